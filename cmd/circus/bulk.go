@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	archiveis "jaytaylor.com/archive.is"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/hydrate"
+)
+
+var (
+	SkipExisting bool
+	HaltOnError  bool
+)
+
+func init() {
+	addHydrateFlags(bulkCmd.Flags())
+	bulkCmd.Flags().BoolVarP(&SkipExisting, "skip-existing", "k", false, "Skip already hydrated stories for which a destination JSON file exists")
+	bulkCmd.Flags().BoolVarP(&HaltOnError, "halt-on-error", "e", false, "Exit immediately if an error is encountered")
+	rootCmd.AddCommand(bulkCmd)
+	commandSettings[bulkCmd] = hydrateSettings
+}
+
+var bulkCmd = &cobra.Command{
+	Use:    "bulk [stories-json-file] [output-dir]",
+	Short:  "Hydrates every story in a JSON array, such as the output of stories",
+	Long:   "Each hydrated story is written to <output-dir>/<ID>.json.  Stories which fail are recorded as <ID>.error.json failure records instead.  The stories file may be '-' to read from stdin.  One NLPWeb instance is shared by all stories.",
+	Args:   cobra.ExactArgs(2),
+	PreRun: preRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := interruptContext()
+		defer cancel()
+		if err := bulk(ctx, args[0], args[1]); err != nil {
+			errorExit(err)
+		}
+	},
+}
+
+// bulk hydrates the stories listed in filename into outputDir.
+func bulk(ctx context.Context, filename string, outputDir string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return fmt.Errorf("reading stories: %s", err)
+	}
+	var stories []*domain.Story
+	if err := json.Unmarshal(data, &stories); err != nil {
+		return fmt.Errorf("input must contain a JSON array of stories: %s", err)
+	}
+
+	if err := os.MkdirAll(outputDir, os.FileMode(int(0755))); err != nil {
+		return fmt.Errorf("creating output directory: %s", err)
+	}
+
+	return withNLPWeb(func(nlpWebURL string) error {
+		h := newHydrator(nlpWebURL)
+
+		var hydrated, failed, skipped int
+		for _, story := range stories {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			outputFile := filepath.Join(outputDir, story.ID.String()+".json")
+			if SkipExisting {
+				if _, err := os.Stat(outputFile); err == nil {
+					log.WithField("url", story.URL).WithField("file", outputFile).Debug("Skipping story with existing output file")
+					skipped++
+					continue
+				}
+			}
+
+			log.WithField("url", story.URL).Info("Hydrating story")
+			if err := bulkHydrate(ctx, h, story, outputFile); err != nil {
+				failed++
+				log.WithField("url", story.URL).Errorf("Hydrating story: %s", err)
+				if werr := writeFailure(filepath.Join(outputDir, story.ID.String()+".error.json"), story.URL, err); werr != nil {
+					log.WithField("url", story.URL).Error(werr)
+				}
+				if HaltOnError {
+					return err
+				}
+				continue
+			}
+			hydrated++
+		}
+
+		log.WithField("stories", len(stories)).WithField("hydrated", hydrated).WithField("failed", failed).WithField("skipped", skipped).Info("Processed stories this run")
+		return nil
+	})
+}
+
+// bulkHydrate hydrates story and writes the resulting context to outputFile.
+func bulkHydrate(ctx context.Context, h *hydrate.Hydrator, story *domain.Story, outputFile string) error {
+	dctx, err := h.Hydrate(ctx, story.URL)
+	if err != nil {
+		return err
+	}
+	dctx.Story = story
+
+	if FetchComments && (story.Source == "" || story.Source == domain.SourceHN) {
+		id, err := strconv.ParseInt(story.ID.String(), 10, 64)
+		if err != nil {
+			return hydrate.NewError(story.URL, domain.FailureInternal, fmt.Errorf("parsing HN story ID %q: %s", story.ID, err))
+		}
+		// The article is still worth keeping without its discussion.
+		if err := h.AttachComments(ctx, dctx, id); err != nil {
+			log.WithField("url", story.URL).Warnf("Capturing comments: %s", err)
+		}
+	}
+
+	if dctx.ArchiveIs, err = archiveis.Search(story.URL, RequestTimeout); err != nil {
+		log.WithField("url", story.URL).Warnf("Searching archive.is snapshots: %s", err)
+	}
+
+	bs, err := json.Marshal(dctx)
+	if err != nil {
+		return hydrate.NewError(story.URL, domain.FailureInternal, fmt.Errorf("serializing story: %s", err))
+	}
+	if err := ioutil.WriteFile(outputFile, bs, os.FileMode(int(0644))); err != nil {
+		return hydrate.NewError(story.URL, domain.FailureInternal, fmt.Errorf("writing story: %s", err))
+	}
+	return nil
+}
+
+// writeFailure keeps the machine-readable failure record for err next to the
+// story output.
+func writeFailure(filename string, url string, err error) error {
+	hErr, ok := err.(*hydrate.Error)
+	if !ok {
+		hErr = hydrate.NewError(url, domain.FailureInternal, err)
+	}
+	bs, err := json.MarshalIndent(hErr.Failure(), "", "    ")
+	if err != nil {
+		return fmt.Errorf("serializing failure record: %s", err)
+	}
+	if err := ioutil.WriteFile(filename, bs, os.FileMode(int(0644))); err != nil {
+		return fmt.Errorf("writing failure record: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var PrintFormat string
+
+func init() {
+	configPrintCmd.Flags().StringVarP(&PrintFormat, "format", "f", "text", `Output format, one of "text", "json" or "shell"`)
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the circus configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long:  "Prints every setting after applying the config file, profile and environment, along with where each value came from.  The shell format emits CIRCUS_* variable assignments for use with eval.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cfg.Print(os.Stdout, PrintFormat); err != nil {
+			errorExit(err)
+		}
+	},
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/academic"
+	"jaytaylor.com/circus/pkg/assets"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+	"jaytaylor.com/circus/pkg/forge"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/hydrate"
+	"jaytaylor.com/circus/pkg/jobqueue"
+	"jaytaylor.com/circus/pkg/render"
+	"jaytaylor.com/circus/pkg/server"
+	"jaytaylor.com/circus/pkg/warc"
+)
+
+var (
+	// Favorites string
+	AltNLPWebServer string
+	RequestTimeout  time.Duration
+	ErrorFormat     string
+	FetchPolicy     string
+	StagesFile      string
+	FetchStateDir   string
+	RespectRobots   bool
+	OutputContext   bool
+	SummarySize     int
+	AssetsDir       string
+	AssetsURL       string
+	ThumbnailWidth  int
+	SnapshotDir     string
+	SnapshotURL     string
+	StoryID         int64
+	FetchComments   bool
+	HNAPI           string
+	GitHubAPI       string
+	GitLabAPI       string
+	ArXivAPI        string
+	DOIResolver     string
+	MaxComments     int
+	ListenAddr      string
+	QueueDir        string
+	JobRetention    time.Duration
+	Workers         int
+	AuthConfig      string
+	StoryDir        string
+	Render          bool
+	RenderMinWords  int
+	BrowserPath     string
+	RenderTimeout   time.Duration
+	RenderIdle      time.Duration
+	RenderNoSandbox bool
+
+	NLPWebAddr          = "127.0.0.1:8000"
+	PDFProcessorTimeout = hydrate.DefaultPDFTimeout
+
+	fetchPolicy = fetchpolicy.New()
+	stages      map[hydrate.Phase][]hydrate.Stage
+)
+
+// hydrateSettings binds the hydrate flags to their config file settings.
+var hydrateSettings = map[string]string{
+	"nlpweb-server":     "nlpweb.server",
+	"http-timeout":      "fetch.timeout",
+	"fetch-policy":      "fetch.policy",
+	"fetch-state-dir":   "fetch.stateDir",
+	"respect-robots":    "fetch.respectRobots",
+	"summary-sentences": "hydrator.summarySentences",
+	"assets-dir":        "hydrator.assetsDir",
+	"assets-url":        "hydrator.assetsURL",
+	"thumbnail-width":   "hydrator.thumbnailWidth",
+	"snapshot-dir":      "hydrator.snapshotDir",
+	"snapshot-url":      "hydrator.snapshotURL",
+	"stages":            "hydrator.stages",
+	"hn-api":            "hn.api",
+	"max-comments":      "hn.maxComments",
+	"github-api":        "forge.githubAPI",
+	"gitlab-api":        "forge.gitlabAPI",
+	"arxiv-api":         "academic.arxivAPI",
+	"doi-resolver":      "academic.doiResolver",
+	"render":            "render.enabled",
+	"render-min-words":  "render.minWords",
+	"browser":           "render.browser",
+	"render-timeout":    "render.timeout",
+	"render-idle":       "render.idle",
+	"render-no-sandbox": "render.noSandbox",
+	"listen":            "serve.listen",
+	"queue-dir":         "serve.queueDir",
+	"job-retention":     "serve.jobRetention",
+	"workers":           "serve.workers",
+	"auth-config":       "serve.authConfig",
+	"story-dir":         "serve.storyDir",
+}
+
+func init() {
+	hydrateCmd.AddCommand(serveCmd)
+	addHydrateFlags(hydrateCmd.PersistentFlags())
+	hydrateCmd.Flags().StringVarP(&ErrorFormat, "error-format", "", "text", `Error output format, one of "text" or "json" (json failure records are written to stdout)`)
+	hydrateCmd.Flags().BoolVarP(&OutputContext, "context", "c", false, "Emit a full story context (article plus resolved original, final and canonical URLs) rather than only the article")
+	hydrateCmd.Flags().Int64VarP(&StoryID, "hn-id", "", 0, "HN story ID of the target, used to fetch its comments")
+	rootCmd.AddCommand(hydrateCmd)
+	commandSettings[hydrateCmd] = hydrateSettings
+	commandSettings[serveCmd] = hydrateSettings
+
+	serveCmd.Flags().StringVarP(&ListenAddr, "listen", "l", "127.0.0.1:8080", "Address for the REST API to listen on")
+	serveCmd.Flags().StringVarP(&QueueDir, "queue-dir", "", "hydrator-queue", "Directory the job queue is persisted to")
+	serveCmd.Flags().DurationVarP(&JobRetention, "job-retention", "", jobqueue.DefaultRetention, "How long finished jobs are kept in the queue directory (0 to keep them forever)")
+	serveCmd.Flags().IntVarP(&Workers, "workers", "w", 2, "Number of jobs to hydrate concurrently")
+	serveCmd.Flags().StringVarP(&AuthConfig, "auth-config", "", "", "Path to JSON file of API tokens; requires a token for the API and enables the /v1/pages endpoint for pushing pages from the browser")
+	serveCmd.Flags().StringVarP(&StoryDir, "story-dir", "", "", "Directory pushed pages are saved to as story JSON files (required with --auth-config)")
+}
+
+// addHydrateFlags registers the extraction and enrichment flags, which are
+// shared by the hydrate, serve and bulk commands.
+func addHydrateFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&AltNLPWebServer, "nlpweb-server", "s", "", "Base URL to already running NLPWeb server (saves on the enormous overhead of launching and initializing one)")
+	flags.DurationVarP(&RequestTimeout, "http-timeout", "t", 10*time.Second, "HTTP timeout value when downloading HTML content")
+	flags.IntVarP(&SummarySize, "summary-sentences", "", 3, "Number of key sentences to include in the article summary (0 to disable)")
+	flags.StringVarP(&FetchPolicy, "fetch-policy", "", "", "Path to JSON file with per-domain fetch policies (user-agent, headers, cookies, delay, max concurrency, robots.txt)")
+	flags.StringVarP(&FetchStateDir, "fetch-state-dir", "", "", "Directory for persisting robots.txt cache and per-host rate limit state between runs")
+	flags.StringVarP(&AssetsDir, "assets-dir", "", "", "Directory of the content-addressed image store; when set, the top image and inline images are mirrored locally")
+	flags.StringVarP(&AssetsURL, "assets-url", "", "/assets", "Public URL prefix under which the assets directory is served")
+	flags.IntVarP(&ThumbnailWidth, "thumbnail-width", "", assets.DefaultThumbnailWidth, "Width in pixels of generated image thumbnails (0 to disable)")
+	flags.StringVarP(&SnapshotDir, "snapshot-dir", "", "", "Directory to write our own single-file HTML and WARC snapshots of each fetched page to (disabled when empty)")
+	flags.StringVarP(&SnapshotURL, "snapshot-url", "", "/snapshots", "Public URL prefix under which the snapshot directory is served")
+	flags.StringVarP(&StagesFile, "stages", "", "", "Path to JSON file of custom pipeline stages to run during hydration")
+	flags.BoolVarP(&FetchComments, "comments", "", false, "Capture and tag the HN comment thread of the story given by --hn-id (included in --context output)")
+	flags.StringVarP(&HNAPI, "hn-api", "", hnapi.DefaultBaseURL, "Base URL of the HN Firebase API")
+	flags.IntVarP(&MaxComments, "max-comments", "", hnapi.DefaultMaxComments, "Maximum number of comments to capture per story")
+	flags.StringVarP(&GitHubAPI, "github-api", "", forge.GitHubBaseURL, "Base URL of the GitHub API, used for github.com repository links")
+	flags.StringVarP(&GitLabAPI, "gitlab-api", "", forge.GitLabBaseURL, "Base URL of the GitLab API, used for gitlab.com repository links")
+	flags.StringVarP(&ArXivAPI, "arxiv-api", "", academic.ArXivBaseURL, "Query endpoint of the arXiv API, used for arxiv.org paper links")
+	flags.StringVarP(&DOIResolver, "doi-resolver", "", academic.DOIBaseURL, "Base URL of the DOI resolver, used for doi.org and ACM Digital Library paper links")
+	flags.BoolVarP(&RespectRobots, "respect-robots", "", false, "Honor robots.txt for all domains not otherwise configured")
+	flags.BoolVarP(&Render, "render", "", false, "Render pages in headless Chromium when the plain fetch yields too little text (skipped when no browser is installed)")
+	flags.IntVarP(&RenderMinWords, "render-min-words", "", 150, "Extracted word count below which a page is rendered in the browser")
+	flags.StringVarP(&BrowserPath, "browser", "", "", "Path to the Chromium or Chrome binary used for rendering (default: $CHROME_PATH or the first found on the PATH)")
+	flags.DurationVarP(&RenderTimeout, "render-timeout", "", render.DefaultTimeout, "Maximum time to spend rendering a page")
+	flags.DurationVarP(&RenderIdle, "render-idle", "", render.DefaultIdleTime, "How long the network must be quiet for a rendered page to be considered loaded")
+	flags.BoolVarP(&RenderNoSandbox, "render-no-sandbox", "", false, "Disable the browser sandbox, which is required to render as root (rendered pages are then not isolated from the host)")
+}
+
+var hydrateCmd = &cobra.Command{
+	Use:    "hydrate [url|-|warc-file]",
+	Short:  "Identifies and tags main content in an HTML document",
+	Long:   "Use GoOse and SpaCy to extract main page content and tag it with keywords",
+	Args:   cobra.MinimumNArgs(1),
+	PreRun: preRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		// Share one NLPWeb instance across all of the articles in a WARC.
+		err := withNLPWeb(func(nlpWebURL string) error {
+			h := newHydrator(nlpWebURL)
+			if isWARC(args[0]) {
+				return hydrateWARC(ctx, h, args[0], os.Stdout)
+			}
+
+			dctx, err := hydrateTarget(ctx, h, args[0])
+			if err != nil {
+				return err
+			}
+
+			var v interface{} = dctx.Article
+			if OutputContext {
+				v = dctx
+			}
+			bs, err := json.MarshalIndent(v, "", "    ")
+			if err != nil {
+				return fmt.Errorf("serializing final result: %s", err)
+			}
+			fmt.Println(string(bs))
+			return nil
+		})
+		if err != nil {
+			errorExit(err)
+		}
+	},
+}
+
+func preRun(cmd *cobra.Command, _ []string) {
+	applyConfig()
+	if ErrorFormat != "text" && ErrorFormat != "json" {
+		errorExit(fmt.Errorf("invalid error format %q, must be one of \"text\" or \"json\"", ErrorFormat))
+	}
+	if err := initFetchPolicy(); err != nil {
+		errorExit(err)
+	}
+	if StagesFile != "" {
+		var err error
+		if stages, err = hydrate.LoadStages(StagesFile); err != nil {
+			errorExit(err)
+		}
+	}
+}
+
+var serveCmd = &cobra.Command{
+	Use:    "serve",
+	Short:  "Run the hydrator as a REST API service",
+	Long:   "Accept hydration jobs over HTTP and process them from a persistent queue, keeping GoOse and the NLP sidecar warm between articles",
+	Args:   cobra.NoArgs,
+	PreRun: preRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := serve(); err != nil {
+			errorExit(err)
+		}
+	},
+}
+
+// serve runs the REST API until interrupted.  One NLPWeb instance is shared by
+// all jobs.
+func serve() error {
+	var auth *server.AuthConfig
+	if AuthConfig != "" {
+		if StoryDir == "" {
+			return errors.New("--story-dir is required with --auth-config")
+		}
+		var err error
+		if auth, err = server.LoadAuthConfig(AuthConfig); err != nil {
+			return err
+		}
+	}
+
+	queue, err := jobqueue.Open(QueueDir)
+	if err != nil {
+		return err
+	}
+	queue.Retention = JobRetention
+
+	return withNLPWeb(func(nlpWebURL string) error {
+		queue.Start(Workers, hydrateJob(newHydrator(nlpWebURL)))
+		defer queue.Close()
+
+		api := server.New(queue, nlpWebURL)
+		api.Auth = auth
+		srv := &http.Server{
+			Addr:    ListenAddr,
+			Handler: api.Handler(),
+		}
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sig)
+		go func() {
+			<-sig
+			log.Info("Shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Errorf("Shutting down http server: %s", err)
+			}
+		}()
+
+		log.WithField("addr", ListenAddr).WithField("queue-dir", QueueDir).WithField("workers", Workers).Info("Serving")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("serving: %s", err)
+		}
+		return nil
+	})
+}
+
+// hydrateJob returns the job queue handler for server.JobRequest jobs.
+// Cancelling a job cancels its hydration.
+func hydrateJob(h *hydrate.Hydrator) jobqueue.Handler {
+	return func(ctx context.Context, job *jobqueue.Job) (interface{}, error) {
+		req := &server.JobRequest{}
+		if err := json.Unmarshal(job.Request, req); err != nil {
+			return nil, hydrate.NewError("", domain.FailureInternal, fmt.Errorf("decoding job request: %s", err))
+		}
+
+		var (
+			dctx *domain.Context
+			err  error
+		)
+		if req.HTML != "" {
+			dctx, err = h.HydrateHTML(ctx, req.URL, []byte(req.HTML))
+		} else {
+			dctx, err = h.Hydrate(ctx, req.URL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req.Comments {
+			if err := h.AttachComments(ctx, dctx, req.HNID); err != nil {
+				log.WithField("url", req.URL).Warnf("Capturing comments: %s", err)
+			}
+		}
+		if req.Save {
+			// Saving jobs are only queued by the token-protected /v1/pages,
+			// so refuse any left over from a run without auth.
+			if AuthConfig == "" {
+				return nil, hydrate.NewError(req.URL, domain.FailureInternal, errors.New("refusing to save story: --auth-config is not set"))
+			}
+			if err := saveStory(dctx, &req.HydrateRequest); err != nil {
+				return nil, err
+			}
+		}
+		return dctx, nil
+	}
+}
+
+// saveStory records ctx as a story pushed from the browser and writes it to
+// the story directory, replacing any earlier copy of the same URL.
+func saveStory(ctx *domain.Context, req *server.HydrateRequest) error {
+	title := req.Title
+	if title == "" {
+		title = ctx.Article.Title
+	}
+	if title == "" {
+		title = req.URL
+	}
+	ctx.Story = &domain.Story{
+		ID:        domain.HashStoryID(domain.SourceBrowser, req.URL),
+		Source:    domain.SourceBrowser,
+		Title:     title,
+		URL:       req.URL,
+		Timestamp: domain.Time{Time: time.Now().UTC()},
+	}
+
+	bs, err := json.MarshalIndent(ctx, "", "    ")
+	if err != nil {
+		return hydrate.NewError(req.URL, domain.FailureInternal, fmt.Errorf("serializing story: %s", err))
+	}
+	if err := os.MkdirAll(StoryDir, os.FileMode(int(0755))); err != nil {
+		return hydrate.NewError(req.URL, domain.FailureInternal, fmt.Errorf("creating story directory: %s", err))
+	}
+	name := filepath.Join(StoryDir, ctx.Story.ID.String()+".json")
+	if err := ioutil.WriteFile(name+".tmp", bs, os.FileMode(int(0644))); err != nil {
+		return hydrate.NewError(req.URL, domain.FailureInternal, fmt.Errorf("writing story: %s", err))
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return hydrate.NewError(req.URL, domain.FailureInternal, fmt.Errorf("writing story: %s", err))
+	}
+	log.WithField("url", req.URL).WithField("file", name).Info("Saved pushed page")
+	return nil
+}
+
+// hydrateTarget hydrates the article at target, or the HTML read from stdin
+// when target is "-", and attaches the comment thread of --hn-id when
+// --comments is set.
+func hydrateTarget(ctx context.Context, h *hydrate.Hydrator, target string) (*domain.Context, error) {
+	var (
+		dctx *domain.Context
+		err  error
+	)
+	if target == "-" {
+		var content []byte
+		if content, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return nil, hydrate.NewError(target, domain.FailureInternal, fmt.Errorf("reading stdin: %s", err))
+		}
+		dctx, err = h.HydrateHTML(ctx, "", content)
+	} else {
+		dctx, err = h.Hydrate(ctx, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if StoryID != 0 && FetchComments {
+		if err := h.AttachComments(ctx, dctx, StoryID); err != nil {
+			log.WithField("url", target).Warnf("Capturing comments: %s", err)
+		}
+	}
+	return dctx, nil
+}
+
+func isWARC(target string) bool {
+	lower := strings.ToLower(target)
+	return strings.HasSuffix(lower, ".warc") || strings.HasSuffix(lower, ".warc.gz")
+}
+
+// hydrateWARC runs every HTML response in the WARC file at path through
+// extraction and tagging, without any network access to the original sites.
+// Output is a JSON object of story contexts keyed by each response's target
+// URI.  Records which fail to hydrate are logged and skipped.
+func hydrateWARC(ctx context.Context, h *hydrate.Hydrator, path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return hydrate.NewError(path, domain.FailureFetch, fmt.Errorf("opening warc: %s", err))
+	}
+	defer f.Close()
+
+	reader, err := warc.NewReader(f)
+	if err != nil {
+		return hydrate.NewError(path, domain.FailureFetch, err)
+	}
+	reader.Skip = func(record *warc.Record) bool {
+		return record.Type() != warc.TypeResponse
+	}
+
+	// Records are hydrated without network access, so images are not
+	// mirrored.
+	offline := *h
+	offline.Assets = nil
+	h = &offline
+
+	var (
+		seen  = map[string]struct{}{}
+		count int
+	)
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return hydrate.NewError(path, domain.FailureInternal, fmt.Errorf("writing output: %s", err))
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return hydrate.NewError(path, domain.FailureInternal, err)
+		}
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return hydrate.NewError(path, domain.FailureFetch, err)
+		}
+		if record.Type() != warc.TypeResponse {
+			continue
+		}
+		uri := record.TargetURI()
+		if _, ok := seen[uri]; ok {
+			log.WithField("url", uri).Debug("Skipping repeat capture")
+			continue
+		}
+
+		resp, body, err := record.HTTPResponse()
+		if err != nil {
+			log.WithField("url", uri).Warnf("Skipping warc record: %s", err)
+			continue
+		}
+		if resp.StatusCode/100 != 2 || !isHTML(resp.Header.Get("Content-Type"), body) {
+			continue
+		}
+		seen[uri] = struct{}{}
+
+		dctx, err := h.HydrateHTML(ctx, uri, body)
+		if err != nil {
+			if hErr, ok := err.(*hydrate.Error); ok {
+				log.WithField("url", uri).WithField("kind", hErr.Kind).Warnf("Skipping warc record: %s", err)
+			} else {
+				log.WithField("url", uri).Warnf("Skipping warc record: %s", err)
+			}
+			continue
+		}
+
+		key, _ := json.Marshal(uri)
+		bs, err := json.MarshalIndent(dctx, "    ", "    ")
+		if err != nil {
+			return hydrate.NewError(uri, domain.FailureInternal, fmt.Errorf("serializing result: %s", err))
+		}
+		sep := "\n    "
+		if count > 0 {
+			sep = ",\n    "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s: %s", sep, key, bs); err != nil {
+			return hydrate.NewError(path, domain.FailureInternal, fmt.Errorf("writing output: %s", err))
+		}
+		count++
+	}
+	if _, err := io.WriteString(w, "\n}\n"); err != nil {
+		return hydrate.NewError(path, domain.FailureInternal, fmt.Errorf("writing output: %s", err))
+	}
+	log.WithField("warc", path).WithField("articles", count).WithField("skipped", reader.Skipped).Info("Hydrated warc")
+	if count == 0 {
+		return hydrate.NewError(path, domain.FailureExtractEmpty, errors.New("no articles found in warc"))
+	}
+	return nil
+}
+
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// newHydrator returns a hydrator configured by the hydrate flags, which tags
+// named entities with the NLPWeb server at nlpWebURL.
+func newHydrator(nlpWebURL string) *hydrate.Hydrator {
+	client := hydrate.NewClient(RequestTimeout, fetchPolicy)
+
+	h := hydrate.New()
+	h.Client = client
+	h.NER = hydrate.NewNLPWeb(nlpWebURL)
+	h.Archives = []hydrate.ArchiveProvider{&hydrate.ArchiveIs{Timeout: RequestTimeout}}
+	h.RenderMinWords = RenderMinWords
+	h.SummarySentences = SummarySize
+	h.SnapshotDir = SnapshotDir
+	h.SnapshotURL = SnapshotURL
+	h.PDFTimeout = PDFProcessorTimeout
+	h.HN.BaseURL = HNAPI
+	h.HN.HTTPClient = client
+	h.HN.MaxComments = MaxComments
+	h.Stages = stages
+
+	github := forge.NewGitHub()
+	github.BaseURL = GitHubAPI
+	github.Token = cfg.Forge.GitHubToken
+	github.HTTPClient = client
+	gitlab := forge.NewGitLab()
+	gitlab.BaseURL = GitLabAPI
+	gitlab.Token = cfg.Forge.GitLabToken
+	gitlab.HTTPClient = client
+	arXiv := academic.NewArXiv()
+	arXiv.BaseURL = ArXivAPI
+	arXiv.HTTPClient = client
+	doi := academic.NewDOIResolver()
+	doi.BaseURL = DOIResolver
+	doi.HTTPClient = client
+	h.Handlers = []hydrate.Handler{
+		&hydrate.Forges{Forges: []forge.Forge{github, gitlab}},
+		&hydrate.Papers{ArXiv: arXiv, DOI: doi},
+	}
+
+	if AssetsDir != "" {
+		h.Assets = assets.New(AssetsDir, AssetsURL)
+		h.Assets.Client = client
+		h.Assets.ThumbnailWidth = ThumbnailWidth
+	}
+
+	if Render {
+		renderer, err := render.New(BrowserPath)
+		if err == render.ErrNoBrowser {
+			log.Debug("Skipping render: no browser found")
+		} else if err != nil {
+			log.Warnf("Skipping render: %s", err)
+		} else if os.Geteuid() == 0 && !RenderNoSandbox {
+			log.Warnf("Skipping render: %s", render.ErrRoot)
+		} else {
+			renderer.UserAgent = cfg.Fetch.UserAgent
+			renderer.Timeout = RenderTimeout
+			renderer.IdleTime = RenderIdle
+			renderer.NoSandbox = RenderNoSandbox
+			renderer.Policy = fetchPolicy
+			if t, ok := client.Transport.(*fetchpolicy.Transport); ok {
+				renderer.Transport = t.Base
+			}
+			h.Renderer = renderer
+		}
+	}
+
+	return h
+}
+
+// interruptContext returns a context which is cancelled on SIGINT or SIGTERM.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-sig:
+			log.Info("Interrupted, cancelling")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// applyConfig applies the settings which have no flags.
+func applyConfig() {
+	NLPWebAddr = cfg.NLPWeb.Addr
+	PDFProcessorTimeout = cfg.Hydrator.PDFTimeout.D()
+}
+
+func initFetchPolicy() error {
+	if FetchPolicy != "" {
+		p, err := fetchpolicy.Load(FetchPolicy)
+		if err != nil {
+			return err
+		}
+		fetchPolicy = p
+	}
+	if FetchStateDir != "" {
+		fetchPolicy.StateDir = FetchStateDir
+	}
+	if RespectRobots {
+		fetchPolicy.Default.RespectRobots = &RespectRobots
+	}
+	// New and Load always fill in the built-in user-agent, so a configured one
+	// has to replace it rather than only fill a gap.
+	if cfg.Fetch.UserAgent != "" && cfg.Fetch.UserAgent != fetchpolicy.DefaultUserAgent {
+		fetchPolicy.Default.UserAgent = cfg.Fetch.UserAgent
+	}
+	return nil
+}
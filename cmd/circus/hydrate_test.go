@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"jaytaylor.com/circus/pkg/config"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+)
+
+func TestInitFetchPolicyUserAgent(t *testing.T) {
+	defer func(c *config.Config, p *fetchpolicy.Policy) {
+		cfg, fetchPolicy = c, p
+	}(cfg, fetchPolicy)
+
+	testCases := []struct {
+		configured string
+		expected   string
+	}{
+		{fetchpolicy.DefaultUserAgent, fetchpolicy.DefaultUserAgent},
+		{"my-agent/1.0", "my-agent/1.0"},
+	}
+	for _, testCase := range testCases {
+		cfg = config.Default()
+		cfg.Fetch.UserAgent = testCase.configured
+		fetchPolicy = fetchpolicy.New()
+		if err := initFetchPolicy(); err != nil {
+			t.Fatal(err)
+		}
+		if actual := fetchPolicy.Default.UserAgent; actual != testCase.expected {
+			t.Errorf("configured %q: expected policy user-agent %q, got %q", testCase.configured, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/config"
+	"jaytaylor.com/circus/pkg/hydrate"
+	"jaytaylor.com/circus/pkg/logging"
+	"jaytaylor.com/circus/pkg/version"
+)
+
+var (
+	ConfigPath string
+	Profile    string
+	Quiet      bool
+	Verbose    bool
+
+	cfg = config.Default()
+
+	// commandSettings binds each command's flags to their config file
+	// settings.
+	commandSettings = map[*cobra.Command]map[string]string{}
+)
+
+func init() {
+	config.AddFlags(rootCmd.PersistentFlags(), &ConfigPath, &Profile)
+	logging.AddFlags(rootCmd.PersistentFlags(), &Quiet, &Verbose)
+
+	rootCmd.AddCommand(versionCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		errorExit(err)
+	}
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "circus",
+	Short: "Archives, tags and publishes saved links",
+	Long:  "Collects stories from link aggregators and bookmarks, hydrates their articles with extracted content and named entities, and renders them into a static Hugo site.",
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		logging.Init(Quiet, Verbose)
+		c, err := config.FromFlags(cmd.Flags(), ConfigPath, Profile, commandSettings[cmd])
+		if err != nil {
+			errorExit(err)
+		}
+		cfg = c
+	},
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version information",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(version.String())
+	},
+}
+
+// errorExit reports err and exits.  Hydration errors exit with the status of
+// their failure kind, and are written to stdout as JSON failure records when
+// the error format is json.
+func errorExit(err interface{}) {
+	hErr, ok := err.(*hydrate.Error)
+	if !ok {
+		hErr = hydrate.NewError("", domain.FailureInternal, fmt.Errorf("%s", err))
+	}
+	failure := hErr.Failure()
+
+	if ErrorFormat == "json" {
+		bs, err := json.MarshalIndent(failure, "", "    ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: serializing failure record: %s\n", err)
+		} else {
+			fmt.Println(string(bs))
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", failure.Message)
+	}
+	os.Exit(failure.ExitCode)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"gigawatt.io/oslib"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	nlpCmd.Flags().StringVarP(&NLPWebAddr, "addr", "a", NLPWebAddr, "Address for nlpweb.py to listen on")
+	rootCmd.AddCommand(nlpCmd)
+	commandSettings[nlpCmd] = map[string]string{"addr": "nlpweb.addr"}
+}
+
+var nlpCmd = &cobra.Command{
+	Use:   "nlp",
+	Short: "Run the nlpweb.py named-entity recognition sidecar",
+	Long:  "Runs nlpweb.py in the foreground until interrupted, so hydrate and bulk runs can share it via --nlpweb-server rather than each launching their own",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, ack, err := launchNLPWeb()
+		if err != nil {
+			errorExit(fmt.Errorf("starting nlpweb.py: %s", err))
+		}
+		log.WithField("addr", NLPWebAddr).Info("Started nlpweb.py")
+		<-ack
+	},
+}
+
+// withNLPWeb calls fn with the base URL of the NLPWeb server given by
+// --nlpweb-server, or else of one launched for the duration of the call.
+func withNLPWeb(fn func(baseURL string) error) error {
+	var baseURL string
+
+	if AltNLPWebServer == "" {
+		nlpSig, nlpAck, err := launchNLPWeb()
+		if err != nil {
+			return fmt.Errorf("starting nlpweb.py: %s", err)
+		}
+
+		defer func() {
+			nlpSig <- os.Interrupt
+			<-nlpAck
+		}()
+
+		baseURL = fmt.Sprintf("http://%v", NLPWebAddr)
+	} else {
+		baseURL = AltNLPWebServer
+	}
+
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = fmt.Sprintf("http://%v", baseURL)
+	}
+
+	err := fn(baseURL)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func launchNLPWeb() (chan os.Signal, chan struct{}, error) {
+	cmd := exec.Command("/usr/bin/env", "bash", "-c",
+		fmt.Sprintf(": && set -o errexit && cd %q && source ../venv/bin/activate && python ../nlpweb.py %v", oslib.PathDirName(os.Args[0]), NLPWebAddr),
+	)
+
+	{
+		r, w := io.Pipe()
+		cmd.Stdout = w
+		go func() {
+			scanner := bufio.NewScanner(r)
+			scanner.Split(bufio.ScanLines)
+			for scanner.Scan() {
+				log.Debugf("[nlpweb][stdout] %v", scanner.Text())
+			}
+		}()
+	}
+
+	{
+		r, w := io.Pipe()
+		cmd.Stderr = w
+		go func() {
+			scanner := bufio.NewScanner(r)
+			scanner.Split(bufio.ScanLines)
+			for scanner.Scan() {
+				log.Debugf("[nlpweb][stderr] %v", scanner.Text())
+			}
+		}()
+	}
+
+	log.Debugf("Starting nlpweb.py on address=%v", NLPWebAddr)
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	var (
+		d = net.Dialer{
+			Timeout: 1 * time.Second,
+		}
+		since   = time.Now()
+		maxWait = 10 * time.Second
+	)
+	for {
+		if time.Now().Sub(since) > maxWait {
+			return nil, nil, fmt.Errorf("timed out after %s waiting for nlpweb.py to start", maxWait)
+		}
+		conn, err := d.Dial("tcp", NLPWebAddr)
+		if err == nil {
+			if err = conn.Close(); err != nil {
+				return nil, nil, fmt.Errorf("unexpected error closing connection to nlpweb.py: %s", err)
+			}
+			break
+		}
+		log.Debug(".")
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Debugf("Started nlpweb.py OK, pid=%v", cmd.Process.Pid)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	signal.Notify(sig, os.Kill)
+
+	ack := make(chan struct{}, 1)
+
+	go func() {
+		<-sig // Wait for ^C signal.
+		fmt.Fprintln(os.Stderr, "\nInterrupt or kill signal detected, shutting down..")
+
+		if err := cmd.Process.Kill(); err != nil {
+			log.Errorf("Shutting down nlpweb.py: %s", err)
+		}
+		log.Debugf("Killed nlpweb.py") // out=%v err=%v", stdout.String(), stderr.String())
+
+		select {
+		case ack <- struct{}{}:
+		default:
+		}
+	}()
+
+	return sig, ack, nil
+}
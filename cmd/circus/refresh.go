@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/hnapi"
+)
+
+var (
+	Concurrency int
+	Force       bool
+)
+
+func init() {
+	refreshCmd.Flags().StringVarP(&HNAPI, "hn-api", "", hnapi.DefaultBaseURL, "Base URL of the HN Firebase API")
+	refreshCmd.Flags().IntVarP(&Concurrency, "concurrency", "c", hnapi.DefaultConcurrency, "Number of stories to refresh in parallel")
+	refreshCmd.Flags().BoolVarP(&Force, "force", "f", false, "Refresh every story, even those not yet due")
+	rootCmd.AddCommand(refreshCmd)
+	commandSettings[refreshCmd] = map[string]string{
+		"hn-api":      "hn.api",
+		"concurrency": "hn.concurrency",
+	}
+}
+
+var refreshCmd = &cobra.Command{
+	Use:   "hn-refresh [json-file-or-dir]...",
+	Short: "Re-polls HN points, comment counts and dead/deleted status of stored stories",
+	Long:  "Stories are polled on a decaying schedule, frequently while new and rarely once old.  Each poll is appended to the story's ScoreHistory and its Points and Comments are updated in place.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var filenames []string
+		for _, arg := range args {
+			fi, err := os.Stat(arg)
+			if err != nil {
+				errorExit(err)
+			}
+			if !fi.IsDir() {
+				filenames = append(filenames, arg)
+				continue
+			}
+			matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+			if err != nil {
+				errorExit(err)
+			}
+			for _, match := range matches {
+				if !strings.HasSuffix(match, ".error.json") {
+					filenames = append(filenames, match)
+				}
+			}
+		}
+
+		client := hnapi.New()
+		client.BaseURL = HNAPI
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		var (
+			now       = time.Now().UTC().Truncate(time.Second)
+			work      = make(chan string)
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			refreshed int
+			failed    int
+		)
+		for i := 0; i < Concurrency || i == 0; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for filename := range work {
+					ok, err := refresh(ctx, client, filename, now)
+					mu.Lock()
+					if err != nil {
+						log.WithField("filename", filename).Errorf("Refreshing story: %s", err)
+						failed++
+					} else if ok {
+						refreshed++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, filename := range filenames {
+			work <- filename
+		}
+		close(work)
+		wg.Wait()
+
+		log.WithField("stories", len(filenames)).WithField("refreshed", refreshed).WithField("failed", failed).Info("Refresh complete")
+		if failed > 0 {
+			errorExit(fmt.Errorf("%v of %v stories failed to refresh", failed, len(filenames)))
+		}
+	},
+}
+
+// refresh polls the HN metadata of the story stored in filename when it is
+// due, and rewrites the file with its Points, Comments and ScoreHistory
+// updated.
+func refresh(ctx context.Context, client *hnapi.Client, filename string, now time.Time) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	dctx := &domain.Context{}
+	if err := json.Unmarshal(data, dctx); err != nil {
+		return false, fmt.Errorf("unmarshalling story: %s", err)
+	}
+	if dctx.Story == nil {
+		return false, fmt.Errorf("no story found")
+	}
+	if dctx.SourceKind() != domain.SourceHN {
+		return false, nil
+	}
+
+	id, err := strconv.ParseInt(dctx.ID.String(), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parsing story ID %q: %s", dctx.ID, err)
+	}
+
+	history := dctx.ScoreHistory
+	var last time.Time
+	if len(history) > 0 {
+		last = history[len(history)-1].Time
+	}
+	if !Force && !hnapi.RefreshDue(dctx.Timestamp.Time, last, now) {
+		log.WithField("id", id).Debug("Story not yet due for refresh")
+		return false, nil
+	}
+
+	item, err := client.Item(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	sample := &domain.ScoreSample{
+		Time: now,
+	}
+	if item == nil {
+		sample.Deleted = true
+	} else {
+		sample.Points = item.Score
+		sample.Comments = item.Descendants
+		sample.Dead = item.Dead
+		sample.Deleted = item.Deleted
+	}
+	// Removed stories report no score, so keep the last known numbers.
+	if (sample.Dead || sample.Deleted) && sample.Points == 0 && len(history) > 0 {
+		sample.Points = history[len(history)-1].Points
+		sample.Comments = history[len(history)-1].Comments
+	}
+	dctx.ScoreHistory = append(history, sample)
+	dctx.Points = sample.Points
+	dctx.Comments = sample.Comments
+
+	if data, err = json.MarshalIndent(dctx, "", "    "); err != nil {
+		return false, fmt.Errorf("marshalling story: %s", err)
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, os.FileMode(int(0644))); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	log.WithField("id", id).WithField("points", sample.Points).WithField("comments", sample.Comments).Debug("Refreshed story")
+	return true, nil
+}
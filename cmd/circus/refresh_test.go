@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/hnapi"
+)
+
+func TestRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":42,"type":"story","score":120,"descendants":31}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "circus-refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "42.json")
+	story := `{"ID":42,"Title":"Story","URL":"https://example.com/","Points":3,"Comments":0,"Timestamp":"2018-06-01T11:00:00Z","Goose":{"title":"Story","namedEntities":[{"frequency":2,"entity":"Ada","label":"PERSON"}]},"Archiveis":null,"Extensions":{"stage":{"score":12345678901}}}`
+	if err := ioutil.WriteFile(filename, []byte(story), os.FileMode(int(0644))); err != nil {
+		t.Fatal(err)
+	}
+
+	client := hnapi.New()
+	client.BaseURL = server.URL
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	ok, err := refresh(context.Background(), client, filename, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatalf("expected story to be due for refresh")
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"{\n    \"ID\": 42,\n    \"Title\": \"Story\",",
+		`"Points": 120,`,
+		`"Comments": 31,`,
+		`"score": 12345678901`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected refreshed story to contain %q, got:\n%s", expected, string(data))
+		}
+	}
+
+	dctx := &domain.Context{}
+	if err := json.Unmarshal(data, dctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(dctx.ScoreHistory) != 1 || dctx.ScoreHistory[0].Points != 120 || !dctx.ScoreHistory[0].Time.Equal(now) {
+		t.Errorf("unexpected score history: %+v", dctx.ScoreHistory)
+	}
+	if dctx.Article == nil || len(dctx.Article.NamedEntities) != 1 || dctx.Article.NamedEntities[0].Frequency != 2 {
+		t.Errorf("expected article to be preserved, got %+v", dctx.Article)
+	}
+
+	if ok, err = refresh(context.Background(), client, filename, now.Add(time.Minute)); err != nil || ok {
+		t.Errorf("expected a just refreshed story to be skipped, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/kljensen/snowball"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/dedup"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/similarity"
+	"jaytaylor.com/circus/pkg/textmanip"
+	"jaytaylor.com/circus/pkg/textstats"
+)
+
+var (
+	PostLimit      int
+	Dedup          bool
+	DedupDistance  int
+	MinWords       int
+	MaxWords       int
+	MaxReadingTime int
+	MaxGrade       float64
+	MinCodeRatio   float64
+	MaxCodeRatio   float64
+)
+
+func init() {
+	addRenderFlags(renderCmd.Flags())
+	rootCmd.AddCommand(renderCmd)
+	commandSettings[renderCmd] = map[string]string{"limit": "site.limit"}
+}
+
+// addRenderFlags registers the post selection flags, which are shared by the
+// render and site commands.
+func addRenderFlags(flags *pflag.FlagSet) {
+	flags.IntVarP(&PostLimit, "limit", "l", -1, "Limit processing to the first N items")
+	flags.BoolVarP(&Dedup, "dedup", "d", true, "Render duplicate and near-duplicate stories as a single post listing every discussion")
+	flags.IntVarP(&DedupDistance, "dedup-distance", "", dedup.DefaultOptions.MaxDistance, "Maximum SimHash distance between near-duplicate article texts (-1 to only match by URL)")
+	flags.IntVarP(&MinWords, "min-words", "", 0, "Only render articles with at least this many words")
+	flags.IntVarP(&MaxWords, "max-words", "", 0, "Only render articles with at most this many words (0 for no limit)")
+	flags.IntVarP(&MaxReadingTime, "max-reading-time", "", 0, "Only render articles with an estimated reading time of at most this many minutes (0 for no limit)")
+	flags.Float64VarP(&MaxGrade, "max-grade", "", 0, "Only render articles with a Flesch-Kincaid grade level of at most this value (0 for no limit)")
+	flags.Float64VarP(&MinCodeRatio, "min-code-ratio", "", 0, "Only render articles where at least this fraction of lines are source code")
+	flags.Float64VarP(&MaxCodeRatio, "max-code-ratio", "", 1, "Only render articles where at most this fraction of lines are source code")
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render [input-file-or-path] [output-path]",
+	Short: "Renders hydrated story JSON into Hugo Markdown posts",
+	Long:  "If input-path is a directory, all files matching *.json will be read.  Both input and output paths can be set to '-' to read/write from/to stdout",
+	Args:  cobra.MinimumNArgs(2),
+	PreRun: func(cmd *cobra.Command, _ []string) {
+		if PostLimit < -1 {
+			errorExit(errors.New("Invalid limit, must be an integer greater than -1"))
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := renderPosts(args[0], args[1]); err != nil {
+			errorExit(err)
+		}
+	},
+}
+
+// renderPosts converts the story JSON file or directory of files at
+// inputPath into Markdown posts in outputPath.
+func renderPosts(inputPath string, outputPath string) error {
+	if outputPath != "-" {
+		if err := os.MkdirAll(outputPath, os.FileMode(int(0755))); err != nil {
+			return fmt.Errorf("creating output directory: %s", err)
+		}
+	}
+
+	fi, err := os.Stat(inputPath)
+	if err != nil {
+		if inputPath == "-" {
+			return convert(inputPath, outputPath)
+		}
+		return err
+	}
+
+	if fi.IsDir() {
+		return doBatch(inputPath, outputPath)
+	}
+	return convert(inputPath, outputPath)
+}
+
+func doBatch(inputPath string, outputPath string) error {
+	filenames, err := filepath.Glob(fmt.Sprintf("%v%v*.json", inputPath, string(os.PathSeparator)))
+	if err != nil {
+		return err
+	}
+
+	// Duplicates can appear anywhere in the corpus, so everything is loaded
+	// before limiting.
+	contexts := make([]*domain.Context, 0, len(filenames))
+	for _, filename := range filenames {
+		if !Dedup && PostLimit > -1 && len(contexts) >= PostLimit {
+			break
+		}
+		if strings.HasSuffix(filename, ".error.json") {
+			// Failure records written by bulk.
+			continue
+		}
+		context, err := load(filename)
+		if err != nil {
+			return err
+		}
+		if !Dedup && !selected(context) {
+			log.WithField("filename", filename).Debug("Skipping story excluded by text statistic filters")
+			continue
+		}
+		contexts = append(contexts, context)
+	}
+
+	if Dedup {
+		opts := dedup.DefaultOptions
+		opts.MaxDistance = DedupDistance
+		clusters := dedup.Group(contexts, opts)
+		log.WithField("stories", len(contexts)).WithField("distinct", len(clusters)).Debug("Grouped duplicate stories")
+
+		contexts = make([]*domain.Context, 0, len(clusters))
+		for _, cluster := range clusters {
+			if selected(cluster.Primary) {
+				contexts = append(contexts, cluster.Primary)
+			}
+		}
+	}
+
+	if PostLimit > -1 && len(contexts) > PostLimit {
+		log.WithField("limit", PostLimit).Debug("Max requested items reached")
+		contexts = contexts[0:PostLimit]
+	}
+
+	// Only stories which are being rendered can be linked to.
+	relatedIndex = similarity.NewIndex(contexts)
+
+	for _, context := range contexts {
+		if err := writePost(context, outputPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selected reports whether the story passes the text statistic filters.
+func selected(context *domain.Context) bool {
+	if context.Article == nil || context.Article.Stats == nil {
+		return MinWords <= 0 && MinCodeRatio <= 0
+	}
+	stats := context.Article.Stats
+	switch {
+	case stats.Words < MinWords:
+		return false
+	case MaxWords > 0 && stats.Words > MaxWords:
+		return false
+	case MaxReadingTime > 0 && stats.ReadingTime > MaxReadingTime:
+		return false
+	case MaxGrade > 0 && stats.FleschKincaidGrade > MaxGrade:
+		return false
+	case stats.CodeBlockRatio < MinCodeRatio || stats.CodeBlockRatio > MaxCodeRatio:
+		return false
+	}
+	return true
+}
+
+func convert(filename string, outputPath string) error {
+	context, err := load(filename)
+	if err != nil {
+		return err
+	}
+	return writePost(context, outputPath)
+}
+
+// load reads and parses a story context JSON file, or stdin when filename is
+// "-".
+func load(filename string) (*domain.Context, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading file %q: %s", filename, err)
+	}
+
+	context := &domain.Context{}
+	// if err := json.Unmarshal(data, &context); err != nil {
+	// 	return fmt.Errorf("parsing JSON from file %q: %s", filename, err)
+	// }
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	if err := d.Decode(&context); err != nil {
+		return nil, fmt.Errorf("parsing JSON from file %q: %s", filename, err)
+	}
+
+	// Stories hydrated before text statistics existed get them computed here.
+	if context.Article != nil && context.Article.Article != nil && context.Article.Stats == nil {
+		context.Article.Stats = textstats.Analyze(context.Article.CleanedText)
+	}
+	return context, nil
+}
+
+func writePost(context *domain.Context, outputPath string) error {
+	buf := &bytes.Buffer{}
+	if err := mdTemplate.Execute(buf, context); err != nil {
+		return fmt.Errorf("executing template for story %v: %s", context.ID, err)
+	}
+
+	if outputPath == "-" {
+		fmt.Print(buf.String())
+	} else {
+		mdFilename := fmt.Sprintf("%v%v%v.md", outputPath, string(os.PathSeparator), context.ID)
+		if err := ioutil.WriteFile(mdFilename, buf.Bytes(), os.FileMode(int(0644))); err != nil {
+			return fmt.Errorf("writing output file %q: %s", mdFilename, err)
+		}
+	}
+	return nil
+}
+
+var (
+	entForbiddenExpr = regexp.MustCompile(`^(?:[0-9.]+|-+)$`)
+	entMustExpr      = regexp.MustCompile(`^[a-zA-Z0-9 #$_'.,/-]+$`)
+)
+
+// cleanedEnts is a text template function which cleans and filters out
+// suspicious NE's as well as hydrating in the stemmed field value.
+func cleanedEnts(nes domain.NamedEntities) domain.NamedEntities {
+	out := domain.NamedEntities{}
+	for _, ne := range nes {
+		ne.Entity = strings.Trim(ne.Entity, "\r\n\t ")
+		if len(ne.Entity) == 0 {
+			continue
+		}
+
+		name := textmanip.ToASCII(ne.Entity)
+		stemmed, err := snowball.Stem(name, "english", true)
+		if err != nil {
+			log.Warnf("Unexpected error stemming %q: %s", ne.Entity, err)
+		} else {
+			ne.Stemmed = strings.Replace(stemmed, " ", "_", -1)
+		}
+
+		if len(name) >= 50 || entForbiddenExpr.MatchString(name) || !entMustExpr.MatchString(name) {
+			log.Debugf("Skipping invalid named entity %q", name)
+			continue
+		}
+		out = append(out, ne)
+	}
+	// Sort by frequency desc.
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Frequency > out[j].Frequency
+	})
+	return out
+}
+
+// minFreqEnts is a text template function which filters out entities below the
+// specified frequency count.
+func minFreqEnts(nes domain.NamedEntities, minFreq int) domain.NamedEntities {
+	out := cleanedEnts(nes)
+	for _, ne := range nes {
+		if ne.Frequency >= minFreq {
+			out = append(out, ne)
+		}
+	}
+	return out
+}
+
+// blockquote prefixes every line of md with a Markdown blockquote marker.
+func blockquote(md string) string {
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// topNEnts returns at most the top N frequent entities.
+func topNEnts(nes domain.NamedEntities, n int) domain.NamedEntities {
+	out := make(domain.NamedEntities, len(nes))
+	copy(out, nes)
+	// Sort by frequency desc.
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Frequency > out[j].Frequency
+	})
+	if n > len(out) {
+		n = len(out)
+	}
+	return out[0:n]
+}
+
+// relatedIndex covers the stories rendered by a batch run.  It is nil when
+// converting a single file.
+var relatedIndex *similarity.Index
+
+// relatedStories is a text template function which returns up to k stories
+// most similar to context.
+func relatedStories(context *domain.Context, k int) []similarity.Match {
+	if relatedIndex == nil {
+		return nil
+	}
+	return relatedIndex.Related(context, k)
+}
+
+// compactJSON returns raw as a single line, which is also valid YAML.
+func compactJSON(raw json.RawMessage) string {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, raw); err != nil {
+		return "null"
+	}
+	return buf.String()
+}
+
+var tplUtils = template.FuncMap{
+	"blockquote":     blockquote,
+	"cleanedEnts":    cleanedEnts,
+	"compactJSON":    compactJSON,
+	"mdEscape":       textmanip.MarkdownEscape,
+	"mdURL":          textmanip.MarkdownURL,
+	"minFreqEnts":    minFreqEnts,
+	"relatedStories": relatedStories,
+	"shortcodeSafe":  textmanip.ShortcodeSafe,
+	"topComments":    hnapi.Top,
+	"topNEnts":       topNEnts,
+	"yamlString":     textmanip.YAMLString,
+}
+
+var mdTemplate = template.Must(template.New("md").Funcs(sprig.TxtFuncMap()).Funcs(tplUtils).Parse(`
+{{- $cleaned := cleanedEnts .Article.NamedEntities -}}
+{{- $top3Cleaned := topNEnts $cleaned 3 -}}
+---
+title: {{ .Title | yamlString }}
+date: {{ .Timestamp }}
+draft: false
+{{- if .Article.Summary }}
+description: {{ .Article.Summary | yamlString }}
+summary: {{ .Article.Summary | yamlString }}
+{{- end }}
+{{- with .Article.Stats }}
+wordCount: {{ .Words }}
+sentenceCount: {{ .Sentences }}
+readingTime: {{ .ReadingTime }}
+readabilityGrade: {{ .FleschKincaidGrade }}
+codeBlockRatio: {{ .CodeBlockRatio }}
+{{- end }}
+{{- with .Article.Images }}
+images:
+  {{- range $img := . }}
+  - {{ $img.URL | yamlString }}
+  {{- end }}
+{{- end }}
+{{- with .Article.Repo }}
+repo:
+  forge: {{ .Forge | yamlString }}
+  path: {{ .Path | yamlString }}
+  url: {{ .URL | yamlString }}
+  stars: {{ .Stars }}
+  forks: {{ .Forks }}
+  {{- with .Language }}
+  language: {{ . | yamlString }}
+  {{- end }}
+  {{- with .License }}
+  license: {{ . | yamlString }}
+  {{- end }}
+  {{- with .LastCommit }}
+  lastCommit: {{ .Format "2006-01-02T15:04:05Z07:00" | yamlString }}
+  {{- end }}
+  {{- with .Topics }}
+  topics:
+    {{- range $topic := . }}
+    - {{ $topic | yamlString }}
+    {{- end }}
+  {{- end }}
+{{- end }}
+{{- with .Article.Citation }}
+citation:
+  title: {{ .Title | yamlString }}
+  {{- with .Authors }}
+  authors:
+    {{- range $author := . }}
+    - {{ $author | yamlString }}
+    {{- end }}
+  {{- end }}
+  {{- if .Year }}
+  year: {{ .Year }}
+  {{- end }}
+  {{- with .Venue }}
+  venue: {{ . | yamlString }}
+  {{- end }}
+  {{- with .DOI }}
+  doi: {{ . | yamlString }}
+  {{- end }}
+  {{- with .ArXivID }}
+  arxiv: {{ . | yamlString }}
+  {{- end }}
+  {{- with .Categories }}
+  categories:
+    {{- range $category := . }}
+    - {{ $category | yamlString }}
+    {{- end }}
+  {{- end }}
+{{- end }}
+{{- with .Status }}
+hnStatus: {{ . }}
+{{- end }}
+{{- with .ScoreHistory }}
+scoreHistory:
+  {{- range $sample := . }}
+  - {time: {{ $sample.Time.Format "2006-01-02T15:04:05Z07:00" | yamlString }}, points: {{ $sample.Points }}, comments: {{ $sample.Comments }}}
+  {{- end }}
+{{- end }}
+{{- with .Extensions }}
+extensions:
+  {{- range $name, $raw := . }}
+  {{ $name | yamlString }}: {{ compactJSON $raw }}
+  {{- end }}
+{{- end }}
+{{- if gt (len $top3Cleaned) 0 }}
+tags:
+  {{- range $ne := $top3Cleaned }}
+  - {{ $ne.Stemmed | yamlString }}
+  {{- end }}
+{{- end }}
+---
+
+ID: {{ .ID }}
+{{- with .DiscussionURL | mdURL }}
+|
+[Discussion on {{ $.SourceName }}]({{ . }}){{ if $.Scored }} ({{ $.CurrentPoints }} point{{ if ne $.CurrentPoints 1 }}s{{ end }}, {{ $.CurrentComments }} comment{{ if ne $.CurrentComments 1 }}s{{ end }}{{ with $.Status }}, {{ . }}{{ end }}){{ end }}
+{{- end }}
+|
+[Original Source]({{ .CanonicalURL | mdURL }})
+{{- if .Submitter }}
+|
+Submitted by: {{ with .SubmitterURL | mdURL }}[{{ $.Submitter | mdEscape }}]({{ . }}){{ else }}{{ .Submitter | mdEscape }}{{ end }}
+{{- end }}
+|
+Archives:
+[archive.is](https://archive.is/{{ .URL | mdURL }})
+[archive.org](https://web.archive.org/web/*/{{ .URL | mdURL }})
+{{- range $snap := .LocalSnapshots }}
+[snapshot ({{ $snap.Format | mdEscape }})]({{ $snap.URL | mdURL }})
+{{- end }}
+{{- with .Article.Repo }}
+
+Repository: [{{ .Path | mdEscape }}]({{ .URL | mdURL }}) ({{ .Stars }} star{{ if ne .Stars 1 }}s{{ end }}{{ with .Language }}, {{ . | mdEscape }}{{ end }}{{ with .License }}, {{ . | mdEscape }} license{{ end }}{{ with .LastCommit }}, last commit {{ .Format "2006-01-02" }}{{ end }}{{ if .Archived }}, archived{{ end }})
+{{- end }}
+{{- if gt (len .Discussions) 1 }}
+
+Discussions:
+{{ range $story := .Discussions }}
+* {{ with $story.DiscussionURL | mdURL }}[{{ $story.Title | mdEscape }}]({{ . }}){{ else }}{{ $story.Title | mdEscape }}{{ end }} on {{ $story.SourceName }}
+{{- if $story.Submitter }} by {{ with $story.SubmitterURL | mdURL }}[{{ $story.Submitter | mdEscape }}]({{ . }}){{ else }}{{ $story.Submitter | mdEscape }}{{ end }}{{ end }}
+{{- if $story.Scored }} ({{ $story.Points }} point{{ if ne $story.Points 1 }}s{{ end }}, {{ $story.Comments }} comment{{ if ne $story.Comments 1 }}s{{ end }}){{ end }}
+{{- end }}
+{{- end }}
+
+{{ if gt (len $cleaned) 0 -}}
+Tags: {{ range $i, $ne := topNEnts $cleaned 10 }}{{ if gt $i 0 }}, {{ end }}[{{ $ne.Entity | mdEscape }}](/tags/{{ $ne.Stemmed }}){{ end }}
+{{- end }}
+
+{{ if .Article.Markdown }}{{ .Article.Markdown | shortcodeSafe }}{{ else }}{{ .Article.CleanedText | mdEscape }}{{ end }}
+{{- with .Article.Citation }}
+
+## Citation
+
+` + "```" + `bibtex
+{{ .BibTeX | shortcodeSafe }}
+` + "```" + `
+{{- end }}
+{{- $comments := topComments .Thread 5 }}
+{{- if gt (len $comments) 0 }}
+
+## Top comments
+{{- range $comment := $comments }}
+
+**[{{ $comment.By | mdEscape }}](https://news.ycombinator.com/user?id={{ $comment.By | urlquery }})** on [{{ $comment.Time.Format "2006-01-02" }}]({{ $comment.URL | mdURL }}){{ if $comment.Descendants }} ({{ $comment.Descendants }} repl{{ if eq $comment.Descendants 1 }}y{{ else }}ies{{ end }}){{ end }}:
+
+{{ $comment.Markdown | blockquote | shortcodeSafe }}
+{{- end }}
+{{- end }}
+{{- $related := relatedStories . 5 }}
+{{- if gt (len $related) 0 }}
+
+## Related
+
+{{ range $match := $related -}}
+* [{{ $match.Context.Title | mdEscape }}]({{ "{{" }}< relref "{{ $match.Context.ID }}.md" >{{ "}}" }})
+{{ end }}
+{{- end }}
+`))
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	goose "jaytaylor.com/GoOse"
+	"jaytaylor.com/circus/domain"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// renderFixture is a hostile story and article, kept independent of the
+// GoOse JSON field names.
+type renderFixture struct {
+	Story    domain.Story
+	Text     string
+	Markdown string
+	Summary  string
+}
+
+var (
+	// unescapedLinkExpr matches a Markdown link to a non-http destination.
+	unescapedLinkExpr = regexp.MustCompile(`(?:^|[^\\])\]\(\s*(?:[a-z]+script|data):`)
+
+	shortcodeExpr = regexp.MustCompile(`\{\{[<%]|[>%]\}\}`)
+)
+
+func TestRenderGolden(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("testdata", "render", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) == 0 {
+		t.Fatal("no render fixtures found")
+	}
+
+	for _, filename := range filenames {
+		name := strings.TrimSuffix(filepath.Base(filename), ".json")
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixture := &renderFixture{}
+		if err := json.Unmarshal(data, fixture); err != nil {
+			t.Fatalf("%v: %s", name, err)
+		}
+		context := &domain.Context{
+			Story: &fixture.Story,
+			Article: &domain.Article{
+				Article:  &goose.Article{Title: fixture.Story.Title, CleanedText: fixture.Text},
+				Markdown: fixture.Markdown,
+				Summary:  fixture.Summary,
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		if err := mdTemplate.Execute(buf, context); err != nil {
+			t.Fatalf("%v: executing template: %s", name, err)
+		}
+		rendered := buf.String()
+
+		golden := filepath.Join("testdata", "render", name+".md")
+		if *update {
+			if err := ioutil.WriteFile(golden, buf.Bytes(), os.FileMode(int(0644))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v: %s (run with -update to create)", name, err)
+		}
+		if rendered != string(expected) {
+			t.Errorf("%v: rendered post differs from %v:\n%v", name, golden, rendered)
+		}
+
+		// Hugo neither renders nor expands shortcodes in front matter, so
+		// only the body is checked for them.
+		body := checkFrontMatter(t, name, rendered, context)
+		if m := shortcodeExpr.FindString(body); m != "" {
+			t.Errorf("%v: unescaped shortcode delimiter %q in rendered post", name, m)
+		}
+		if m := unescapedLinkExpr.FindString(body); m != "" {
+			t.Errorf("%v: injected link %q in rendered post", name, m)
+		}
+	}
+}
+
+// checkFrontMatter verifies the front matter is a single well-formed YAML
+// document which round-trips the hostile fields, and returns the post body.
+func checkFrontMatter(t *testing.T, name string, rendered string, context *domain.Context) string {
+	parts := strings.SplitN(rendered, "---\n", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		t.Errorf("%v: expected post to start with a --- delimited front matter block", name)
+		return rendered
+	}
+	fm := struct {
+		Title   string `yaml:"title"`
+		Draft   bool   `yaml:"draft"`
+		Summary string `yaml:"summary"`
+	}{}
+	if err := yaml.UnmarshalStrict([]byte(parts[1]), &map[string]interface{}{}); err != nil {
+		t.Errorf("%v: front matter is not valid YAML: %s", name, err)
+		return parts[2]
+	}
+	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
+		t.Errorf("%v: front matter is not valid YAML: %s", name, err)
+		return parts[2]
+	}
+	if fm.Title != context.Title {
+		t.Errorf("%v: front matter title = %q, expected %q", name, fm.Title, context.Title)
+	}
+	if fm.Summary != context.Article.Summary {
+		t.Errorf("%v: front matter summary = %q, expected %q", name, fm.Summary, context.Article.Summary)
+	}
+	if fm.Draft {
+		t.Errorf("%v: front matter draft overridden", name)
+	}
+	return parts[2]
+}
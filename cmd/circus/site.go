@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"jaytaylor.com/circus/pkg/config"
+)
+
+var (
+	HugoDir   string
+	SrcDir    string
+	OutputDir string
+	Fast      bool
+)
+
+// siteSettings binds the site flags to their config file settings.
+var siteSettings = map[string]string{
+	"hugo-dir":   "site.hugoDir",
+	"src-dir":    "site.srcDir",
+	"output-dir": "site.outputDir",
+	"limit":      "site.limit",
+}
+
+func init() {
+	addSiteFlags(siteCmd.Flags())
+	rootCmd.AddCommand(siteCmd)
+	commandSettings[siteCmd] = siteSettings
+}
+
+// addSiteFlags registers the site generation flags, which are shared by the
+// site and watch commands.
+func addSiteFlags(flags *pflag.FlagSet) {
+	defaults := config.Default().Site
+	addRenderFlags(flags)
+	flags.StringVarP(&HugoDir, "hugo-dir", "b", defaults.HugoDir, "Hugo base directory")
+	flags.StringVarP(&SrcDir, "src-dir", "s", defaults.SrcDir, "Directory of hydrated story JSON files")
+	flags.StringVarP(&OutputDir, "output-dir", "o", defaults.OutputDir, "Directory to publish the generated site to, replacing its previous contents")
+	flags.BoolVarP(&Fast, "fast", "f", false, "Skip rendering posts and rebuild the site from the existing (possibly stale) ones")
+}
+
+var siteCmd = &cobra.Command{
+	Use:    "site",
+	Short:  "Renders stories to posts, builds the Hugo site and publishes it",
+	Long:   "Renders every story in the source directory into the Hugo content directory, runs hugo, then swaps the freshly built site into the output directory.",
+	Args:   cobra.NoArgs,
+	PreRun: sitePreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generateSite(); err != nil {
+			errorExit(err)
+		}
+	},
+}
+
+// sitePreRun applies the site post limit, which unlike the render command's
+// defaults to site.limit.
+func sitePreRun(cmd *cobra.Command, _ []string) {
+	if !cmd.Flags().Changed("limit") {
+		PostLimit = cfg.Site.Limit
+	}
+}
+
+// generateSite renders the posts, builds the site and publishes it.
+func generateSite() error {
+	if HugoDir == "" {
+		return errors.New("missing required setting: --hugo-dir or site.hugoDir")
+	}
+	if OutputDir == "" {
+		return errors.New("missing required setting: --output-dir or site.outputDir")
+	}
+	if SrcDir == "" && !Fast {
+		return errors.New("missing required setting: --src-dir or site.srcDir")
+	}
+	log.WithField("hugo-dir", HugoDir).WithField("src-dir", SrcDir).WithField("output-dir", OutputDir).WithField("limit", PostLimit).WithField("fast", Fast).Debug("Generating site")
+
+	if !Fast {
+		postsDir := filepath.Join(HugoDir, "content", "posts")
+		if err := os.RemoveAll(postsDir); err != nil {
+			return fmt.Errorf("removing old posts: %s", err)
+		}
+		if err := renderPosts(SrcDir, postsDir); err != nil {
+			return fmt.Errorf("rendering posts: %s", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(postsDir, "_index.md"), []byte("Posts\n"), os.FileMode(int(0644))); err != nil {
+			return fmt.Errorf("writing posts index: %s", err)
+		}
+	}
+
+	publicDir := filepath.Join(HugoDir, "public")
+	if err := os.RemoveAll(publicDir); err != nil {
+		return fmt.Errorf("removing old hugo output: %s", err)
+	}
+	hugo := exec.Command("hugo")
+	hugo.Dir = HugoDir
+	hugo.Stdout = os.Stderr
+	hugo.Stderr = os.Stderr
+	if err := hugo.Run(); err != nil {
+		return fmt.Errorf("running hugo: %s", err)
+	}
+
+	return publish(publicDir, OutputDir)
+}
+
+// publish replaces outputDir with the built site in publicDir, keeping the
+// previous site aside until the new one is in place.
+func publish(publicDir string, outputDir string) error {
+	err := filepath.Walk(publicDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.Chmod(path, os.FileMode(int(0755)))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("setting site directory permissions: %s", err)
+	}
+
+	old := outputDir + ".old"
+	if _, err := os.Stat(outputDir); err == nil {
+		if err := os.RemoveAll(old); err != nil {
+			return fmt.Errorf("removing previous site backup: %s", err)
+		}
+		if err := os.Rename(outputDir, old); err != nil {
+			return fmt.Errorf("moving previous site aside: %s", err)
+		}
+	}
+	if err := move(publicDir, outputDir); err != nil {
+		return fmt.Errorf("publishing site: %s", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return fmt.Errorf("removing previous site: %s", err)
+	}
+	log.WithField("output-dir", outputDir).Info("Published site")
+	return nil
+}
+
+// move renames src to dst, falling back to mv(1) when they are on different
+// filesystems.
+func move(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if out, err := exec.Command("mv", src, dst).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/config"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/sources"
+)
+
+var (
+	StoriesLimit int
+	LobstersURL  string
+	PinboardUser string
+)
+
+func init() {
+	storiesCmd.Flags().IntVarP(&StoriesLimit, "limit", "l", -1, "Limit output to the first N stories")
+	storiesCmd.Flags().StringVarP(&HNAPI, "hn-api", "", hnapi.DefaultBaseURL, "Base URL of the HN Firebase API (hn-api source)")
+	storiesCmd.Flags().StringVarP(&LobstersURL, "lobsters-url", "", sources.LobstersBaseURL, "Base URL of the Lobsters site (lobsters source)")
+	storiesCmd.Flags().StringVarP(&PinboardUser, "pinboard-user", "", "", "Owner of the bookmarks, for submitter links (pinboard source)")
+	rootCmd.AddCommand(storiesCmd)
+	commandSettings[storiesCmd] = map[string]string{"hn-api": "hn.api"}
+}
+
+var storiesCmd = &cobra.Command{
+	Use:   "stories [source] [location]",
+	Short: "Emits the stories from a source as a JSON array suitable for bulk",
+	Long: fmt.Sprintf(`Sources: %v
+
+  hn        hn-favorites / hn-upvotes JSON file
+  hn-api    HN API list name, e.g. "topstories" or "beststories:30" for the first 30
+  lobsters  listing path such as "hottest" or "t/go", or a JSON file or URL
+  reddit    saved-posts JSON feed URL or exported listing file
+  pinboard  JSON export file, or the posts/all API URL
+  feed      RSS or Atom feed file or URL
+  urls      text file with one URL per line
+
+  bookmarks-html  Netscape bookmark file exported by a browser or service
+  firefox         Firefox bookmark backup JSON
+  chrome          Chrome profile Bookmarks JSON file
+  pocket          Pocket export, HTML or CSV
+  instapaper      Instapaper CSV export
+
+Locations may be "-" to read from stdin.`, strings.Join(sources.Names(), ", ")),
+	Args: cobra.ExactArgs(2),
+	PreRun: func(cmd *cobra.Command, _ []string) {
+		if cfg.Source("fetch.userAgent") != config.SourceDefault {
+			sources.UserAgent = cfg.Fetch.UserAgent
+		}
+		sources.LobstersBaseURL = LobstersURL
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		source, err := sources.New(args[0], args[1])
+		if err != nil {
+			errorExit(err)
+		}
+		switch s := source.(type) {
+		case *sources.HNAPI:
+			s.Client.BaseURL = HNAPI
+		case *sources.Pinboard:
+			s.User = PinboardUser
+		}
+
+		stories, err := source.Stories()
+		if err != nil {
+			errorExit(fmt.Errorf("reading %v stories: %s", source.Name(), err))
+		}
+		if StoriesLimit > -1 && len(stories) > StoriesLimit {
+			stories = stories[0:StoriesLimit]
+		}
+		log.WithField("source", source.Name()).WithField("stories", len(stories)).Info("Read stories")
+
+		if stories == nil {
+			stories = []*domain.Story{}
+		}
+		bs, err := json.MarshalIndent(stories, "", "    ")
+		if err != nil {
+			errorExit(fmt.Errorf("serializing stories: %s", err))
+		}
+		fmt.Println(string(bs))
+	},
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	WatchDirs     []string
+	WatchInterval time.Duration
+	WatchSettle   time.Duration
+)
+
+func init() {
+	addSiteFlags(watchCmd.Flags())
+	watchCmd.Flags().StringSliceVarP(&WatchDirs, "watch", "w", nil, "Directories to watch for changes (default: the hugo themes directory)")
+	watchCmd.Flags().DurationVarP(&WatchInterval, "interval", "i", time.Second, "How often to poll the watched directories")
+	watchCmd.Flags().DurationVarP(&WatchSettle, "settle", "", 2*time.Second, "Minimum time between site rebuilds, so bursts of saves trigger only one")
+	rootCmd.AddCommand(watchCmd)
+	commandSettings[watchCmd] = siteSettings
+}
+
+var watchCmd = &cobra.Command{
+	Use:    "watch",
+	Short:  "Regenerates the site whenever its theme changes",
+	Long:   "Polls the watched directories and reruns site generation after files are added, removed or modified.  Polling works the same on every platform and filesystem, including network and container mounts.",
+	Args:   cobra.NoArgs,
+	PreRun: sitePreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(WatchDirs) == 0 {
+			WatchDirs = []string{filepath.Join(HugoDir, "themes")}
+		}
+		watch()
+	},
+}
+
+// watch polls WatchDirs until interrupted, regenerating the site on change.
+func watch() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	var (
+		last    = scanTree(WatchDirs)
+		lastRun time.Time
+		pending bool
+	)
+	log.WithField("dirs", WatchDirs).Info("Watching for changes")
+	for {
+		select {
+		case <-sig:
+			log.Info("Stopped watching")
+			return
+		case <-ticker.C:
+		}
+
+		current := scanTree(WatchDirs)
+		if !sameTree(last, current) {
+			log.Debug("Change detected")
+			pending = true
+		}
+		last = current
+
+		if !pending || time.Since(lastRun) < WatchSettle {
+			continue
+		}
+		pending = false
+		lastRun = time.Now()
+		if err := generateSite(); err != nil {
+			log.Errorf("Generating site: %s", err)
+		}
+	}
+}
+
+// fileState is what a poll compares to detect a changed file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// scanTree records the state of every file and directory under dirs.
+// Unreadable paths are logged and left out.
+func scanTree(dirs []string) map[string]fileState {
+	tree := map[string]fileState{}
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				log.WithField("path", path).Debugf("Skipping unreadable path: %s", err)
+				return nil
+			}
+			tree[path] = fileState{
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			}
+			return nil
+		})
+		if err != nil {
+			log.WithField("dir", dir).Warnf("Scanning watched directory: %s", err)
+		}
+	}
+	return tree
+}
+
+func sameTree(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,9 +1,16 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
+
 	goose "jaytaylor.com/GoOse"
 	archiveis "jaytaylor.com/archive.is"
-	hn "jaytaylor.com/hn-utils/domain"
+	"jaytaylor.com/circus/pkg/academic"
+	"jaytaylor.com/circus/pkg/assets"
+	"jaytaylor.com/circus/pkg/forge"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/textstats"
 )
 
 // Article wraps goose.Article with extra attributes specific to the circus show.
@@ -11,12 +18,100 @@ import (
 type Article struct {
 	*goose.Article
 
-	NamedEntities NamedEntities `json:"namedEntities"`
+	NamedEntities NamedEntities    `json:"namedEntities"`
+	Markdown      string           `json:"markdown,omitempty"`     // Structured body converted from the extracted top node.
+	Summary       string           `json:"summary,omitempty"`      // Extractive summary of CleanedText.
+	KeySentences  []string         `json:"keySentences,omitempty"` // Highest ranked sentences, best first.
+	Stats         *textstats.Stats `json:"stats,omitempty"`
+	Images        []*assets.Image  `json:"images,omitempty"`   // Locally mirrored copies of the top and inline images.
+	Repo          *forge.Repo      `json:"repo,omitempty"`     // Set when the story links to a code repository.
+	Citation      *academic.Paper  `json:"citation,omitempty"` // Set when the story links to an academic paper.
 }
 
 // Context holds an entire story context, including metadata.
 type Context struct {
-	*hn.Story
+	*Story
 	Article   *Article             `json:"Goose"`
 	ArchiveIs []archiveis.Snapshot `json:"Archiveis"`
+	URLs      *URLs                `json:"URLs,omitempty"`
+
+	// LocalSnapshots are our own preserved copies of the page, made while
+	// hydrating.
+	LocalSnapshots []*LocalSnapshot `json:"LocalSnapshots,omitempty"`
+
+	// Thread is the story's discussion, when captured, and ThreadEntities the
+	// named entities found across all of its comments.
+	Thread         []*hnapi.Comment `json:"Thread,omitempty"`
+	ThreadEntities NamedEntities    `json:"ThreadEntities,omitempty"`
+
+	// ScoreHistory records the story's points and comment count each time
+	// its HN metadata was refreshed, oldest first.
+	ScoreHistory []*ScoreSample `json:"ScoreHistory,omitempty"`
+
+	// Discussions lists every submission of the same article, this story's
+	// own first, when duplicates have been detected.
+	Discussions []*Story `json:"Discussions,omitempty"`
+
+	// Extensions holds annotations added by pipeline stages, keyed by stage
+	// name.
+	Extensions map[string]json.RawMessage `json:"Extensions,omitempty"`
+}
+
+// SetExtension stores v, marshalled to JSON, as the extension named name.
+func (c *Context) SetExtension(name string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshalling %v extension: %s", name, err)
+	}
+	if c.Extensions == nil {
+		c.Extensions = map[string]json.RawMessage{}
+	}
+	c.Extensions[name] = json.RawMessage(bs)
+	return nil
+}
+
+// Extension unmarshals the extension named name into v.  False is returned
+// when there is no such extension.
+func (c *Context) Extension(name string, v interface{}) (bool, error) {
+	raw, ok := c.Extensions[name]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("unmarshalling %v extension: %s", name, err)
+	}
+	return true, nil
+}
+
+// CanonicalURL returns the canonical form of the story URL when one was
+// resolved during hydration, otherwise the URL as submitted.
+func (c *Context) CanonicalURL() string {
+	if c.URLs != nil && c.URLs.Canonical != "" {
+		return c.URLs.Canonical
+	}
+	if c.Story == nil {
+		return ""
+	}
+	return c.Story.URL
+}
+
+// URLs records how a story URL was resolved while fetching it.
+type URLs struct {
+	Original  string   `json:"original"`            // As submitted.
+	Final     string   `json:"final,omitempty"`     // After following all redirects.
+	Canonical string   `json:"canonical,omitempty"` // Preferred form for display and de-duplication.
+	Redirects []string `json:"redirects,omitempty"` // Each hop after the original, in order.
+}
+
+// Local snapshot formats.
+const (
+	SnapshotHTML = "html" // Single-file HTML with inlined stylesheets and images.
+	SnapshotWARC = "warc" // Gzipped WARC of the raw HTTP exchanges.
+)
+
+// LocalSnapshot locates a preserved copy of a story page.
+type LocalSnapshot struct {
+	Format string `json:"format"`
+	Path   string `json:"path"` // Relative to the snapshot directory.
+	URL    string `json:"url"`  // Public URL under which the snapshot is served.
 }
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+)
+
+// FailureKind categorizes the reason hydration of a story failed.
+type FailureKind string
+
+const (
+	FailureFetch           FailureKind = "fetch"            // Network or transport level error.
+	FailureHTTPStatus      FailureKind = "http-status"      // Non-2xx response with no usable fallback.
+	FailureArchiveFallback FailureKind = "archive-fallback" // Archive.is fallback search or download failed.
+	FailurePDFConvert      FailureKind = "pdf-convert"      // PDF download or conversion to HTML failed.
+	FailureExtractEmpty    FailureKind = "extract-empty"    // No article content could be extracted.
+	FailureNER             FailureKind = "ner"              // Named-entity recognition sidecar failed.
+	FailureStage           FailureKind = "stage"            // A pipeline stage failed.
+	FailureInternal        FailureKind = "internal"         // Anything else.
+)
+
+// Failure is a machine-readable record of a failed hydration attempt.  Batch
+// tooling stores these next to successfully hydrated Context files.
+type Failure struct {
+	Kind       FailureKind `json:"kind"`
+	URL        string      `json:"url"`
+	Message    string      `json:"message"`
+	StatusCode int         `json:"statusCode,omitempty"` // HTTP status code, when applicable.
+	ExitCode   int         `json:"exitCode"`
+	Timestamp  time.Time   `json:"timestamp"`
+}
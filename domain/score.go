@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"time"
+)
+
+// ScoreSample is a point-in-time observation of a story's HN metadata.
+type ScoreSample struct {
+	Time     time.Time `json:"time"`
+	Points   int       `json:"points"`
+	Comments int       `json:"comments"`
+	Dead     bool      `json:"dead,omitempty"`    // Killed by flags or moderators.
+	Deleted  bool      `json:"deleted,omitempty"` // Removed by the submitter.
+}
+
+// LatestSample returns the most recent score sample, or nil when the story
+// has never been refreshed.
+func (c *Context) LatestSample() *ScoreSample {
+	if len(c.ScoreHistory) == 0 {
+		return nil
+	}
+	return c.ScoreHistory[len(c.ScoreHistory)-1]
+}
+
+// CurrentPoints returns the most recently observed points.
+func (c *Context) CurrentPoints() int {
+	if s := c.LatestSample(); s != nil {
+		return s.Points
+	}
+	if c.Story == nil {
+		return 0
+	}
+	return c.Story.Points
+}
+
+// CurrentComments returns the most recently observed comment count.
+func (c *Context) CurrentComments() int {
+	if s := c.LatestSample(); s != nil {
+		return s.Comments
+	}
+	if c.Story == nil {
+		return 0
+	}
+	return c.Story.Comments
+}
+
+// Status is "dead" or "deleted" when the latest refresh found the story
+// removed from HN, otherwise "".
+func (c *Context) Status() string {
+	s := c.LatestSample()
+	switch {
+	case s == nil:
+		return ""
+	case s.Deleted:
+		return "deleted"
+	case s.Dead:
+		return "dead"
+	}
+	return ""
+}
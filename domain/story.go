@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Story sources.
+const (
+	SourceHN         = "hn"
+	SourceLobsters   = "lobsters"
+	SourceReddit     = "reddit"
+	SourcePinboard   = "pinboard"
+	SourceFeed       = "feed"
+	SourceURLs       = "urls"
+	SourceBookmarks  = "bookmarks" // Browser bookmarks.
+	SourcePocket     = "pocket"
+	SourceInstapaper = "instapaper"
+	SourceBrowser    = "browser" // Pages pushed from the browser.
+)
+
+// Story is a link submitted, saved or bookmarked somewhere, along with
+// whatever discussion metadata its source provides.  The JSON field names
+// match those of hn-utils stories so existing HN data loads unchanged; stories
+// without a Source are from HN.
+type Story struct {
+	ID        StoryID  `json:"ID"`
+	Source    string   `json:"Source,omitempty"`
+	Title     string   `json:"Title"`
+	URL       string   `json:"URL"`
+	Submitter string   `json:"Submitter,omitempty"`
+	Points    int      `json:"Points"`
+	Comments  int      `json:"Comments"`
+	Timestamp Time     `json:"Timestamp"`
+	Tags      []string `json:"Tags,omitempty"`
+
+	// Permalink is the discussion page, for sources where it cannot be
+	// derived from the ID.
+	Permalink string `json:"Permalink,omitempty"`
+}
+
+// SourceKind returns the story's source, defaulting to HN.
+func (s *Story) SourceKind() string {
+	if s.Source == "" {
+		return SourceHN
+	}
+	return s.Source
+}
+
+// SourceName returns a human readable name for the story's source.
+func (s *Story) SourceName() string {
+	switch s.SourceKind() {
+	case SourceHN:
+		return "Hacker News"
+	case SourceLobsters:
+		return "Lobsters"
+	case SourceReddit:
+		return "Reddit"
+	case SourcePinboard:
+		return "Pinboard"
+	case SourceFeed:
+		return "Feed"
+	case SourceURLs:
+		return "URL list"
+	case SourceBookmarks:
+		return "Bookmarks"
+	case SourcePocket:
+		return "Pocket"
+	case SourceInstapaper:
+		return "Instapaper"
+	case SourceBrowser:
+		return "Browser"
+	}
+	return s.Source
+}
+
+// Scored reports whether the source tracks points and comment counts.
+func (s *Story) Scored() bool {
+	switch s.SourceKind() {
+	case SourceHN, SourceLobsters, SourceReddit:
+		return true
+	}
+	return false
+}
+
+// DiscussionURL returns the story's discussion page, or "" when the source
+// has none.
+func (s *Story) DiscussionURL() string {
+	if s.Permalink != "" {
+		return s.Permalink
+	}
+	switch s.SourceKind() {
+	case SourceHN:
+		return fmt.Sprintf("https://news.ycombinator.com/item?id=%v", s.ID)
+	case SourceLobsters:
+		return fmt.Sprintf("https://lobste.rs/s/%v", url.PathEscape(s.ID.String()))
+	}
+	return ""
+}
+
+// CommentsURL is an alias of DiscussionURL, kept for templates written
+// against hn-utils stories.
+func (s *Story) CommentsURL() string {
+	return s.DiscussionURL()
+}
+
+// SubmitterURL returns the submitter's profile page, or "" when unknown.
+func (s *Story) SubmitterURL() string {
+	if s.Submitter == "" {
+		return ""
+	}
+	user := url.PathEscape(s.Submitter)
+	switch s.SourceKind() {
+	case SourceHN:
+		return "https://news.ycombinator.com/user?id=" + url.QueryEscape(s.Submitter)
+	case SourceLobsters:
+		return "https://lobste.rs/u/" + user
+	case SourceReddit:
+		return "https://www.reddit.com/user/" + user
+	case SourcePinboard:
+		return "https://pinboard.in/u:" + user
+	}
+	return ""
+}
+
+// HashStoryID derives a stable, filename-safe story ID from key, for sources
+// without IDs of their own.
+func HashStoryID(source string, key string) StoryID {
+	sum := sha1.Sum([]byte(key))
+	return StoryID(fmt.Sprintf("%v-%x", source, sum[0:6]))
+}
+
+// StoryID identifies a story within its source.  HN IDs are numeric while
+// other sources use strings, so both JSON forms are accepted, and numeric IDs
+// are written back as numbers.  IDs which only parse as numbers, such as
+// "012345" or "+5", stay strings so that they survive a round trip.
+type StoryID string
+
+// String returns the ID.
+func (id StoryID) String() string {
+	return string(id)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (id StoryID) MarshalJSON() ([]byte, error) {
+	if n, err := strconv.ParseInt(string(id), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(id) {
+		return []byte(id), nil
+	}
+	return json.Marshal(string(id))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (id *StoryID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = StoryID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("story ID must be a string or number: %s", err)
+	}
+	*id = StoryID(n.String())
+	return nil
+}
+
+// Time is a story timestamp.  Stories written by hn-utils hold Unix seconds
+// rather than RFC 3339 strings, so both JSON forms are accepted; timestamps
+// are always written back as RFC 3339.
+type Time struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		return t.Time.UnmarshalJSON(data)
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("timestamp must be an RFC 3339 string or Unix seconds: %s", err)
+	}
+	secs, err := n.Int64()
+	if err != nil {
+		return fmt.Errorf("timestamp must be an RFC 3339 string or Unix seconds: %s", err)
+	}
+	t.Time = time.Unix(secs, 0).UTC()
+	return nil
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStoryUnmarshal(t *testing.T) {
+	expected := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		json string
+		id   StoryID
+		time time.Time
+	}{
+		{`{"ID":17205865,"Timestamp":1527854400}`, "17205865", expected},
+		{`{"ID":"17205865","Timestamp":"2018-06-01T12:00:00Z"}`, "17205865", expected},
+		{`{"ID":"lobsters-abc","Timestamp":"2018-06-01T14:00:00+02:00"}`, "lobsters-abc", expected},
+		{`{"ID":1,"Timestamp":null}`, "1", time.Time{}},
+		{`{"ID":1}`, "1", time.Time{}},
+	}
+	for i, testCase := range testCases {
+		story := &Story{}
+		if err := json.Unmarshal([]byte(testCase.json), story); err != nil {
+			t.Errorf("[%v] %s", i, err)
+			continue
+		}
+		if story.ID != testCase.id {
+			t.Errorf("[%v] expected ID %q, got %q", i, testCase.id, story.ID)
+		}
+		if !story.Timestamp.Equal(testCase.time) {
+			t.Errorf("[%v] expected timestamp %v, got %v", i, testCase.time, story.Timestamp)
+		}
+	}
+
+	for _, bad := range []string{`{"Timestamp":1.5}`, `{"Timestamp":"yesterday"}`, `{"Timestamp":true}`} {
+		if err := json.Unmarshal([]byte(bad), &Story{}); err == nil {
+			t.Errorf("expected an error unmarshalling %s", bad)
+		}
+	}
+}
+
+func TestStoryMarshal(t *testing.T) {
+	story := &Story{
+		ID:        "17205865",
+		Timestamp: Time{Time: time.Unix(1527854400, 0).UTC()},
+	}
+	bs, err := json.Marshal(story)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"ID":17205865,"Title":"","URL":"","Points":0,"Comments":0,"Timestamp":"2018-06-01T12:00:00Z"}`
+	if string(bs) != expected {
+		t.Errorf("expected %s, got %s", expected, string(bs))
+	}
+}
+
+func TestStoryIDMarshal(t *testing.T) {
+	testCases := []struct {
+		id       StoryID
+		expected string
+	}{
+		{"17205865", `17205865`},
+		{"-5", `-5`},
+		{"0", `0`},
+		{"012345", `"012345"`},
+		{"+5", `"+5"`},
+		{"-0", `"-0"`},
+		{"00", `"00"`},
+		{"99999999999999999999", `"99999999999999999999"`},
+		{"lobsters-abc", `"lobsters-abc"`},
+		{"", `""`},
+	}
+	for _, testCase := range testCases {
+		bs, err := json.Marshal(testCase.id)
+		if err != nil {
+			t.Errorf("%q: %s", testCase.id, err)
+			continue
+		}
+		if string(bs) != testCase.expected {
+			t.Errorf("%q: expected %s, got %s", testCase.id, testCase.expected, string(bs))
+		}
+		var roundTripped StoryID
+		if err := json.Unmarshal(bs, &roundTripped); err != nil || roundTripped != testCase.id {
+			t.Errorf("%q: round trip produced %q (err=%v)", testCase.id, roundTripped, err)
+		}
+	}
+
+	dctx := &Context{Story: &Story{ID: "012345"}}
+	if _, err := json.Marshal(dctx); err != nil {
+		t.Errorf("marshalling a context with a zero-padded ID: %s", err)
+	}
+}
+
+func TestContextUnmarshalUnixTimestamp(t *testing.T) {
+	dctx := &Context{}
+	if err := json.Unmarshal([]byte(`{"ID":42,"Title":"Story","Timestamp":1527854400,"ScoreHistory":[{"points":3}]}`), dctx); err != nil {
+		t.Fatal(err)
+	}
+	if dctx.Story == nil || dctx.Title != "Story" || dctx.Timestamp.Unix() != 1527854400 {
+		t.Errorf("unexpected story: %+v", dctx.Story)
+	}
+	if len(dctx.ScoreHistory) != 1 {
+		t.Errorf("expected context fields alongside the story, got %+v", dctx)
+	}
+}
@@ -0,0 +1,171 @@
+package academic
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ArXivBaseURL is the arXiv Atom API query endpoint.
+const ArXivBaseURL = "https://export.arxiv.org/api/query"
+
+var (
+	// arXivIDExpr matches new (1706.03762v7) and old (hep-th/9901001v1)
+	// style identifiers, capturing the version separately.
+	arXivIDExpr = regexp.MustCompile(`^((?:\d{4}\.\d{4,5})|(?:[a-z-]+(?:\.[A-Za-z]{2})?/\d{7}))(v\d+)?$`)
+
+	arXivHosts = map[string]struct{}{
+		"arxiv.org":        {},
+		"www.arxiv.org":    {},
+		"export.arxiv.org": {},
+	}
+)
+
+// ParseArXiv returns the version-less arXiv identifier of the paper url
+// refers to, for abstract, PDF and HTML pages as well as arXiv DOIs.  False
+// is returned for anything else.
+func ParseArXiv(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	var id string
+	if _, ok := arXivHosts[host]; ok {
+		path := strings.TrimPrefix(u.Path, "/")
+		i := strings.Index(path, "/")
+		if i < 0 {
+			return "", false
+		}
+		switch path[0:i] {
+		case "abs", "pdf", "html", "format":
+			id = strings.TrimSuffix(strings.TrimSuffix(path[i+1:], "/"), ".pdf")
+		default:
+			return "", false
+		}
+	} else if doi, ok := ParseDOI(rawURL); ok && strings.HasPrefix(strings.ToLower(doi), "10.48550/arxiv.") {
+		id = doi[len("10.48550/arxiv."):]
+	} else {
+		return "", false
+	}
+
+	m := arXivIDExpr.FindStringSubmatch(id)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// ArXivURL returns the abstract page of the paper with identifier id.
+func ArXivURL(id string) string {
+	return "https://arxiv.org/abs/" + id
+}
+
+// ArXiv is a client for the arXiv Atom API
+// (https://info.arxiv.org/help/api/).
+type ArXiv struct {
+	BaseURL    string       // Override to point at a local stand-in or mirror.
+	HTTPClient *http.Client // Defaults to http.DefaultClient.
+}
+
+// NewArXiv returns a client for the public arXiv API.
+func NewArXiv() *ArXiv {
+	a := &ArXiv{
+		BaseURL:    ArXivBaseURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	return a
+}
+
+type arXivFeed struct {
+	Entries []arXivEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type arXivEntry struct {
+	ID        string    `xml:"http://www.w3.org/2005/Atom id"`
+	Title     string    `xml:"http://www.w3.org/2005/Atom title"`
+	Summary   string    `xml:"http://www.w3.org/2005/Atom summary"`
+	Published time.Time `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   time.Time `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors   []struct {
+		Name string `xml:"http://www.w3.org/2005/Atom name"`
+	} `xml:"http://www.w3.org/2005/Atom author"`
+	Links []struct {
+		Href  string `xml:"href,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"http://www.w3.org/2005/Atom link"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"http://www.w3.org/2005/Atom category"`
+	PrimaryCategory struct {
+		Term string `xml:"term,attr"`
+	} `xml:"http://arxiv.org/schemas/atom primary_category"`
+	DOI        string `xml:"http://arxiv.org/schemas/atom doi"`
+	JournalRef string `xml:"http://arxiv.org/schemas/atom journal_ref"`
+}
+
+// Paper fetches the metadata of the paper with identifier id.
+func (a *ArXiv) Paper(ctx context.Context, id string) (*Paper, error) {
+	u := fmt.Sprintf("%v?id_list=%v&max_results=1", a.BaseURL, url.QueryEscape(id))
+	data, err := get(ctx, a.HTTPClient, u, "application/atom+xml")
+	if err != nil {
+		return nil, err
+	}
+	feed := &arXivFeed{}
+	if err := xml.Unmarshal(data, feed); err != nil {
+		return nil, fmt.Errorf("unmarshalling arxiv feed for %v: %s", id, err)
+	}
+	// Unknown identifiers produce an empty feed, or an "Error" entry.
+	if len(feed.Entries) == 0 || strings.Contains(feed.Entries[0].ID, "/api/errors") || feed.Entries[0].Title == "" {
+		return nil, errors.New("arxiv paper " + id + " not found")
+	}
+	entry := feed.Entries[0]
+
+	p := &Paper{
+		Type:     TypeMisc,
+		ArXivID:  id,
+		DOI:      strings.TrimSpace(entry.DOI),
+		Title:    collapse(entry.Title),
+		Abstract: collapse(entry.Summary),
+		Venue:    collapse(entry.JournalRef),
+		URL:      ArXivURL(id),
+	}
+	if i := strings.LastIndex(entry.ID, "/abs/"); i >= 0 {
+		if m := arXivIDExpr.FindStringSubmatch(entry.ID[i+len("/abs/"):]); m != nil {
+			p.Version = m[2]
+		}
+	}
+	if p.Venue != "" {
+		p.Type = TypeArticle
+	}
+	for _, author := range entry.Authors {
+		p.Authors = append(p.Authors, collapse(author.Name))
+	}
+	if primary := entry.PrimaryCategory.Term; primary != "" {
+		p.Categories = append(p.Categories, primary)
+	}
+	for _, category := range entry.Categories {
+		if category.Term != "" && category.Term != entry.PrimaryCategory.Term {
+			p.Categories = append(p.Categories, category.Term)
+		}
+	}
+	for _, link := range entry.Links {
+		if link.Title == "pdf" {
+			p.PDFURL = strings.Replace(link.Href, "http://", "https://", 1)
+		}
+	}
+	if !entry.Published.IsZero() {
+		p.Published = &entry.Published
+	}
+	if !entry.Updated.IsZero() {
+		p.Updated = &entry.Updated
+	}
+	return p, nil
+}
@@ -0,0 +1,108 @@
+package academic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseArXiv(t *testing.T) {
+	testCases := []struct {
+		url string
+		id  string
+		ok  bool
+	}{
+		{"https://arxiv.org/abs/1706.03762", "1706.03762", true},
+		{"https://arxiv.org/abs/1706.03762v7", "1706.03762", true},
+		{"http://www.arxiv.org/abs/1706.03762/", "1706.03762", true},
+		{"https://arxiv.org/pdf/1706.03762", "1706.03762", true},
+		{"https://arxiv.org/pdf/1706.03762v5.pdf", "1706.03762", true},
+		{"https://arxiv.org/html/2401.00001v2", "2401.00001", true},
+		{"https://export.arxiv.org/abs/0704.0001", "0704.0001", true},
+		{"https://arxiv.org/format/1706.03762", "1706.03762", true},
+		{"https://arxiv.org/abs/hep-th/9901001", "hep-th/9901001", true},
+		{"https://arxiv.org/abs/hep-th/9901001v1", "hep-th/9901001", true},
+		{"https://arxiv.org/pdf/math.GT/0309136v2.pdf", "math.GT/0309136", true},
+		{"https://doi.org/10.48550/arXiv.1706.03762", "1706.03762", true},
+		{"https://arxiv.org/list/cs.AI/recent", "", false},
+		{"https://arxiv.org/abs/", "", false},
+		{"https://arxiv.org/abs/not-an-id", "", false},
+		{"https://arxiv.org/abs/1706.037", "", false},
+		{"https://arxiv.org/", "", false},
+		{"https://example.com/abs/1706.03762", "", false},
+		{"https://doi.org/10.1145/3368089.3409741", "", false},
+	}
+	for _, testCase := range testCases {
+		id, ok := ParseArXiv(testCase.url)
+		if id != testCase.id || ok != testCase.ok {
+			t.Errorf("ParseArXiv(%q) = %q, %v, expected %q, %v", testCase.url, id, ok, testCase.id, testCase.ok)
+		}
+	}
+}
+
+const arXivFeedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:arxiv="http://arxiv.org/schemas/atom">
+  <entry>
+    <id>http://arxiv.org/abs/1706.03762v7</id>
+    <updated>2023-08-02T00:41:18Z</updated>
+    <published>2017-06-12T17:57:34Z</published>
+    <title>Attention Is All
+      You Need</title>
+    <summary>  The dominant sequence transduction models
+are based on complex recurrent networks.</summary>
+    <author><name>Ashish Vaswani</name></author>
+    <author><name>Noam  Shazeer</name></author>
+    <link href="http://arxiv.org/abs/1706.03762v7" rel="alternate" type="text/html"/>
+    <link title="pdf" href="http://arxiv.org/pdf/1706.03762v7" rel="related" type="application/pdf"/>
+    <arxiv:primary_category term="cs.CL" scheme="http://arxiv.org/schemas/atom"/>
+    <category term="cs.CL" scheme="http://arxiv.org/schemas/atom"/>
+    <category term="cs.LG" scheme="http://arxiv.org/schemas/atom"/>
+  </entry>
+</feed>`
+
+func TestArXivPaper(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("id_list") != "1706.03762" {
+			w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"></feed>`))
+			return
+		}
+		w.Write([]byte(arXivFeedXML))
+	}))
+	defer server.Close()
+
+	a := NewArXiv()
+	a.BaseURL = server.URL
+	p, err := a.Paper(context.Background(), "1706.03762")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "id_list=1706.03762&max_results=1"; query != expected {
+		t.Errorf("expected query %q, got %q", expected, query)
+	}
+	if p.Title != "Attention Is All You Need" || p.Version != "v7" || p.Type != TypeMisc {
+		t.Errorf("unexpected paper: %+v", p)
+	}
+	if expected := []string{"Ashish Vaswani", "Noam Shazeer"}; !reflect.DeepEqual(p.Authors, expected) {
+		t.Errorf("expected authors %v, got %v", expected, p.Authors)
+	}
+	if expected := []string{"cs.CL", "cs.LG"}; !reflect.DeepEqual(p.Categories, expected) {
+		t.Errorf("expected categories %v, got %v", expected, p.Categories)
+	}
+	if expected := "https://arxiv.org/pdf/1706.03762v7"; p.PDFURL != expected {
+		t.Errorf("expected PDF URL %q, got %q", expected, p.PDFURL)
+	}
+	if expected := "https://arxiv.org/abs/1706.03762"; p.URL != expected {
+		t.Errorf("expected URL %q, got %q", expected, p.URL)
+	}
+	if p.Published == nil || p.Published.Year() != 2017 {
+		t.Errorf("expected publication in 2017, got %v", p.Published)
+	}
+
+	if _, err := a.Paper(context.Background(), "9999.99999"); err == nil {
+		t.Errorf("expected an error for an unknown paper")
+	}
+}
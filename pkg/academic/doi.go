@@ -0,0 +1,184 @@
+package academic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DOIBaseURL is the DOI resolver, which serves citation metadata through
+// content negotiation.
+const DOIBaseURL = "https://doi.org"
+
+var (
+	doiExpr = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+
+	// jatsTagExpr matches the JATS XML markup found in abstracts.
+	jatsTagExpr = regexp.MustCompile(`<[^>]+>`)
+)
+
+// ParseDOI returns the DOI of the paper url refers to, for doi.org links and
+// ACM Digital Library abstract, full text and PDF pages.  False is returned
+// for anything else.
+func ParseDOI(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+
+	switch strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") {
+	case "doi.org", "dx.doi.org":
+	case "dl.acm.org":
+		// e.g. /doi/10.1145/3368089.3409741 or /doi/pdf/10.1145/3368089.3409741
+		if !strings.HasPrefix(path, "doi/") {
+			return "", false
+		}
+		path = path[len("doi/"):]
+		if i := strings.Index(path, "10."); i > 0 {
+			path = path[i:]
+		}
+	default:
+		return "", false
+	}
+
+	path = strings.TrimSuffix(path, "/")
+	if !doiExpr.MatchString(path) {
+		return "", false
+	}
+	return path, true
+}
+
+// DOIURL returns the resolver link of doi, lower-cased as DOIs are case
+// insensitive.
+func DOIURL(doi string) string {
+	return "https://doi.org/" + doiPath(strings.ToLower(doi))
+}
+
+// doiPath escapes each segment of doi for use in a URL path.  DOIs may
+// contain characters such as "?", "#" and "<" which are meaningful in URLs.
+func doiPath(doi string) string {
+	segments := strings.Split(doi, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// DOIResolver fetches citation metadata for DOIs as CSL JSON.
+type DOIResolver struct {
+	BaseURL    string       // Override to point at a local stand-in.
+	HTTPClient *http.Client // Defaults to http.DefaultClient.
+}
+
+// NewDOIResolver returns a resolver using doi.org.
+func NewDOIResolver() *DOIResolver {
+	d := &DOIResolver{
+		BaseURL:    DOIBaseURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	return d
+}
+
+// cslString is a CSL variable which may be given as a string or an array of
+// strings, of which the first is used.
+type cslString string
+
+func (s *cslString) UnmarshalJSON(data []byte) error {
+	var values []string
+	if err := json.Unmarshal(data, &values); err == nil {
+		if len(values) > 0 {
+			*s = cslString(values[0])
+		}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = cslString(value)
+	return nil
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func (d *cslDate) time() *time.Time {
+	if d == nil || len(d.DateParts) == 0 || len(d.DateParts[0]) == 0 {
+		return nil
+	}
+	parts := append(d.DateParts[0], 1, 1)
+	t := time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+type cslItem struct {
+	Type           string    `json:"type"`
+	DOI            string    `json:"DOI"`
+	URL            string    `json:"URL"`
+	Title          cslString `json:"title"`
+	ContainerTitle cslString `json:"container-title"`
+	Publisher      string    `json:"publisher"`
+	Abstract       string    `json:"abstract"`
+	Subject        []string  `json:"subject"`
+	Author         []struct {
+		Given   string `json:"given"`
+		Family  string `json:"family"`
+		Literal string `json:"literal"`
+	} `json:"author"`
+	Issued    *cslDate `json:"issued"`
+	Published *cslDate `json:"published"`
+}
+
+// Paper fetches the metadata of the paper with the given DOI.
+func (d *DOIResolver) Paper(ctx context.Context, doi string) (*Paper, error) {
+	u := fmt.Sprintf("%v/%v", strings.TrimRight(d.BaseURL, "/"), doiPath(doi))
+	data, err := get(ctx, d.HTTPClient, u, "application/vnd.citationstyles.csl+json")
+	if err != nil {
+		return nil, err
+	}
+	item := &cslItem{}
+	if err := json.Unmarshal(data, item); err != nil {
+		return nil, fmt.Errorf("unmarshalling citation metadata for %v: %s", doi, err)
+	}
+	if item.Title == "" {
+		return nil, fmt.Errorf("no citation metadata found for %v", doi)
+	}
+
+	p := &Paper{
+		DOI:        doi,
+		Title:      collapse(string(item.Title)),
+		Abstract:   collapse(jatsTagExpr.ReplaceAllString(item.Abstract, " ")),
+		Categories: item.Subject,
+		Venue:      collapse(string(item.ContainerTitle)),
+		Publisher:  item.Publisher,
+		URL:        DOIURL(doi),
+	}
+	switch item.Type {
+	case "journal-article":
+		p.Type = TypeArticle
+	case "paper-conference", "proceedings-article":
+		p.Type = TypeInProceedings
+	default:
+		p.Type = TypeMisc
+	}
+	for _, author := range item.Author {
+		name := author.Literal
+		if name == "" {
+			name = strings.TrimSpace(author.Given + " " + author.Family)
+		}
+		if name != "" {
+			p.Authors = append(p.Authors, name)
+		}
+	}
+	if p.Published = item.Issued.time(); p.Published == nil {
+		p.Published = item.Published.time()
+	}
+	return p, nil
+}
@@ -0,0 +1,103 @@
+package academic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseDOI(t *testing.T) {
+	testCases := []struct {
+		url string
+		doi string
+		ok  bool
+	}{
+		{"https://doi.org/10.1145/3368089.3409741", "10.1145/3368089.3409741", true},
+		{"http://dx.doi.org/10.1145/3368089.3409741/", "10.1145/3368089.3409741", true},
+		{"https://www.doi.org/10.1000/182", "10.1000/182", true},
+		{"https://doi.org/10.1002/(SICI)1097-4571(199806)49:8%3C693::AID-ASI4%3E3.0.CO;2-0", "10.1002/(SICI)1097-4571(199806)49:8<693::AID-ASI4>3.0.CO;2-0", true},
+		{"https://dl.acm.org/doi/10.1145/3368089.3409741", "10.1145/3368089.3409741", true},
+		{"https://dl.acm.org/doi/abs/10.1145/3368089.3409741", "10.1145/3368089.3409741", true},
+		{"https://dl.acm.org/doi/pdf/10.1145/3368089.3409741", "10.1145/3368089.3409741", true},
+		{"https://dl.acm.org/doi/fullHtml/10.1145/3368089.3409741", "10.1145/3368089.3409741", true},
+		{"https://dl.acm.org/doi/", "", false},
+		{"https://dl.acm.org/profile/81100", "", false},
+		{"https://doi.org/", "", false},
+		{"https://doi.org/11.1145/123", "", false},
+		{"https://doi.org/10.1/123", "", false},
+		{"https://example.com/10.1145/3368089.3409741", "", false},
+	}
+	for _, testCase := range testCases {
+		doi, ok := ParseDOI(testCase.url)
+		if doi != testCase.doi || ok != testCase.ok {
+			t.Errorf("ParseDOI(%q) = %q, %v, expected %q, %v", testCase.url, doi, ok, testCase.doi, testCase.ok)
+		}
+	}
+}
+
+func TestDOIURL(t *testing.T) {
+	testCases := []struct {
+		doi string
+		url string
+	}{
+		{"10.1145/3368089.3409741", "https://doi.org/10.1145/3368089.3409741"},
+		{"10.1000/ABC", "https://doi.org/10.1000/abc"},
+		{"10.1002/(SICI)1097-4571(199806)49:8<693::AID-ASI4>3.0.CO;2-0", "https://doi.org/10.1002/%28sici%291097-4571%28199806%2949:8%3C693::aid-asi4%3E3.0.co%3B2-0"},
+		{"10.1000/a?b#c", "https://doi.org/10.1000/a%3Fb%23c"},
+	}
+	for _, testCase := range testCases {
+		if actual := DOIURL(testCase.doi); actual != testCase.url {
+			t.Errorf("DOIURL(%q) = %q, expected %q", testCase.doi, actual, testCase.url)
+		}
+	}
+}
+
+func TestDOIResolverPaper(t *testing.T) {
+	const doi = "10.1000/odd?name#1"
+	var path, accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, accept = r.URL.EscapedPath(), r.Header.Get("Accept")
+		if r.URL.Path != "/"+doi {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"type": "proceedings-article",
+			"title": ["Some   Paper"],
+			"container-title": "Proceedings of Something",
+			"publisher": "ACM",
+			"abstract": "<jats:p>An <jats:italic>abstract</jats:italic>.</jats:p>",
+			"author": [{"given": "Ada", "family": "Lovelace"}, {"literal": "The Team"}],
+			"issued": {"date-parts": [[2020, 11]]}
+		}`))
+	}))
+	defer server.Close()
+
+	d := NewDOIResolver()
+	d.BaseURL = server.URL
+	p, err := d.Paper(context.Background(), doi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/10.1000/odd%3Fname%231"; path != expected {
+		t.Errorf("expected request path %q, got %q", expected, path)
+	}
+	if expected := "application/vnd.citationstyles.csl+json"; accept != expected {
+		t.Errorf("expected Accept %q, got %q", expected, accept)
+	}
+	if p.Title != "Some Paper" || p.Type != TypeInProceedings || p.Venue != "Proceedings of Something" || p.Abstract != "An abstract ." {
+		t.Errorf("unexpected paper: %+v", p)
+	}
+	if expected := []string{"Ada Lovelace", "The Team"}; !reflect.DeepEqual(p.Authors, expected) {
+		t.Errorf("expected authors %v, got %v", expected, p.Authors)
+	}
+	if p.Published == nil || p.Published.Year() != 2020 || p.Published.Month() != 11 {
+		t.Errorf("expected publication in November 2020, got %v", p.Published)
+	}
+
+	if _, err := d.Paper(context.Background(), "10.1000/missing"); err == nil {
+		t.Errorf("expected an error for an unknown DOI")
+	}
+}
@@ -0,0 +1,170 @@
+package academic
+
+// Citation metadata for academic papers, from the arXiv API and DOI
+// resolvers, which describe papers far better than their abstract pages and
+// PDFs do.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+// BibTeX entry types.
+const (
+	TypeArticle       = "article"
+	TypeInProceedings = "inproceedings"
+	TypeMisc          = "misc"
+)
+
+// Paper is an academic paper's citation metadata.
+type Paper struct {
+	Type       string     `json:"type"`              // BibTeX entry type.
+	ArXivID    string     `json:"arxivID,omitempty"` // Without version, e.g. "1706.03762".
+	Version    string     `json:"version,omitempty"` // Latest arXiv version, e.g. "v7".
+	DOI        string     `json:"doi,omitempty"`
+	Title      string     `json:"title"`
+	Authors    []string   `json:"authors"`
+	Abstract   string     `json:"abstract,omitempty"`
+	Categories []string   `json:"categories,omitempty"` // Subject categories, primary first.
+	Venue      string     `json:"venue,omitempty"`      // Journal or proceedings.
+	Publisher  string     `json:"publisher,omitempty"`
+	Published  *time.Time `json:"published,omitempty"`
+	Updated    *time.Time `json:"updated,omitempty"`
+	URL        string     `json:"url"` // Abstract or landing page.
+	PDFURL     string     `json:"pdfURL,omitempty"`
+}
+
+var (
+	bibtexEscaper = strings.NewReplacer(`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`, `_`, `\_`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`)
+
+	// bibtexVerbatimEscaper percent-encodes the characters which could end a
+	// verbatim field (doi, url) early.
+	bibtexVerbatimEscaper = strings.NewReplacer(`\`, `%5C`, `{`, `%7B`, `}`, `%7D`)
+
+	citeKeyExpr = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// CiteKey returns a BibTeX citation key in the usual
+// <first author surname><year><first title word> form, e.g.
+// "vaswani2017attention".
+func (p *Paper) CiteKey() string {
+	var key string
+	if len(p.Authors) > 0 {
+		names := strings.Fields(p.Authors[0])
+		if len(names) > 0 {
+			key = names[len(names)-1]
+		}
+	}
+	if p.Published != nil {
+		key += fmt.Sprint(p.Published.Year())
+	}
+	for _, word := range strings.Fields(p.Title) {
+		word = strings.ToLower(textmanip.ToASCII(word))
+		if len(word) > 3 && !textmanip.IsStopWord(word) {
+			key += word
+			break
+		}
+	}
+	key = citeKeyExpr.ReplaceAllString(strings.ToLower(textmanip.ToASCII(key)), "")
+	if key == "" {
+		key = citeKeyExpr.ReplaceAllString(strings.ToLower(p.ArXivID+p.DOI), "")
+	}
+	return key
+}
+
+// BibTeX renders the paper as a BibTeX entry.
+func (p *Paper) BibTeX() string {
+	typ := p.Type
+	if typ == "" {
+		typ = TypeMisc
+	}
+
+	var fields [][2]string
+	add := func(name string, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{name, value})
+		}
+	}
+	// Double braces preserve the title's capitalization.
+	add("title", "{"+bibtexEscaper.Replace(p.Title)+"}")
+	authors := make([]string, len(p.Authors))
+	for i, author := range p.Authors {
+		authors[i] = bibtexEscaper.Replace(author)
+	}
+	add("author", strings.Join(authors, " and "))
+	switch typ {
+	case TypeArticle:
+		add("journal", bibtexEscaper.Replace(p.Venue))
+	case TypeInProceedings:
+		add("booktitle", bibtexEscaper.Replace(p.Venue))
+	}
+	add("publisher", bibtexEscaper.Replace(p.Publisher))
+	if p.Published != nil {
+		add("year", fmt.Sprint(p.Published.Year()))
+		add("month", strings.ToLower(p.Published.Month().String()[0:3]))
+	}
+	if p.ArXivID != "" {
+		add("eprint", p.ArXivID)
+		add("archivePrefix", "arXiv")
+		if len(p.Categories) > 0 {
+			add("primaryClass", p.Categories[0])
+		}
+	}
+	add("doi", bibtexVerbatimEscaper.Replace(p.DOI))
+	add("url", bibtexVerbatimEscaper.Replace(p.URL))
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "@%v{%v", typ, p.CiteKey())
+	for _, field := range fields {
+		fmt.Fprintf(buf, ",\n  %v = {%v}", field[0], field[1])
+	}
+	buf.WriteString("\n}")
+	return buf.String()
+}
+
+// Year returns the year of publication, or 0 when unknown.
+func (p *Paper) Year() int {
+	if p.Published == nil {
+		return 0
+	}
+	return p.Published.Year()
+}
+
+// collapse replaces every run of whitespace in s with a single space.
+func collapse(s string) string {
+	return strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+}
+
+func get(ctx context.Context, client *http.Client, u string, accept string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request to %v: %s", u, err)
+	}
+	req.Header.Set("Accept", accept)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %v: %s", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("fetching %v: non-2xx response status-code=%v", u, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %s", u, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,78 @@
+package academic
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBibTeX(t *testing.T) {
+	published := time.Date(2017, 6, 12, 0, 0, 0, 0, time.UTC)
+	p := &Paper{
+		Type:       TypeInProceedings,
+		ArXivID:    "1706.03762",
+		DOI:        "10.1000/x}{y",
+		Title:      `Attention {Is} All_You Need: 100% of $5 & #1 \o/ ~^`,
+		Authors:    []string{"Ashish Vaswani", "Łukasz Kaiser"},
+		Categories: []string{"cs.CL"},
+		Venue:      "NeurIPS } \\end{document}",
+		Published:  &published,
+		URL:        "https://arxiv.org/abs/1706.03762",
+	}
+	expected := `@inproceedings{vaswani2017attention,
+  title = {{Attention \{Is\} All\_You Need: 100\% of \$5 \& \#1 \textbackslash{}o/ \textasciitilde{}\textasciicircum{}}},
+  author = {Ashish Vaswani and Łukasz Kaiser},
+  booktitle = {NeurIPS \} \textbackslash{}end\{document\}},
+  year = {2017},
+  month = {jun},
+  eprint = {1706.03762},
+  archivePrefix = {arXiv},
+  primaryClass = {cs.CL},
+  doi = {10.1000/x%7D%7By},
+  url = {https://arxiv.org/abs/1706.03762}
+}`
+	if actual := p.BibTeX(); actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestBibTeXBalancedBraces(t *testing.T) {
+	for _, hostile := range []string{"}", "{", "}}}, note = {pwned", `\`, `\}`, "a}b{c"} {
+		p := &Paper{Title: hostile, Authors: []string{hostile}, Venue: hostile, Publisher: hostile, Type: TypeArticle, DOI: hostile, URL: hostile}
+		entry := p.BibTeX()
+		depth, negative := 0, false
+		for i, c := range entry {
+			if i > 0 && entry[i-1] == '\\' {
+				continue
+			}
+			switch c {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			negative = negative || depth < 0
+		}
+		if depth != 0 || negative || strings.Count(entry, "\n") != 7 {
+			t.Errorf("unbalanced entry for %q:\n%s", hostile, entry)
+		}
+	}
+}
+
+func TestCiteKey(t *testing.T) {
+	published := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		paper *Paper
+		key   string
+	}{
+		{&Paper{Authors: []string{"Ashish Vaswani"}, Title: "Attention Is All You Need", Published: &published}, "vaswani2020attention"},
+		{&Paper{Authors: []string{"Kurt Gödel"}, Title: "Über formal unentscheidbare Sätze", Published: &published}, "godel2020uber"},
+		{&Paper{Title: "The Art of the Deal"}, "deal"},
+		{&Paper{DOI: "10.1000/182"}, "101000182"},
+	}
+	for _, testCase := range testCases {
+		if actual := testCase.paper.CiteKey(); actual != testCase.key {
+			t.Errorf("CiteKey(%+v) = %q, expected %q", testCase.paper, actual, testCase.key)
+		}
+	}
+}
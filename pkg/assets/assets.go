@@ -0,0 +1,238 @@
+package assets
+
+// Content-addressed mirroring of article images.
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxImageBytes caps the size of a single mirrored image.
+const MaxImageBytes = 20 * 1024 * 1024
+
+// MaxImagePixels caps the dimensions of images which are decoded for
+// thumbnailing, as a small compressed file can declare enormous dimensions.
+const MaxImagePixels = 24 * 1000 * 1000
+
+// DefaultThumbnailWidth is the width in pixels of generated thumbnails.
+const DefaultThumbnailWidth = 320
+
+// Image describes a mirrored copy of a remote image.
+type Image struct {
+	Source      string `json:"source"`              // Original remote URL.
+	SHA256      string `json:"sha256"`              // Hex digest of the image content.
+	Path        string `json:"path"`                // Location relative to the store root.
+	URL         string `json:"url"`                 // Public URL of the mirrored copy.
+	Thumbnail   string `json:"thumbnail,omitempty"` // Public URL of the thumbnail, when one could be generated.
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Top         bool   `json:"top,omitempty"` // Whether this is the article's lead image.
+}
+
+// Store saves images under Dir, named by the SHA-256 of their content so
+// that an image shared between articles is only kept once.
+type Store struct {
+	Dir            string       // Root directory on disk.
+	URLPrefix      string       // Public URL prefix under which Dir is served.
+	Client         *http.Client // Defaults to http.DefaultClient.
+	ThumbnailWidth int          // Zero disables thumbnails.
+}
+
+// New returns a Store rooted at dir which is served under urlPrefix.
+func New(dir string, urlPrefix string) *Store {
+	s := &Store{
+		Dir:            dir,
+		URLPrefix:      urlPrefix,
+		ThumbnailWidth: DefaultThumbnailWidth,
+	}
+	return s
+}
+
+// Mirror downloads the image at src and saves it, along with a thumbnail, to
+// the store.
+func (s *Store) Mirror(ctx context.Context, src string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating image request to %v: %s", src, err)
+	}
+	req.Header.Set("Accept", "image/*")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading image %v: %s", src, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("downloading image %v: non-2xx response status-code=%v", src, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading image %v: %s", src, err)
+	}
+	if len(data) > MaxImageBytes {
+		return nil, fmt.Errorf("image %v exceeds %v bytes", src, MaxImageBytes)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%v is not an image (content-type=%v)", src, contentType)
+	}
+
+	return s.Put(src, contentType, data)
+}
+
+// Put saves image data fetched from src to the store.
+func (s *Store) Put(src string, contentType string, data []byte) (*Image, error) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	img := &Image{
+		Source:      src,
+		SHA256:      digest,
+		Path:        path.Join(digest[0:2], digest+extension(contentType, src)),
+		ContentType: contentType,
+	}
+	img.URL = s.url(img.Path)
+
+	if err := s.write(img.Path, data); err != nil {
+		return nil, err
+	}
+
+	// Formats the standard library cannot decode (e.g. SVG and WebP) are
+	// still mirrored, just without dimensions or a thumbnail.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return img, nil
+	}
+	img.Width, img.Height = config.Width, config.Height
+
+	// Only the header has been read so far; images over the pixel budget are
+	// kept without a thumbnail rather than decoded.
+	if s.ThumbnailWidth > 0 && int64(config.Width)*int64(config.Height) <= MaxImagePixels {
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return img, nil
+		}
+		thumbPath := path.Join(digest[0:2], fmt.Sprintf("%v-%v%v", digest, s.ThumbnailWidth, thumbnailExtension(contentType)))
+		if !s.exists(thumbPath) {
+			thumb, err := encodeThumbnail(Thumbnail(decoded, s.ThumbnailWidth), contentType)
+			if err != nil {
+				return nil, fmt.Errorf("encoding thumbnail for %v: %s", src, err)
+			}
+			if err := s.write(thumbPath, thumb); err != nil {
+				return nil, err
+			}
+		}
+		img.Thumbnail = s.url(thumbPath)
+	}
+
+	return img, nil
+}
+
+func (s *Store) url(p string) string {
+	return strings.TrimRight(s.URLPrefix, "/") + "/" + p
+}
+
+func (s *Store) exists(p string) bool {
+	_, err := os.Stat(filepath.Join(s.Dir, filepath.FromSlash(p)))
+	return err == nil
+}
+
+// write atomically saves data to p unless it is already present.
+func (s *Store) write(p string, data []byte) error {
+	if s.exists(p) {
+		return nil
+	}
+	dst := filepath.Join(s.Dir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(dst), os.FileMode(int(0755))); err != nil {
+		return fmt.Errorf("creating asset directory: %s", err)
+	}
+	tmp := fmt.Sprintf("%v.tmp-%v", dst, os.Getpid())
+	if err := ioutil.WriteFile(tmp, data, os.FileMode(int(0644))); err != nil {
+		return fmt.Errorf("writing asset %v: %s", dst, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("renaming asset %v: %s", dst, err)
+	}
+	return nil
+}
+
+var extensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+	"image/bmp":     ".bmp",
+	"image/x-icon":  ".ico",
+	"image/avif":    ".avif",
+}
+
+func extension(contentType string, src string) string {
+	if ext, ok := extensions[contentType]; ok {
+		return ext
+	}
+	if ext := strings.ToLower(path.Ext(strings.SplitN(src, "?", 2)[0])); len(ext) > 1 && len(ext) <= 5 {
+		return ext
+	}
+	return ".img"
+}
+
+var imageRefExpr = regexp.MustCompile(`!\[(?:\\.|[^\]\\])*\]\(([^)\s]+)\)`)
+
+// ImageRefs returns the distinct image URLs referenced by Markdown image
+// syntax in md, in order of appearance.
+func ImageRefs(md string) []string {
+	var (
+		refs = []string{}
+		seen = map[string]struct{}{}
+	)
+	for _, m := range imageRefExpr.FindAllStringSubmatch(md, -1) {
+		if _, ok := seen[m[1]]; ok {
+			continue
+		}
+		seen[m[1]] = struct{}{}
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// Rewrite replaces Markdown image references to remote URLs with the local
+// URLs given in replacements.
+func Rewrite(md string, replacements map[string]string) string {
+	return imageRefExpr.ReplaceAllStringFunc(md, func(ref string) string {
+		m := imageRefExpr.FindStringSubmatch(ref)
+		local, ok := replacements[m[1]]
+		if !ok {
+			return ref
+		}
+		return ref[0:len(ref)-len(m[1])-1] + local + ")"
+	})
+}
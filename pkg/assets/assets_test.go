@@ -0,0 +1,144 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pngHeader returns the start of a PNG declaring the given dimensions, which
+// is all DecodeConfig reads.
+func pngHeader(width uint32, height uint32) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA.
+	binary.Write(buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestPutRejectsPixelBombs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(dir, "/assets")
+	img, err := s.Put("https://example.com/bomb.png", "image/png", pngHeader(50000, 50000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 50000 || img.Height != 50000 {
+		t.Errorf("expected declared dimensions to be recorded, got %vx%v", img.Width, img.Height)
+	}
+	if img.Thumbnail != "" {
+		t.Errorf("expected no thumbnail for an image over the pixel budget, got %v", img.Thumbnail)
+	}
+}
+
+func TestPutThumbnail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := image.NewNRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(dir, "/assets")
+	img, err := s.Put("https://example.com/a.png", "image/png", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 640 || img.Height != 480 || img.Thumbnail == "" {
+		t.Fatalf("unexpected image %+v", img)
+	}
+	thumb := Thumbnail(src, 320)
+	if b := thumb.Bounds(); b.Dx() != 320 || b.Dy() != 240 {
+		t.Errorf("expected a 320x240 thumbnail, got %vx%v", b.Dx(), b.Dy())
+	}
+	if r, g, b, a := thumb.At(10, 10).RGBA(); r>>8 != 200 || g>>8 != 100 || b>>8 != 50 || a>>8 != 255 {
+		t.Errorf("expected averaged color to be preserved, got %v %v %v %v", r>>8, g>>8, b>>8, a>>8)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/a.png":
+			// Served without a content type, so it is sniffed.
+			w.Write(pngHeader(10, 10))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>not an image</p>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := New(dir, "/assets")
+	img, err := s.Mirror(context.Background(), server.URL+"/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 10 || !strings.HasPrefix(img.URL, "/assets/") {
+		t.Errorf("unexpected image %+v", img)
+	}
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/page.html", "is not an image"},
+		{"/missing.png", "status-code=404"},
+	}
+	for _, testCase := range testCases {
+		if _, err := s.Mirror(context.Background(), server.URL+testCase.path); err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("%v: expected an error containing %q, got %v", testCase.path, testCase.expected, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	requests = 0
+	if _, err := s.Mirror(ctx, server.URL+"/a.png"); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests once cancelled, got %v", requests)
+	}
+}
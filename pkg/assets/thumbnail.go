@@ -0,0 +1,89 @@
+package assets
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// Thumbnail scales src down to width pixels wide, preserving the aspect
+// ratio, by averaging the source pixels covered by each destination pixel.
+// Images already narrower than width are returned as-is.
+func Thumbnail(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if sw <= width || sw == 0 || sh == 0 {
+		return src
+	}
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	// Work on premultiplied RGBA pixels directly rather than through the
+	// per-pixel At interface; draw has fast paths for the decoded types.
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, sw, sh))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+	origin := rgba.Bounds().Min
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := (y + 1) * sh / height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := (x + 1) * sw / width
+			if x1 == x0 {
+				x1++
+			}
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(origin.X+x0, origin.Y+sy)
+				row := rgba.Pix[i : i+(x1-x0)*4]
+				for j := 0; j < len(row); j += 4 {
+					sum[0] += uint64(row[j])
+					sum[1] += uint64(row[j+1])
+					sum[2] += uint64(row[j+2])
+					sum[3] += uint64(row[j+3])
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			k := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[k+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// thumbnailExtension returns the file extension used for thumbnails of the
+// given content type.  Formats which may carry transparency keep it as PNG.
+func thumbnailExtension(contentType string) string {
+	if contentType == "image/png" || contentType == "image/gif" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+func encodeThumbnail(img image.Image, contentType string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if thumbnailExtension(contentType) == ".png" {
+		if err := png.Encode(buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,371 @@
+package canonical
+
+// Canonical URL resolution: tracking parameter removal, de-AMP'ing and
+// discovery of <link rel="canonical"> / og:url declarations.
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// trackingParams are query parameters which never affect page content.
+var trackingParams = map[string]struct{}{
+	"fbclid":               {},
+	"gclid":                {},
+	"dclid":                {},
+	"yclid":                {},
+	"msclkid":              {},
+	"igshid":               {},
+	"mc_cid":               {},
+	"mc_eid":               {},
+	"_hsenc":               {},
+	"_hsmi":                {},
+	"mkt_tok":              {},
+	"ref_src":              {},
+	"ref_url":              {},
+	"referrer":             {},
+	"smid":                 {},
+	"cmpid":                {},
+	"ncid":                 {},
+	"sr_share":             {},
+	"s_cid":                {},
+	"__twitter_impression": {},
+	"amp":                  {},
+	"outputtype":           {},
+}
+
+// trackingPrefixes are query parameter name prefixes which never affect page
+// content.
+var trackingPrefixes = []string{"utm_", "pk_", "hmb_", "ga_", "oly_"}
+
+// maxRefreshDelay is the longest meta refresh delay, in seconds, which is
+// treated as a redirect.
+const maxRefreshDelay = 5
+
+// mobileHosts are mobile and AMP hosts known to serve the same documents, at
+// the same paths, as the regular hosts they map to.  Other mobile hosts are
+// left alone unless the page itself declares the regular URL as canonical.
+var mobileHosts = map[string]string{
+	"amp.theguardian.com": "www.theguardian.com",
+	"m.facebook.com":      "www.facebook.com",
+	"m.youtube.com":       "www.youtube.com",
+	"mobile.nytimes.com":  "www.nytimes.com",
+	"mobile.twitter.com":  "twitter.com",
+}
+
+// mobileWikiExpr matches the mobile hosts of the Wikimedia projects, e.g.
+// "en.m.wikipedia.org".
+var mobileWikiExpr = regexp.MustCompile(`^([a-z-]+)\.m\.(wikipedia|wiktionary|wikibooks|wikinews|wikiquote|wikisource|wikiversity|wikivoyage)\.org$`)
+
+// ampPrefixHosts publish the AMP version of /<path> at /amp/<path>.
+var ampPrefixHosts = map[string]struct{}{
+	"cbsnews.com": {},
+	"cnbc.com":    {},
+}
+
+// ampSuffixHosts publish the AMP version of /<path> at /<path>/amp,
+// /<path>.amp or similar.  Elsewhere a trailing "amp" is as likely to be part
+// of the document's address, e.g. "https://github.com/ampproject/amp", and
+// AMP pages are recognized by their declared canonical URL instead.
+var ampSuffixHosts = map[string]struct{}{
+	"bbc.co.uk":         {},
+	"bbc.com":           {},
+	"independent.co.uk": {},
+	"wired.com":         {},
+}
+
+// Clean normalizes rawURL: lower-cases the scheme and host, drops default
+// ports, fragments and tracking parameters, rewrites AMP variants and known
+// mobile hosts to their regular form, and sorts the remaining query
+// parameters.  The path and parameter encodings are otherwise preserved.
+// Values which cannot be parsed are returned as-is.
+func Clean(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	// Documents served from an AMP cache are always AMP variants.
+	unwrapped := unwrapAMPCache(u)
+	if unwrapped != nil {
+		u = unwrapped
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		u.Host = u.Host[0:strings.LastIndex(u.Host, ":")]
+	}
+	u.Host = desktopHost(u.Host)
+	u.Fragment = ""
+	u.Path = deAMPPath(u.Host, u.Path, unwrapped != nil)
+	if u.RawPath != "" {
+		u.RawPath = deAMPPath(u.Host, u.RawPath, unwrapped != nil)
+	}
+	u.RawQuery = cleanQuery(u.RawQuery)
+
+	return u.String()
+}
+
+// cleanQuery drops tracking parameters from rawQuery and sorts the remainder
+// by name, providing a stable representation.  Parameters are otherwise kept
+// exactly as they were, so "?flag" does not become "?flag=".
+func cleanQuery(rawQuery string) string {
+	type param struct {
+		name string
+		raw  string
+	}
+	params := []param{}
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name := raw
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[0:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if IsTrackingParam(name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+// IsTrackingParam reports whether the named query parameter is only used for
+// analytics / attribution.
+func IsTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := trackingParams[name]; ok {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// desktopHost returns the regular host for known mobile hosts, and host
+// itself otherwise.
+func desktopHost(host string) string {
+	if desktop, ok := mobileHosts[host]; ok {
+		return desktop
+	}
+	if m := mobileWikiExpr.FindStringSubmatch(host); m != nil {
+		return m[1] + "." + m[2] + ".org"
+	}
+	return host
+}
+
+// deAMPPath strips a leading "/amp/" segment on hosts known to use one, and a
+// trailing "/amp" or ".amp" / ".amp.html" suffix on hosts known to use those
+// or when the document came from an AMP cache.  "/amp/" elsewhere in the path
+// is left alone, as it is just as likely to be part of the document's
+// address, e.g. "/docs/amp/intro".
+func deAMPPath(host string, p string, cached bool) string {
+	host = strings.TrimPrefix(host, "www.")
+	if _, ok := ampPrefixHosts[host]; ok && strings.HasPrefix(p, "/amp/") {
+		p = p[len("/amp"):]
+	}
+	if _, ok := ampSuffixHosts[host]; !ok && !cached {
+		return p
+	}
+	for _, suffix := range []string{"/amp", ".amp", "/amp.html"} {
+		if strings.HasSuffix(p, suffix) {
+			p = p[0 : len(p)-len(suffix)]
+			if p == "" {
+				p = "/"
+			}
+			break
+		}
+	}
+	if strings.HasSuffix(p, ".amp.html") {
+		p = p[0:len(p)-len(".amp.html")] + ".html"
+	}
+	return p
+}
+
+// unwrapAMPCache extracts the origin URL from Google AMP viewer and AMP cache
+// URLs, e.g. "https://www.google.com/amp/s/example.com/story" or
+// "https://example-com.cdn.ampproject.org/c/s/example.com/story".  Returns
+// nil when u is not an AMP cache URL.
+func unwrapAMPCache(u *url.URL) *url.URL {
+	var rest string
+	switch {
+	case (u.Host == "www.google.com" || u.Host == "google.com") && strings.HasPrefix(u.Path, "/amp/"):
+		rest = strings.TrimPrefix(u.Path, "/amp/")
+	case strings.HasSuffix(u.Host, ".cdn.ampproject.org"):
+		rest = strings.TrimPrefix(u.Path, "/")
+		for _, prefix := range []string{"c/", "v/", "i/"} {
+			rest = strings.TrimPrefix(rest, prefix)
+		}
+	default:
+		return nil
+	}
+	scheme := "http"
+	if strings.HasPrefix(rest, "s/") {
+		scheme = "https"
+		rest = strings.TrimPrefix(rest, "s/")
+	}
+	origin, err := url.Parse(scheme + "://" + rest)
+	if err != nil || origin.Host == "" {
+		return nil
+	}
+	origin.RawQuery = u.RawQuery
+	return origin
+}
+
+// Declared holds the URLs a page declares about itself.
+type Declared struct {
+	Canonical   string // <link rel="canonical">
+	OpenGraph   string // <meta property="og:url">
+	MetaRefresh string // <meta http-equiv="refresh" content="0;url=...">
+}
+
+// FromHTML scans the document head for canonical, og:url and meta refresh
+// declarations.  Relative references are resolved against base.
+func FromHTML(content []byte, base string) Declared {
+	var (
+		declared = Declared{}
+		z        = html.NewTokenizer(bytes.NewReader(content))
+		baseURL  *url.URL
+	)
+	if u, err := url.Parse(base); err == nil {
+		baseURL = u
+	}
+
+	resolve := func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || baseURL == nil {
+			return ref
+		}
+		u, err := baseURL.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return declared
+
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Head {
+				return declared
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			if a == atom.Body {
+				return declared
+			}
+			if !hasAttr || (a != atom.Link && a != atom.Meta) {
+				continue
+			}
+			attrs := map[string]string{}
+			for {
+				key, val, more := z.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(val)
+				if !more {
+					break
+				}
+			}
+			switch {
+			case a == atom.Link && hasToken(attrs["rel"], "canonical") && declared.Canonical == "":
+				declared.Canonical = resolve(attrs["href"])
+			case a == atom.Meta && strings.ToLower(attrs["property"]) == "og:url" && declared.OpenGraph == "":
+				declared.OpenGraph = resolve(attrs["content"])
+			case a == atom.Meta && strings.ToLower(attrs["http-equiv"]) == "refresh" && declared.MetaRefresh == "":
+				declared.MetaRefresh = resolve(refreshTarget(attrs["content"]))
+			}
+		}
+	}
+}
+
+// Preferred returns the canonical URL declaration, falling back to og:url.
+func (d Declared) Preferred() string {
+	if isHTTP(d.Canonical) {
+		return d.Canonical
+	}
+	if isHTTP(d.OpenGraph) {
+		return d.OpenGraph
+	}
+	return ""
+}
+
+// Resolve picks the canonical URL for a page fetched from finalURL.  The
+// declared canonical is used unless it looks bogus (e.g. points at a site's
+// home page when the article was not the home page), then the result is
+// cleaned.
+func Resolve(finalURL string, declared Declared) string {
+	candidate := declared.Preferred()
+	if candidate != "" {
+		cu, err1 := url.Parse(candidate)
+		fu, err2 := url.Parse(finalURL)
+		if err1 != nil || err2 != nil || (isRootPath(cu.Path) && !isRootPath(fu.Path)) {
+			candidate = ""
+		}
+	}
+	if candidate == "" {
+		candidate = finalURL
+	}
+	return Clean(candidate)
+}
+
+// refreshTarget extracts the destination from a meta refresh content value
+// such as "0; url=https://example.com/".  Refreshes with a delay of more than
+// a few seconds are periodic page reloads rather than redirects, and are
+// ignored.
+func refreshTarget(content string) string {
+	parts := strings.SplitN(content, ";", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	if delay, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil || delay > maxRefreshDelay {
+		return ""
+	}
+	target := strings.TrimSpace(parts[1])
+	if i := strings.Index(strings.ToLower(target), "url="); i == 0 {
+		target = target[4:]
+	}
+	return strings.Trim(strings.TrimSpace(target), `'"`)
+}
+
+func hasToken(list string, token string) bool {
+	for _, field := range strings.Fields(strings.ToLower(list)) {
+		if field == token {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func isRootPath(p string) bool {
+	return p == "" || p == "/"
+}
@@ -0,0 +1,55 @@
+package canonical
+
+import (
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"HTTPS://Example.com:443/a?utm_source=x&b=2&a=1#frag", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/story/amp", "https://example.com/story/amp"},
+		{"https://example.com/story.amp.html", "https://example.com/story.amp.html"},
+		{"https://github.com/ampproject/amp", "https://github.com/ampproject/amp"},
+		{"https://www.wired.com/story/some-story/amp", "https://www.wired.com/story/some-story"},
+		{"https://www.bbc.com/news/world-12345678.amp", "https://www.bbc.com/news/world-12345678"},
+		{"https://www.google.com/amp/s/example.com/story/amp", "https://example.com/story"},
+		{"https://example.com/a%2Fb/c", "https://example.com/a%2Fb/c"},
+		{"https://example.com/search?q=a+b&flag&utm_medium=x", "https://example.com/search?flag&q=a+b"},
+		{"https://example.com/?b=2&a=1&b=1", "https://example.com/?a=1&b=2&b=1"},
+		{"https://example.com/docs/amp/intro", "https://example.com/docs/amp/intro"},
+		{"https://example.com/amp/story", "https://example.com/amp/story"},
+		{"https://www.cnbc.com/amp/2020/01/01/story.html", "https://www.cnbc.com/2020/01/01/story.html"},
+		{"https://github.com/foo/bar/tree/main?ref=v1.2.0", "https://github.com/foo/bar/tree/main?ref=v1.2.0"},
+		{"https://m.example.com/story", "https://m.example.com/story"},
+		{"https://mobile.twitter.com/user/status/1", "https://twitter.com/user/status/1"},
+		{"https://en.m.wikipedia.org/wiki/Go", "https://en.wikipedia.org/wiki/Go"},
+		{"https://www.google.com/amp/s/example.com/story", "https://example.com/story"},
+	}
+	for _, testCase := range testCases {
+		if actual := Clean(testCase.in); actual != testCase.expected {
+			t.Errorf("Clean(%q) = %q, expected %q", testCase.in, actual, testCase.expected)
+		}
+	}
+}
+
+func TestResolveKeepsDeclaredCanonical(t *testing.T) {
+	declared := FromHTML([]byte(`<html><head><link rel="canonical" href="https://github.com/ampproject/amp"></head></html>`), "https://github.com/ampproject/amp")
+	if actual, expected := Resolve("https://github.com/ampproject/amp", declared), "https://github.com/ampproject/amp"; actual != expected {
+		t.Errorf("Resolve = %q, expected %q", actual, expected)
+	}
+
+	declared = FromHTML([]byte(`<html><head><link rel="canonical" href="https://example.com/story"></head></html>`), "https://example.com/story/amp")
+	if actual, expected := Resolve("https://example.com/story/amp", declared), "https://example.com/story"; actual != expected {
+		t.Errorf("Resolve = %q, expected %q", actual, expected)
+	}
+}
+
+func TestResolveMobileWithCanonical(t *testing.T) {
+	declared := FromHTML([]byte(`<html><head><link rel="canonical" href="https://example.com/story"></head></html>`), "https://m.example.com/story")
+	if actual, expected := Resolve("https://m.example.com/story", declared), "https://example.com/story"; actual != expected {
+		t.Errorf("Resolve = %q, expected %q", actual, expected)
+	}
+}
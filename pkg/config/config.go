@@ -0,0 +1,455 @@
+package config
+
+// Central configuration shared by the hydrator, site generator and helper
+// scripts.  Settings are layered, each overriding the last:
+//
+//	built-in defaults < config file < active profile < environment < flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"jaytaylor.com/circus/pkg/academic"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+	"jaytaylor.com/circus/pkg/forge"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/jobqueue"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g.
+// CIRCUS_FETCH_TIMEOUT for fetch.timeout.
+const EnvPrefix = "CIRCUS_"
+
+// Sources of a setting's value, as reported by Source.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Config holds every setting.  Keys are the dotted JSON field names, e.g.
+// "fetch.timeout".
+type Config struct {
+	NLPWeb   NLPWeb   `json:"nlpweb"`
+	Fetch    Fetch    `json:"fetch"`
+	Hydrator Hydrator `json:"hydrator"`
+	Render   Render   `json:"render"`
+	Serve    Serve    `json:"serve"`
+	HN       HN       `json:"hn"`
+	Forge    Forge    `json:"forge"`
+	Academic Academic `json:"academic"`
+	Site     Site     `json:"site"`
+
+	// Path and Profile record where the configuration was loaded from.
+	Path    string `json:"-"`
+	Profile string `json:"-"`
+
+	sources map[string]string
+}
+
+// NLPWeb configures the named-entity recognition sidecar.
+type NLPWeb struct {
+	Addr   string `json:"addr"`   // Address to launch nlpweb.py on.
+	Server string `json:"server"` // Base URL of an already running instance.
+}
+
+// Fetch configures outbound HTTP requests.
+type Fetch struct {
+	Timeout       Duration `json:"timeout"`
+	UserAgent     string   `json:"userAgent"`
+	Policy        string   `json:"policy"`   // Path to a fetch policy JSON file.
+	StateDir      string   `json:"stateDir"` // Robots.txt and rate limit state.
+	RespectRobots bool     `json:"respectRobots"`
+}
+
+// Hydrator configures article extraction and enrichment.
+type Hydrator struct {
+	SummarySentences int      `json:"summarySentences"`
+	PDFTimeout       Duration `json:"pdfTimeout"`
+	AssetsDir        string   `json:"assetsDir"`
+	AssetsURL        string   `json:"assetsURL"`
+	ThumbnailWidth   int      `json:"thumbnailWidth"`
+	SnapshotDir      string   `json:"snapshotDir"`
+	SnapshotURL      string   `json:"snapshotURL"`
+	Stages           string   `json:"stages"` // Path to a pipeline stages JSON file.
+}
+
+// Render configures headless browser rendering.
+type Render struct {
+	Enabled  bool     `json:"enabled"`
+	MinWords int      `json:"minWords"`
+	Browser  string   `json:"browser"`
+	Timeout  Duration `json:"timeout"`
+	Idle     Duration `json:"idle"`
+
+	// NoSandbox disables the browser sandbox, which is required to render
+	// as root.
+	NoSandbox bool `json:"noSandbox"`
+}
+
+// Serve configures the hydrator REST API.
+type Serve struct {
+	Listen       string   `json:"listen"`
+	QueueDir     string   `json:"queueDir"`
+	JobRetention Duration `json:"jobRetention"` // How long finished jobs are kept.
+	Workers      int      `json:"workers"`
+	AuthConfig   string   `json:"authConfig"`
+	StoryDir     string   `json:"storyDir"`
+}
+
+// HN configures access to Hacker News.
+type HN struct {
+	User        string `json:"user"`
+	API         string `json:"api"`
+	Concurrency int    `json:"concurrency"`
+	MaxComments int    `json:"maxComments"`
+}
+
+// Forge configures the code forge APIs used for repository links.
+type Forge struct {
+	GitHubAPI   string `json:"githubAPI"`
+	GitHubToken string `json:"githubToken" secret:"true"`
+	GitLabAPI   string `json:"gitlabAPI"`
+	GitLabToken string `json:"gitlabToken" secret:"true"`
+}
+
+// Academic configures the APIs used for links to academic papers.
+type Academic struct {
+	ArXivAPI    string `json:"arxivAPI"`
+	DOIResolver string `json:"doiResolver"`
+}
+
+// Site configures static site generation.
+type Site struct {
+	HugoDir   string `json:"hugoDir"   env:"HUGO_DIR"`
+	SrcDir    string `json:"srcDir"    env:"SRC_DIR"`
+	OutputDir string `json:"outputDir" env:"OUTPUT_DIR"`
+	Limit     int    `json:"limit"     env:"LIMIT"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	c := &Config{
+		NLPWeb: NLPWeb{
+			Addr: "127.0.0.1:8000",
+		},
+		Fetch: Fetch{
+			Timeout:   Duration(10 * time.Second),
+			UserAgent: fetchpolicy.DefaultUserAgent,
+		},
+		Hydrator: Hydrator{
+			SummarySentences: 3,
+			PDFTimeout:       Duration(30 * time.Second),
+			AssetsURL:        "/assets",
+			ThumbnailWidth:   320,
+			SnapshotURL:      "/snapshots",
+		},
+		Render: Render{
+			MinWords: 150,
+			Timeout:  Duration(30 * time.Second),
+			Idle:     Duration(500 * time.Millisecond),
+		},
+		Serve: Serve{
+			Listen:       "127.0.0.1:8080",
+			QueueDir:     "hydrator-queue",
+			JobRetention: Duration(jobqueue.DefaultRetention),
+			Workers:      2,
+		},
+		HN: HN{
+			User:        "jaytaylor",
+			API:         hnapi.DefaultBaseURL,
+			Concurrency: hnapi.DefaultConcurrency,
+			MaxComments: hnapi.DefaultMaxComments,
+		},
+		Forge: Forge{
+			GitHubAPI: forge.GitHubBaseURL,
+			GitLabAPI: forge.GitLabBaseURL,
+		},
+		Academic: Academic{
+			ArXivAPI:    academic.ArXivBaseURL,
+			DOIResolver: academic.DOIBaseURL,
+		},
+		Site: Site{
+			HugoDir:   "quickstart",
+			OutputDir: "/var/www/jaytaylor.com/hn",
+			Limit:     500,
+		},
+		sources: map[string]string{},
+	}
+	return c
+}
+
+// DefaultPath returns $CIRCUS_CONFIG, or else the first of circus.toml,
+// circus.yaml and circus.yml found in the working directory or
+// ~/.config/circus, or "" when there are none.
+func DefaultPath() string {
+	if path := os.Getenv(EnvPrefix + "CONFIG"); path != "" {
+		return path
+	}
+	dirs := []string{"."}
+	if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".config", "circus"))
+	}
+	for _, dir := range dirs {
+		for _, name := range []string{"circus.toml", "circus.yaml", "circus.yml"} {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Load builds the configuration from the defaults, the file at path (skipped
+// when empty), the named profile within it and the environment, then
+// validates it.  When profile is empty $CIRCUS_PROFILE, then the file's
+// top-level "profile" setting, selects the profile.
+func Load(path string, profile string) (*Config, error) {
+	c := Default()
+	c.Path = path
+	if profile == "" {
+		profile = os.Getenv(EnvPrefix + "PROFILE")
+	}
+
+	if path != "" {
+		settings, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		profiles := map[string]interface{}{}
+		if v, ok := settings["profiles"]; ok {
+			if profiles, ok = v.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%v: profiles must be a table of profile names", path)
+			}
+			delete(settings, "profiles")
+		}
+		if v, ok := settings["profile"]; ok {
+			if profile == "" {
+				profile, _ = v.(string)
+			}
+			delete(settings, "profile")
+		}
+
+		if err := c.merge(settings, SourceFile); err != nil {
+			return nil, fmt.Errorf("%v: %s", path, err)
+		}
+		if profile != "" {
+			p, ok := profiles[profile].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%v: no profile named %q (have: %v)", path, profile, strings.Join(keys(profiles), ", "))
+			}
+			if err := c.merge(p, SourceProfile); err != nil {
+				return nil, fmt.Errorf("%v: profile %v: %s", path, profile, err)
+			}
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requested but no config file was given", profile)
+	}
+	c.Profile = profile
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile parses a TOML, YAML or JSON file, chosen by extension, into
+// generic maps.
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %s", err)
+	}
+	var settings map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &settings); err != nil {
+			return nil, fmt.Errorf("parsing %v: %s", path, err)
+		}
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parsing %v: %s", path, err)
+		}
+		var ok bool
+		if settings, ok = normalize(raw).(map[string]interface{}); !ok && raw != nil {
+			return nil, fmt.Errorf("parsing %v: top level must be a mapping", path)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, fmt.Errorf("parsing %v: %s", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized config file extension %q, must be one of .toml, .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	return settings, nil
+}
+
+// normalize converts the map[interface{}]interface{} values produced by the
+// YAML decoder into map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+	}
+	return v
+}
+
+// merge overlays settings onto c, recording source for every key set.
+// Unknown keys are rejected.
+func (c *Config) merge(settings map[string]interface{}, source string) error {
+	bs, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("serializing settings: %s", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return err
+	}
+	for _, key := range flatten("", settings) {
+		c.sources[key] = source
+	}
+	return nil
+}
+
+// flatten returns the dotted paths of the leaves of settings.
+func flatten(prefix string, settings map[string]interface{}) []string {
+	var paths []string
+	for k, v := range settings {
+		if m, ok := v.(map[string]interface{}); ok {
+			paths = append(paths, flatten(prefix+k+".", m)...)
+			continue
+		}
+		paths = append(paths, prefix+k)
+	}
+	return paths
+}
+
+// Source returns where the value of key came from: one of SourceDefault,
+// SourceFile, SourceProfile, SourceEnv or SourceFlag.
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Validate checks settings are within range and well formed.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.NLPWeb.Addr != "" && validHostPort(c.NLPWeb.Addr), "nlpweb.addr %q must be a host:port address", c.NLPWeb.Addr)
+	check(c.NLPWeb.Server == "" || validURL(c.NLPWeb.Server) || validHostPort(c.NLPWeb.Server), "nlpweb.server %q must be a URL or host:port address", c.NLPWeb.Server)
+	check(c.Fetch.Timeout > 0, "fetch.timeout must be positive")
+	check(c.Fetch.UserAgent != "", "fetch.userAgent must not be empty")
+	check(c.Hydrator.SummarySentences >= 0, "hydrator.summarySentences must not be negative")
+	check(c.Hydrator.PDFTimeout > 0, "hydrator.pdfTimeout must be positive")
+	check(c.Hydrator.ThumbnailWidth >= 0, "hydrator.thumbnailWidth must not be negative")
+	check(c.Render.MinWords >= 0, "render.minWords must not be negative")
+	check(c.Render.Timeout > 0, "render.timeout must be positive")
+	check(c.Render.Idle >= 0, "render.idle must not be negative")
+	check(validHostPort(c.Serve.Listen), "serve.listen %q must be a host:port address", c.Serve.Listen)
+	check(c.Serve.QueueDir != "", "serve.queueDir must not be empty")
+	check(c.Serve.JobRetention >= 0, "serve.jobRetention must not be negative")
+	check(c.Serve.Workers >= 1, "serve.workers must be at least 1")
+	check(c.Serve.AuthConfig == "" || c.Serve.StoryDir != "", "serve.storyDir is required when serve.authConfig is set")
+	check(validURL(c.HN.API), "hn.api %q must be an http or https URL", c.HN.API)
+	check(c.HN.Concurrency >= 1, "hn.concurrency must be at least 1")
+	check(c.HN.MaxComments >= 0, "hn.maxComments must not be negative")
+	check(validURL(c.Forge.GitHubAPI), "forge.githubAPI %q must be an http or https URL", c.Forge.GitHubAPI)
+	check(validURL(c.Forge.GitLabAPI), "forge.gitlabAPI %q must be an http or https URL", c.Forge.GitLabAPI)
+	check(validURL(c.Academic.ArXivAPI), "academic.arxivAPI %q must be an http or https URL", c.Academic.ArXivAPI)
+	check(validURL(c.Academic.DOIResolver), "academic.doiResolver %q must be an http or https URL", c.Academic.DOIResolver)
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validHostPort(addr string) bool {
+	_, _, err := net.SplitHostPort(addr)
+	return err == nil
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func keys(m map[string]interface{}) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// Duration is a time.Duration written as a string such as "1m30s".
+type Duration time.Duration
+
+// D returns d as a time.Duration.
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// String implements fmt.Stringer.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"10s\": %s", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const layeredTOML = `
+profile = "ci"
+
+[fetch]
+timeout = "1s"
+userAgent = "file-agent"
+
+[render]
+minWords = 10
+
+[hn]
+concurrency = 3
+maxComments = 7
+
+[serve]
+workers = 3
+
+[profiles.ci.fetch]
+timeout = "2s"
+
+[profiles.ci.hn]
+concurrency = 4
+
+[profiles.ci.serve]
+workers = 4
+
+[profiles.other.fetch]
+timeout = "9s"
+`
+
+// writeConfig writes content to a file with the given name in a new
+// temporary directory.
+func writeConfig(t *testing.T, name string, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "circus-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), os.FileMode(int(0644))); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// setenv sets the given environment variables, returning a function which
+// restores their previous values.
+func setenv(t *testing.T, vars map[string]string) func() {
+	previous := map[string]*string{}
+	for name, value := range vars {
+		if old, ok := os.LookupEnv(name); ok {
+			previous[name] = &old
+		} else {
+			previous[name] = nil
+		}
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for name, old := range previous {
+			if old == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *old)
+			}
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path, cleanup := writeConfig(t, "circus.toml", layeredTOML)
+	defer cleanup()
+	defer setenv(t, map[string]string{
+		"CIRCUS_PROFILE":        "",
+		"CIRCUS_HN_CONCURRENCY": "5",
+		"CIRCUS_SERVE_WORKERS":  "5",
+		"CIRCUS_SITE_LIMIT":     "42",
+		"LIMIT":                 "13",
+		"HUGO_DIR":              "legacy-hugo",
+	})()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	var (
+		workers     int
+		maxComments int
+		minWords    int
+	)
+	flags.IntVar(&workers, "workers", 2, "")
+	flags.IntVar(&maxComments, "max-comments", 0, "")
+	flags.IntVar(&minWords, "min-words", 150, "")
+	if err := flags.Parse([]string{"--workers=6"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := FromFlags(flags, path, "", map[string]string{
+		"workers":      "serve.workers",
+		"max-comments": "hn.maxComments",
+		"min-words":    "render.minWords",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Profile != "ci" {
+		t.Errorf("expected the file's profile setting to select %q, got %q", "ci", c.Profile)
+	}
+
+	testCases := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{"nlpweb.addr", "127.0.0.1:8000", SourceDefault},
+		{"fetch.userAgent", "file-agent", SourceFile},
+		{"render.minWords", "10", SourceFile},
+		{"fetch.timeout", "2s", SourceProfile},
+		{"hn.concurrency", "5", SourceEnv},
+		{"serve.workers", "6", SourceFlag},
+		{"site.limit", "42", SourceEnv},
+		{"site.hugoDir", "legacy-hugo", SourceEnv},
+	}
+	for _, testCase := range testCases {
+		f := c.Field(testCase.key)
+		if f == nil {
+			t.Errorf("%v: no such setting", testCase.key)
+			continue
+		}
+		if f.Value != testCase.value || f.Source != testCase.source {
+			t.Errorf("%v = %q from %v, expected %q from %v", testCase.key, f.Value, f.Source, testCase.value, testCase.source)
+		}
+	}
+
+	// Flags left at their defaults pick up configured values.
+	if maxComments != 7 {
+		t.Errorf("expected --max-comments to be set from the file, got %v", maxComments)
+	}
+	if minWords != 10 {
+		t.Errorf("expected --min-words to be set from the file, got %v", minWords)
+	}
+	if workers != 6 {
+		t.Errorf("expected --workers to keep its command-line value, got %v", workers)
+	}
+}
+
+func TestLoadProfileSelection(t *testing.T) {
+	path, cleanup := writeConfig(t, "circus.toml", layeredTOML)
+	defer cleanup()
+
+	testCases := []struct {
+		env      string
+		profile  string
+		expected time.Duration
+	}{
+		{"", "", 2 * time.Second},             // The file's profile setting.
+		{"other", "", 9 * time.Second},        // $CIRCUS_PROFILE beats the file.
+		{"other", "ci", 2 * time.Second},      // An explicit profile beats $CIRCUS_PROFILE.
+		{"", "other", 9 * time.Second},        // An explicit profile beats the file.
+		{"missing", "", 0},                    // Unknown profiles are errors.
+		{"", "missing", 0},                    // Unknown profiles are errors.
+		{"other", "missing", 0},               // Unknown profiles are errors.
+		{"missing", "other", 9 * time.Second}, // Only the chosen profile matters.
+	}
+	for _, testCase := range testCases {
+		restore := setenv(t, map[string]string{"CIRCUS_PROFILE": testCase.env})
+		c, err := Load(path, testCase.profile)
+		restore()
+		if testCase.expected == 0 {
+			if err == nil || !strings.Contains(err.Error(), "no profile named") {
+				t.Errorf("env=%q profile=%q: expected an unknown profile error, got %v", testCase.env, testCase.profile, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("env=%q profile=%q: %s", testCase.env, testCase.profile, err)
+			continue
+		}
+		if actual := c.Fetch.Timeout.D(); actual != testCase.expected {
+			t.Errorf("env=%q profile=%q: expected fetch.timeout %v, got %v", testCase.env, testCase.profile, testCase.expected, actual)
+		}
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"circus.toml", "[fetch]\nuserAgent = \"agent/1\"\n"},
+		{"circus.yaml", "fetch:\n  userAgent: agent/1\n"},
+		{"circus.yml", "fetch:\n  userAgent: agent/1\n"},
+		{"circus.json", `{"fetch": {"userAgent": "agent/1"}}`},
+	}
+	for _, testCase := range testCases {
+		path, cleanup := writeConfig(t, testCase.name, testCase.content)
+		c, err := Load(path, "")
+		cleanup()
+		if err != nil {
+			t.Errorf("%v: %s", testCase.name, err)
+			continue
+		}
+		if c.Fetch.UserAgent != "agent/1" || c.Source("fetch.userAgent") != SourceFile {
+			t.Errorf("%v: expected fetch.userAgent from the file, got %q from %v", testCase.name, c.Fetch.UserAgent, c.Source("fetch.userAgent"))
+		}
+	}
+}
+
+func TestLoadRejectsInvalid(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{"[fetch]\nuserAgnet = \"typo\"\n", "unknown field"},
+		{"[serve]\nworkers = 0\n", "serve.workers must be at least 1"},
+		{"[serve]\njobRetention = \"-1h\"\n", "serve.jobRetention must not be negative"},
+		{"[fetch]\ntimeout = 10\n", "durations must be strings"},
+		{"profiles = \"ci\"\n", "profiles must be a table"},
+	}
+	for _, testCase := range testCases {
+		path, cleanup := writeConfig(t, "circus.toml", testCase.content)
+		_, err := Load(path, "")
+		cleanup()
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", testCase.content, testCase.expected, err)
+		}
+	}
+
+	defer setenv(t, map[string]string{"CIRCUS_HN_CONCURRENCY": "lots"})()
+	if _, err := Load("", ""); err == nil || !strings.Contains(err.Error(), "CIRCUS_HN_CONCURRENCY") {
+		t.Errorf("expected an invalid environment variable error, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+// Redacted replaces the values of secret settings when printed.
+const Redacted = "***"
+
+// Field is a single setting.
+type Field struct {
+	Key    string // Dotted name, e.g. "fetch.timeout".
+	Env    string // Environment variable, e.g. "CIRCUS_FETCH_TIMEOUT".
+	Alias  string // Legacy environment variable, if any.
+	Value  string
+	Source string
+	Secret bool // Tagged `secret:"true"`; never printed.
+
+	value reflect.Value
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// Fields lists every setting in declaration order.
+func (c *Config) Fields() []*Field {
+	var fields []*Field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			key := prefix + name
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			f := &Field{
+				Key:    key,
+				Env:    envName(key),
+				Alias:  sf.Tag.Get("env"),
+				Value:  formatValue(v.Field(i)),
+				Source: c.Source(key),
+				Secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return fields
+}
+
+// Field returns the setting named key, or nil.
+func (c *Config) Field(key string) *Field {
+	for _, f := range c.Fields() {
+		if f.Key == key {
+			return f
+		}
+	}
+	return nil
+}
+
+// envName converts a dotted key to its environment variable, e.g.
+// "hydrator.assetsURL" to "CIRCUS_HYDRATOR_ASSETS_URL".
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for _, part := range strings.Split(key, ".") {
+		if b.Len() > len(EnvPrefix) {
+			b.WriteByte('_')
+		}
+		runes := []rune(part)
+		for i, r := range runes {
+			// Break before an upper case letter which starts a new word.
+			if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// set parses s into the field's value.
+func (f *Field) set(s string) error {
+	v := f.value
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		f.Value = formatValue(v)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported setting type %v", v.Type())
+	}
+	f.Value = formatValue(v)
+	return nil
+}
+
+// applyEnv overrides settings from CIRCUS_* environment variables, and their
+// legacy aliases where those are unset.
+func (c *Config) applyEnv() error {
+	for _, f := range c.Fields() {
+		name := f.Env
+		s, ok := os.LookupEnv(name)
+		if !ok && f.Alias != "" {
+			name = f.Alias
+			s, ok = os.LookupEnv(name)
+		}
+		if !ok {
+			continue
+		}
+		if err := f.set(s); err != nil {
+			return fmt.Errorf("invalid %v=%q: %s", name, s, err)
+		}
+		c.sources[f.Key] = SourceEnv
+	}
+	return nil
+}
+
+// ApplyFlags reconciles the configuration with command-line flags.
+// Bindings maps flag names to setting keys; flags missing from the flag set
+// are ignored.  Flags given on the command line override the configuration,
+// while flags left at their defaults are set from any non-default setting.
+func (c *Config) ApplyFlags(flags *pflag.FlagSet, bindings map[string]string) error {
+	for name, key := range bindings {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			continue
+		}
+		f := c.Field(key)
+		if f == nil {
+			return fmt.Errorf("flag --%v bound to unknown setting %q", name, key)
+		}
+		if flag.Changed {
+			if err := f.set(flag.Value.String()); err != nil {
+				return fmt.Errorf("invalid --%v: %s", name, err)
+			}
+			c.sources[key] = SourceFlag
+			continue
+		}
+		if f.Source != SourceDefault {
+			if err := flags.Set(name, f.Value); err != nil {
+				return fmt.Errorf("setting --%v from %v: %s", name, key, err)
+			}
+		}
+	}
+	return c.Validate()
+}
+
+// Print writes the effective settings to w in the given format: "text"
+// (with the source of each value), "json", or "shell" (NAME='value' lines
+// suitable for eval).  Secret settings are shown as Redacted, and left out of
+// the shell format so that eval cannot clobber them.
+func (c *Config) Print(w io.Writer, format string) error {
+	c = c.redacted()
+	switch format {
+	case "text":
+		if c.Path != "" {
+			fmt.Fprintf(w, "# file: %v\n", c.Path)
+		}
+		if c.Profile != "" {
+			fmt.Fprintf(w, "# profile: %v\n", c.Profile)
+		}
+		for _, f := range c.Fields() {
+			fmt.Fprintf(w, "%-26v = %-40q # %v\n", f.Key, f.Value, f.Source)
+		}
+	case "json":
+		bs, err := json.MarshalIndent(c, "", "    ")
+		if err != nil {
+			return fmt.Errorf("serializing config: %s", err)
+		}
+		fmt.Fprintln(w, string(bs))
+	case "shell":
+		for _, f := range c.Fields() {
+			if f.Secret && f.Value != "" {
+				fmt.Fprintf(w, "# %v is secret and not printed\n", f.Env)
+				continue
+			}
+			fmt.Fprintf(w, "%v='%v'\n", f.Env, strings.Replace(f.Value, "'", `'\''`, -1))
+		}
+	default:
+		return fmt.Errorf("unrecognized format %q, must be one of text, json or shell", format)
+	}
+	return nil
+}
+
+// redacted returns a copy of c with the values of secret settings replaced
+// by Redacted.
+func (c *Config) redacted() *Config {
+	out := *c
+	for _, f := range out.Fields() {
+		if f.Secret && f.Value != "" {
+			f.value.SetString(Redacted)
+		}
+	}
+	return &out
+}
+
+// AddFlags registers the --config and --profile flags on flags.
+func AddFlags(flags *pflag.FlagSet, path *string, profile *string) {
+	flags.StringVarP(path, "config", "", "", "Path to TOML, YAML or JSON config file (default: $CIRCUS_CONFIG, then circus.toml or circus.yaml in the working directory or ~/.config/circus)")
+	flags.StringVarP(profile, "profile", "", "", "Config profile to apply (default: $CIRCUS_PROFILE, then the config file's profile setting)")
+}
+
+// FromFlags loads the config file at path (or DefaultPath when empty) with
+// the given profile, and reconciles it with flags according to bindings.
+func FromFlags(flags *pflag.FlagSet, path string, profile string, bindings map[string]string) (*Config, error) {
+	if path == "" {
+		path = DefaultPath()
+	}
+	c, err := Load(path, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ApplyFlags(flags, bindings); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	const token = "ghp_0123456789abcdefghij"
+
+	c := Default()
+	c.Forge.GitHubToken = token
+
+	for _, format := range []string{"text", "json", "shell"} {
+		buf := &bytes.Buffer{}
+		if err := c.Print(buf, format); err != nil {
+			t.Fatalf("%v: %s", format, err)
+		}
+		out := buf.String()
+		if strings.Contains(out, token) {
+			t.Errorf("%v: token printed verbatim:\n%s", format, out)
+		}
+		switch format {
+		case "text":
+			if !strings.Contains(out, `forge.githubToken          = "***"`) {
+				t.Errorf("text: expected redacted token, got:\n%s", out)
+			}
+		case "json":
+			if !strings.Contains(out, `"githubToken": "***"`) {
+				t.Errorf("json: expected redacted token, got:\n%s", out)
+			}
+		case "shell":
+			if strings.Contains(out, "CIRCUS_FORGE_GITHUB_TOKEN=") {
+				t.Errorf("shell: expected no token assignment, got:\n%s", out)
+			}
+			// Unset secrets are harmless to print.
+			if !strings.Contains(out, "CIRCUS_FORGE_GITLAB_TOKEN=''") {
+				t.Errorf("shell: expected empty gitlab token, got:\n%s", out)
+			}
+		}
+	}
+
+	if c.Forge.GitHubToken != token {
+		t.Errorf("Print modified the config: token is now %q", c.Forge.GitHubToken)
+	}
+}
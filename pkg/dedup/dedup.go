@@ -0,0 +1,197 @@
+package dedup
+
+// Groups stories which refer to the same article, whether by canonical URL or
+// by near-identical article text.
+
+import (
+	"sort"
+
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/canonical"
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+// Options controls how aggressively stories are grouped.
+type Options struct {
+	MaxDistance int // Maximum SimHash Hamming distance between near-duplicates; negative disables text matching.
+	MinWords    int // Articles with fewer words are only grouped by URL.
+}
+
+// DefaultOptions are tuned to catch re-submissions of the same article without
+// merging distinct articles which share boilerplate.
+var DefaultOptions = Options{
+	MaxDistance: 3,
+	MinWords:    100,
+}
+
+// Cluster is a group of stories about the same article.
+type Cluster struct {
+	Primary *domain.Context
+	Others  []*domain.Context
+}
+
+// Group clusters contexts and returns one cluster per distinct article, in
+// order of first appearance.  Each primary story with duplicates has its
+// Discussions populated with every member's story, primary first.
+func Group(contexts []*domain.Context, opts Options) []*Cluster {
+	uf := newUnionFind(len(contexts))
+
+	byURL := map[string]int{}
+	for i, ctx := range contexts {
+		key := canonical.Clean(ctx.CanonicalURL())
+		if key == "" {
+			continue
+		}
+		if j, ok := byURL[key]; ok {
+			uf.union(i, j)
+		} else {
+			byURL[key] = i
+		}
+	}
+
+	if opts.MaxDistance >= 0 {
+		groupByText(contexts, opts, uf)
+	}
+
+	var (
+		clusters []*Cluster
+		members  = map[int][]int{}
+		order    []int
+	)
+	for i := range contexts {
+		root := uf.find(i)
+		if _, ok := members[root]; !ok {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	for _, root := range order {
+		idxs := members[root]
+		sort.SliceStable(idxs, func(a, b int) bool {
+			return better(contexts[idxs[a]], contexts[idxs[b]])
+		})
+		cluster := &Cluster{
+			Primary: contexts[idxs[0]],
+		}
+		for _, idx := range idxs[1:] {
+			cluster.Others = append(cluster.Others, contexts[idx])
+		}
+		if len(cluster.Others) > 0 {
+			cluster.Primary.Discussions = discussions(cluster)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// groupByText unions stories whose article text fingerprints are within
+// opts.MaxDistance of each other.  Fingerprints are split into
+// MaxDistance+1 bands; by the pigeonhole principle near-duplicates must
+// agree exactly on at least one band, so only stories sharing a band are
+// compared.
+func groupByText(contexts []*domain.Context, opts Options, uf *unionFind) {
+	var (
+		nBands       = opts.MaxDistance + 1
+		width        = 64 / nBands
+		fingerprints = map[int]uint64{}
+		buckets      = map[[2]uint64][]int{}
+	)
+	if width < 1 {
+		width = 1
+		nBands = 64
+	}
+
+	for i, ctx := range contexts {
+		if ctx.Article == nil || ctx.Article.Article == nil {
+			continue
+		}
+		words := textmanip.Words(ctx.Article.CleanedText)
+		if len(words) < opts.MinWords {
+			continue
+		}
+		fp := SimHash(words)
+		fingerprints[i] = fp
+		for band := 0; band < nBands; band++ {
+			shift := uint(band * width)
+			bandWidth := uint(width)
+			if band == nBands-1 {
+				bandWidth = 64 - shift
+			}
+			var mask uint64 = 1<<bandWidth - 1
+			if bandWidth == 64 {
+				mask = ^uint64(0)
+			}
+			key := [2]uint64{uint64(band), (fp >> shift) & mask}
+			for _, j := range buckets[key] {
+				if uf.find(i) != uf.find(j) && Distance(fp, fingerprints[j]) <= opts.MaxDistance {
+					uf.union(i, j)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+}
+
+// better reports whether a makes a better primary story than b: stories with
+// extracted content win, followed by points and then comments.
+func better(a *domain.Context, b *domain.Context) bool {
+	if hasText(a) != hasText(b) {
+		return hasText(a)
+	}
+	if a.Story == nil || b.Story == nil {
+		return a.Story != nil
+	}
+	if a.Points != b.Points {
+		return a.Points > b.Points
+	}
+	return a.Comments > b.Comments
+}
+
+func hasText(ctx *domain.Context) bool {
+	return ctx.Article != nil && ctx.Article.Article != nil && len(ctx.Article.CleanedText) > 0
+}
+
+func discussions(cluster *Cluster) []*domain.Story {
+	var stories []*domain.Story
+	for _, ctx := range append([]*domain.Context{cluster.Primary}, cluster.Others...) {
+		if ctx.Story != nil {
+			stories = append(stories, ctx.Story)
+		}
+	}
+	return stories
+}
+
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{
+		parent: make([]int, n),
+	}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+func (uf *unionFind) find(i int) int {
+	for uf.parent[i] != i {
+		uf.parent[i] = uf.parent[uf.parent[i]]
+		i = uf.parent[i]
+	}
+	return i
+}
+
+// union merges the sets containing i and j, keeping the lower index as the
+// root so that cluster order follows input order.
+func (uf *unionFind) union(i int, j int) {
+	ri, rj := uf.find(i), uf.find(j)
+	if ri == rj {
+		return
+	}
+	if rj < ri {
+		ri, rj = rj, ri
+	}
+	uf.parent[rj] = ri
+}
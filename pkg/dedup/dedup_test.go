@@ -0,0 +1,242 @@
+package dedup
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	goose "jaytaylor.com/GoOse"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+// text returns n pseudo-random words drawn from a small vocabulary, the same
+// for the same seed.
+func text(seed int64, n int) string {
+	r := rand.New(rand.NewSource(seed))
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%v", r.Intn(500))
+	}
+	return strings.Join(words, " ")
+}
+
+// edit replaces the ith word of s.
+func edit(s string, i int) string {
+	words := strings.Fields(s)
+	words[i] = "edited"
+	return strings.Join(words, " ")
+}
+
+func story(id string, url string, points int, comments int, text string) *domain.Context {
+	ctx := &domain.Context{
+		Story: &domain.Story{ID: domain.StoryID(id), URL: url, Points: points, Comments: comments},
+	}
+	if text != "" {
+		ctx.Article = &domain.Article{Article: &goose.Article{CleanedText: text}}
+	}
+	return ctx
+}
+
+func TestSimHash(t *testing.T) {
+	var (
+		original = textmanip.Words(text(1, 1000))
+		edited   = textmanip.Words(edit(text(1, 1000), 990))
+		other    = textmanip.Words(text(2, 1000))
+	)
+	if SimHash(original) != SimHash(textmanip.Words(text(1, 1000))) {
+		t.Errorf("expected identical text to produce identical fingerprints")
+	}
+	if d := Distance(SimHash(original), SimHash(edited)); d == 0 || d > DefaultOptions.MaxDistance {
+		t.Errorf("expected a lightly edited text to differ by 1 to %v bits, got %v", DefaultOptions.MaxDistance, d)
+	}
+	if d := Distance(SimHash(original), SimHash(other)); d <= 10 {
+		t.Errorf("expected unrelated texts to be far apart, got distance %v", d)
+	}
+	if fp := SimHash(nil); fp != 0 {
+		t.Errorf("expected an empty fingerprint for no words, got %x", fp)
+	}
+	if SimHash([]string{"one", "two"}) == SimHash([]string{"two", "one"}) {
+		t.Errorf("expected texts shorter than a shingle to still be fingerprinted in order")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		a        uint64
+		b        uint64
+		expected int
+	}{
+		{0, 0, 0},
+		{0xff, 0xff, 0},
+		{0, 1, 1},
+		{0xf0, 0x0f, 8},
+		{0, ^uint64(0), 64},
+		{1 << 63, 1, 2},
+	}
+	for _, testCase := range testCases {
+		if actual := Distance(testCase.a, testCase.b); actual != testCase.expected {
+			t.Errorf("Distance(%x, %x): expected %v, got %v", testCase.a, testCase.b, testCase.expected, actual)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var (
+		article = text(1, 1000)
+		edited  = edit(article, 990)
+		other   = text(2, 1000)
+		short   = text(3, 50)
+	)
+	testCases := []struct {
+		name     string
+		contexts []*domain.Context
+		opts     Options
+		expected [][]string // Story IDs of each cluster, primary first.
+	}{
+		{
+			name: "canonical url",
+			contexts: []*domain.Context{
+				story("a", "https://Example.com:443/post?utm_source=hn", 10, 0, ""),
+				story("b", "https://example.com/post#top", 20, 0, ""),
+				story("c", "https://example.com/other", 30, 0, ""),
+			},
+			opts:     DefaultOptions,
+			expected: [][]string{{"b", "a"}, {"c"}},
+		},
+		{
+			name: "resolved canonical url",
+			contexts: func() []*domain.Context {
+				a := story("a", "https://short.example/x", 1, 0, "")
+				a.URLs = &domain.URLs{Canonical: "https://example.com/post"}
+				return []*domain.Context{a, story("b", "https://example.com/post", 1, 0, "")}
+			}(),
+			opts:     DefaultOptions,
+			expected: [][]string{{"a", "b"}},
+		},
+		{
+			name: "near-duplicate text",
+			contexts: []*domain.Context{
+				story("a", "https://example.com/a", 5, 0, article),
+				story("b", "https://mirror.example/b", 50, 0, edited),
+				story("c", "https://example.com/c", 500, 0, other),
+			},
+			opts:     DefaultOptions,
+			expected: [][]string{{"b", "a"}, {"c"}},
+		},
+		{
+			name: "text matching disabled",
+			contexts: []*domain.Context{
+				story("a", "https://example.com/a", 0, 0, article),
+				story("b", "https://mirror.example/b", 0, 0, article),
+			},
+			opts:     Options{MaxDistance: -1, MinWords: 100},
+			expected: [][]string{{"a"}, {"b"}},
+		},
+		{
+			name: "too short for text matching",
+			contexts: []*domain.Context{
+				story("a", "https://example.com/a", 0, 0, short),
+				story("b", "https://mirror.example/b", 0, 0, short),
+			},
+			opts:     DefaultOptions,
+			expected: [][]string{{"a"}, {"b"}},
+		},
+		{
+			name: "exact text only",
+			contexts: []*domain.Context{
+				story("a", "https://example.com/a", 0, 0, article),
+				story("b", "https://mirror.example/b", 0, 0, edited),
+				story("c", "https://copy.example/c", 0, 0, article),
+			},
+			opts:     Options{MaxDistance: 0, MinWords: 100},
+			expected: [][]string{{"a", "c"}, {"b"}},
+		},
+		{
+			name: "transitive",
+			contexts: []*domain.Context{
+				story("a", "https://example.com/a", 1, 0, ""),
+				story("b", "https://other.example/b", 2, 0, ""),
+				story("c", "https://example.com/a#comments", 3, 0, article),
+				story("d", "https://mirror.example/d", 4, 0, article),
+				story("e", "https://other.example/b", 5, 0, ""),
+			},
+			opts:     DefaultOptions,
+			expected: [][]string{{"d", "c", "a"}, {"e", "b"}},
+		},
+		{
+			name: "no urls",
+			contexts: []*domain.Context{
+				story("a", "", 0, 0, ""),
+				story("b", "", 0, 0, ""),
+			},
+			opts:     DefaultOptions,
+			expected: [][]string{{"a"}, {"b"}},
+		},
+	}
+	for _, testCase := range testCases {
+		clusters := Group(testCase.contexts, testCase.opts)
+		var actual [][]string
+		for _, cluster := range clusters {
+			ids := []string{cluster.Primary.ID.String()}
+			for _, other := range cluster.Others {
+				ids = append(ids, other.ID.String())
+			}
+			actual = append(actual, ids)
+
+			var discussions []string
+			for _, story := range cluster.Primary.Discussions {
+				discussions = append(discussions, story.ID.String())
+			}
+			if len(ids) == 1 {
+				ids = nil
+			}
+			if !reflect.DeepEqual(discussions, ids) {
+				t.Errorf("%v: expected discussions %v, got %v", testCase.name, ids, discussions)
+			}
+		}
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("%v: expected clusters %v, got %v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestBetter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        *domain.Context
+		b        *domain.Context
+		expected bool
+	}{
+		{"text beats points", story("a", "", 1, 0, "text"), story("b", "", 100, 0, ""), true},
+		{"no text loses", story("a", "", 100, 0, ""), story("b", "", 1, 0, "text"), false},
+		{"points", story("a", "", 10, 0, "text"), story("b", "", 5, 50, "text"), true},
+		{"comments break ties", story("a", "", 5, 1, ""), story("b", "", 5, 2, ""), false},
+		{"equal", story("a", "", 5, 2, ""), story("b", "", 5, 2, ""), false},
+		{"story beats none", story("a", "", 0, 0, ""), &domain.Context{}, true},
+		{"none loses", &domain.Context{}, story("b", "", 0, 0, ""), false},
+	}
+	for _, testCase := range testCases {
+		if actual := better(testCase.a, testCase.b); actual != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestUnionFind(t *testing.T) {
+	uf := newUnionFind(6)
+	uf.union(4, 5)
+	uf.union(5, 2)
+	uf.union(0, 1)
+	uf.union(2, 4) // Already merged.
+
+	var roots []int
+	for i := 0; i < 6; i++ {
+		roots = append(roots, uf.find(i))
+	}
+	if expected := []int{0, 0, 2, 3, 2, 2}; !reflect.DeepEqual(roots, expected) {
+		t.Errorf("expected roots %v, got %v", expected, roots)
+	}
+}
@@ -0,0 +1,55 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+// shingleSize is the number of consecutive words hashed together as a
+// feature.
+const shingleSize = 3
+
+// SimHash computes a 64-bit locality-sensitive fingerprint of the word
+// shingles in words.  Similar texts produce fingerprints with a small Hamming
+// distance.
+func SimHash(words []string) uint64 {
+	var (
+		weights [64]int
+		h       = fnv.New64a()
+	)
+
+	n := len(words) - shingleSize + 1
+	if n < 1 && len(words) > 0 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		h.Reset()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		sum := h.Sum64()
+		for bit := uint(0); bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := uint(0); bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Distance returns the Hamming distance between two fingerprints.
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,282 @@
+package fetchpolicy
+
+// Per-domain politeness settings for outbound HTTP fetches.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"jaytaylor.com/circus/pkg/version"
+)
+
+// DefaultUserAgent is sent when no policy specifies a user-agent.  It
+// identifies the crawler honestly, with a link for site operators.
+var DefaultUserAgent = "circus/" + version.Version + " (+https://jaytaylor.com/circus)"
+
+// DomainPolicy holds the fetch settings for a single domain.  Zero values
+// inherit from the default policy.
+type DomainPolicy struct {
+	UserAgent      string            `json:"userAgent,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Cookies        map[string]string `json:"cookies,omitempty"`
+	Delay          Duration          `json:"delay,omitempty"`          // Minimum time between requests to the host.
+	MaxConcurrency int               `json:"maxConcurrency,omitempty"` // Maximum in-flight requests to the host.
+	RespectRobots  *bool             `json:"respectRobots,omitempty"`
+}
+
+// merge returns a copy of p with any non-zero settings from override applied.
+func (p DomainPolicy) merge(override DomainPolicy) DomainPolicy {
+	out := p
+	out.Headers = map[string]string{}
+	out.Cookies = map[string]string{}
+	for k, v := range p.Headers {
+		out.Headers[k] = v
+	}
+	for k, v := range p.Cookies {
+		out.Cookies[k] = v
+	}
+	for k, v := range override.Headers {
+		out.Headers[k] = v
+	}
+	for k, v := range override.Cookies {
+		out.Cookies[k] = v
+	}
+	if override.UserAgent != "" {
+		out.UserAgent = override.UserAgent
+	}
+	if override.Delay > 0 {
+		out.Delay = override.Delay
+	}
+	if override.MaxConcurrency > 0 {
+		out.MaxConcurrency = override.MaxConcurrency
+	}
+	if override.RespectRobots != nil {
+		out.RespectRobots = override.RespectRobots
+	}
+	return out
+}
+
+// Policy is the complete fetch policy configuration, plus the runtime state
+// needed to enforce it.
+type Policy struct {
+	Default DomainPolicy            `json:"default"`
+	Domains map[string]DomainPolicy `json:"domains"` // Keyed by hostname; also applies to subdomains.
+
+	// RobotsTTL controls how long fetched robots.txt files are cached for.
+	RobotsTTL Duration `json:"robotsTTL,omitempty"`
+
+	// StateDir, when set, persists robots.txt files and per-host last-request
+	// times so limits hold across separate hydrator invocations.
+	StateDir string `json:"stateDir,omitempty"`
+
+	mu     sync.Mutex
+	hosts  map[string]*hostState
+	robots map[string]*robotsEntry
+}
+
+type hostState struct {
+	sem  chan struct{}
+	mu   sync.Mutex
+	last time.Time
+}
+
+// New returns a policy with sensible defaults.
+func New() *Policy {
+	p := &Policy{
+		Default: DomainPolicy{
+			UserAgent:      DefaultUserAgent,
+			MaxConcurrency: 2,
+		},
+		Domains:   map[string]DomainPolicy{},
+		RobotsTTL: Duration(24 * time.Hour),
+	}
+	return p
+}
+
+// Load reads a JSON policy file, layering it on top of the defaults from New.
+func Load(filename string) (*Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading fetch policy %q: %s", filename, err)
+	}
+	loaded := &Policy{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		return nil, fmt.Errorf("parsing fetch policy %q: %s", filename, err)
+	}
+	p := New()
+	p.Default = p.Default.merge(loaded.Default)
+	for host, dp := range loaded.Domains {
+		p.Domains[strings.ToLower(host)] = dp
+	}
+	if loaded.RobotsTTL > 0 {
+		p.RobotsTTL = loaded.RobotsTTL
+	}
+	p.StateDir = loaded.StateDir
+	return p, nil
+}
+
+// For resolves the effective policy for host.  The most specific matching
+// domain entry wins, e.g. "blog.example.com" before "example.com".
+func (p *Policy) For(host string) DomainPolicy {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[0:i]
+	}
+	for candidate := host; candidate != ""; {
+		if dp, ok := p.Domains[candidate]; ok {
+			return p.Default.merge(dp)
+		}
+		i := strings.Index(candidate, ".")
+		if i == -1 {
+			break
+		}
+		candidate = candidate[i+1:]
+	}
+	return p.Default.merge(DomainPolicy{})
+}
+
+// Apply sets the user-agent, headers and cookies configured for the request's
+// host.
+func (p *Policy) Apply(req *http.Request) {
+	dp := p.For(req.URL.Host)
+	if dp.UserAgent != "" {
+		req.Header.Set("User-Agent", dp.UserAgent)
+	}
+	for k, v := range dp.Headers {
+		req.Header.Set(k, v)
+	}
+	for name, value := range dp.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+}
+
+// Acquire blocks until a request to host is permitted by the concurrency and
+// delay settings.  The returned function must be invoked once the request is
+// finished.
+func (p *Policy) Acquire(ctx context.Context, host string) (func(), error) {
+	var (
+		dp    = p.For(host)
+		state = p.hostState(host, dp)
+	)
+
+	select {
+	case state.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-state.sem }
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	last := state.last
+	if persisted := p.persistedLast(host); persisted.After(last) {
+		last = persisted
+	}
+	delay := time.Duration(dp.Delay)
+	if crawlDelay := p.crawlDelay(host); crawlDelay > delay {
+		delay = crawlDelay
+	}
+	if wait := last.Add(delay).Sub(time.Now()); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	state.last = time.Now()
+	p.persistLast(host, state.last)
+
+	return release, nil
+}
+
+func (p *Policy) hostState(host string, dp DomainPolicy) *hostState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hosts == nil {
+		p.hosts = map[string]*hostState{}
+	}
+	state, ok := p.hosts[host]
+	if !ok {
+		n := dp.MaxConcurrency
+		if n < 1 {
+			n = 1
+		}
+		state = &hostState{
+			sem: make(chan struct{}, n),
+		}
+		p.hosts[host] = state
+	}
+	return state
+}
+
+func (p *Policy) stateFile(host string, suffix string) string {
+	if p.StateDir == "" {
+		return ""
+	}
+	return filepath.Join(p.StateDir, strings.Replace(host, ":", "_", -1)+suffix)
+}
+
+func (p *Policy) persistedLast(host string) time.Time {
+	filename := p.stateFile(host, ".last")
+	if filename == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+func (p *Policy) persistLast(host string, ts time.Time) {
+	filename := p.stateFile(host, ".last")
+	if filename == "" {
+		return
+	}
+	if err := os.MkdirAll(p.StateDir, os.FileMode(int(0755))); err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(filename, nil, os.FileMode(int(0644))); err != nil {
+		return
+	}
+	os.Chtimes(filename, ts, ts)
+}
+
+// Duration is a time.Duration which serializes to and from JSON as a string
+// such as "1.5s".  Plain numbers are interpreted as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case float64:
+		*d = Duration(t * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("parsing duration %q: %s", t, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration value %s", string(data))
+	}
+	return nil
+}
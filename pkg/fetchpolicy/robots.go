@@ -0,0 +1,341 @@
+package fetchpolicy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// robotsMaxSize caps how much of a robots.txt file will be read.
+	robotsMaxSize = 512 * 1024
+
+	// robotsMaxRedirects is how many redirects are followed when fetching
+	// robots.txt, per RFC 9309.
+	robotsMaxRedirects = 5
+
+	// robotsUnavailableTTL is how long a host whose robots.txt fails with a
+	// server error is treated as disallowing everything before retrying.
+	robotsUnavailableTTL = 10 * time.Minute
+)
+
+// errRobotsUnavailable is returned by fetchRobots for 5xx responses.
+var errRobotsUnavailable = errors.New("robots.txt unavailable")
+
+// DisallowedError is returned when robots.txt forbids fetching a URL.
+type DisallowedError struct {
+	URL string
+}
+
+func (e *DisallowedError) Error() string {
+	return fmt.Sprintf("fetching %v is disallowed by robots.txt", e.URL)
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	fetched time.Time
+	ttl     time.Duration // Overrides Policy.RobotsTTL when non-zero.
+}
+
+// robotsRules is the parsed group of robots.txt directives which applies to
+// our user-agent.
+type robotsRules struct {
+	allow      []*robotsPattern
+	disallow   []*robotsPattern
+	crawlDelay time.Duration
+}
+
+// robotsPattern is an allow or disallow rule path, compiled once when parsed.
+type robotsPattern struct {
+	pattern string
+	expr    *regexp.Regexp
+}
+
+// disallowAll is applied to hosts whose robots.txt is unavailable.
+var disallowAll = &robotsRules{disallow: []*robotsPattern{newRobotsPattern("/")}}
+
+// Allowed reports whether path is permitted.  The longest matching rule wins,
+// with allow rules winning ties.
+func (r *robotsRules) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	best, allowed := -1, true
+	for _, rule := range r.disallow {
+		if len(rule.pattern) > best && rule.match(path) {
+			best, allowed = len(rule.pattern), false
+		}
+	}
+	for _, rule := range r.allow {
+		if len(rule.pattern) >= best && rule.match(path) {
+			best, allowed = len(rule.pattern), true
+		}
+	}
+	return allowed
+}
+
+func (r *robotsPattern) match(path string) bool {
+	return r.expr != nil && r.expr.MatchString(path)
+}
+
+// newRobotsPattern compiles pattern with the "*" and "$" wildcard semantics
+// used by the major crawlers.  Empty patterns match nothing.
+func newRobotsPattern(pattern string) *robotsPattern {
+	rule := &robotsPattern{pattern: pattern}
+	if pattern == "" {
+		return rule
+	}
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[0 : len(pattern)-1]
+	}
+	expr := "^"
+	for i, part := range strings.Split(pattern, "*") {
+		if i > 0 {
+			expr += ".*"
+		}
+		expr += regexp.QuoteMeta(part)
+	}
+	if anchored {
+		expr += "$"
+	}
+	// Compilation cannot fail as every literal part is quoted.
+	rule.expr, _ = regexp.Compile(expr)
+	return rule
+}
+
+// parseRobots extracts the rules applicable to userAgent.  The group with the
+// longest user-agent token contained in userAgent is used, falling back to
+// the "*" group.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	var (
+		scanner    = bufio.NewScanner(r)
+		ua         = strings.ToLower(userAgent)
+		groups     = map[string]*robotsRules{}
+		current    []string
+		inRules    bool
+		bestAgent  string
+		bestLength = -1
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[0:i]
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[0:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			current = append(current, agent)
+			if _, ok := groups[agent]; !ok {
+				groups[agent] = &robotsRules{}
+			}
+		case "allow", "disallow", "crawl-delay":
+			inRules = true
+			for _, agent := range current {
+				rules := groups[agent]
+				switch key {
+				case "allow":
+					rules.allow = append(rules.allow, newRobotsPattern(value))
+				case "disallow":
+					if value != "" {
+						rules.disallow = append(rules.disallow, newRobotsPattern(value))
+					}
+				case "crawl-delay":
+					if secs, err := strconv.ParseFloat(value, 64); err == nil {
+						rules.crawlDelay = time.Duration(secs * float64(time.Second))
+					}
+				}
+			}
+		}
+	}
+
+	for agent := range groups {
+		if agent != "*" && strings.Contains(ua, agent) && len(agent) > bestLength {
+			bestAgent, bestLength = agent, len(agent)
+		}
+	}
+	if bestLength == -1 {
+		bestAgent = "*"
+	}
+	return groups[bestAgent]
+}
+
+// Allowed reports whether robots.txt permits fetching u.  Policies which do
+// not opt in to robots.txt compliance always permit.
+func (p *Policy) Allowed(ctx context.Context, rt http.RoundTripper, u *url.URL) (bool, error) {
+	dp := p.For(u.Host)
+	if dp.RespectRobots == nil || !*dp.RespectRobots {
+		return true, nil
+	}
+	rules, err := p.robotsFor(ctx, rt, u, dp.UserAgent)
+	if err != nil {
+		return false, err
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.Allowed(path), nil
+}
+
+func (p *Policy) crawlDelay(host string) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.robots[host]; ok && entry.rules != nil {
+		return entry.rules.crawlDelay
+	}
+	return 0
+}
+
+func (p *Policy) robotsFor(ctx context.Context, rt http.RoundTripper, u *url.URL, userAgent string) (*robotsRules, error) {
+	p.mu.Lock()
+	entry, ok := p.robots[u.Host]
+	p.mu.Unlock()
+	if ok {
+		ttl := time.Duration(p.RobotsTTL)
+		if entry.ttl > 0 {
+			ttl = entry.ttl
+		}
+		if time.Now().Sub(entry.fetched) < ttl {
+			return entry.rules, nil
+		}
+	}
+
+	data, fetched, err := p.cachedRobots(u.Host)
+	if err != nil {
+		if data, err = fetchRobots(ctx, rt, u, userAgent); err == errRobotsUnavailable {
+			// RFC 9309 section 2.3.1.3: assume complete disallow while the
+			// server is erroring, and retry later.  Not persisted.
+			entry = &robotsEntry{
+				rules:   disallowAll,
+				fetched: time.Now(),
+				ttl:     robotsUnavailableTTL,
+			}
+			p.storeRobots(u.Host, entry)
+			return entry.rules, nil
+		} else if err != nil {
+			return nil, err
+		}
+		fetched = time.Now()
+		if filename := p.stateFile(u.Host, ".robots.txt"); filename != "" {
+			if err := os.MkdirAll(p.StateDir, os.FileMode(int(0755))); err == nil {
+				ioutil.WriteFile(filename, data, os.FileMode(int(0644)))
+			}
+		}
+	}
+
+	entry = &robotsEntry{
+		rules:   parseRobots(strings.NewReader(string(data)), userAgent),
+		fetched: fetched,
+	}
+	p.storeRobots(u.Host, entry)
+	return entry.rules, nil
+}
+
+func (p *Policy) storeRobots(host string, entry *robotsEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.robots == nil {
+		p.robots = map[string]*robotsEntry{}
+	}
+	p.robots[host] = entry
+}
+
+// cachedRobots returns the on-disk robots.txt for host if it has not expired.
+func (p *Policy) cachedRobots(host string) ([]byte, time.Time, error) {
+	filename := p.stateFile(host, ".robots.txt")
+	if filename == "" {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if time.Now().Sub(fi.ModTime()) >= time.Duration(p.RobotsTTL) {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, fi.ModTime(), nil
+}
+
+// fetchRobots downloads robots.txt for the host of u, following up to
+// robotsMaxRedirects redirects.  Missing robots.txt files and 4xx responses
+// mean everything is allowed, and are represented as empty content.  Server
+// errors produce errRobotsUnavailable.
+func fetchRobots(ctx context.Context, rt http.RoundTripper, u *url.URL, userAgent string) ([]byte, error) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequest("GET", robotsURL.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating robots.txt request to %v: %s", robotsURL, err)
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("User-Agent", userAgent)
+
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %v: %s", robotsURL, err)
+		}
+
+		switch resp.StatusCode / 100 {
+		case 2:
+			data, err := ioutil.ReadAll(io.LimitReader(resp.Body, robotsMaxSize))
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading %v: %s", robotsURL, err)
+			}
+			return data, nil
+		case 3:
+			location := resp.Header.Get("Location")
+			resp.Body.Close()
+			if location == "" || redirects >= robotsMaxRedirects {
+				// Treated as unavailable, like other unfollowable responses.
+				return []byte{}, nil
+			}
+			next, err := robotsURL.Parse(location)
+			if err != nil {
+				return nil, fmt.Errorf("parsing robots.txt redirect location %q: %s", location, err)
+			}
+			robotsURL = next
+		case 4:
+			resp.Body.Close()
+			return []byte{}, nil
+		case 5:
+			resp.Body.Close()
+			return nil, errRobotsUnavailable
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %v: received unexpected response status code=%v", robotsURL, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package fetchpolicy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /private
+Allow: /private/ok$
+Disallow: /*.pdf$
+`), "circus/dev")
+
+	testCases := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/private", false},
+		{"/private/x", false},
+		{"/private/ok", true},
+		{"/private/ok/x", false},
+		{"/paper.pdf", false},
+		{"/paper.pdf?x=1", true},
+	}
+	for _, testCase := range testCases {
+		if actual := rules.Allowed(testCase.path); actual != testCase.allowed {
+			t.Errorf("Allowed(%q) = %v, expected %v", testCase.path, actual, testCase.allowed)
+		}
+	}
+}
+
+func TestFetchRobotsRedirects(t *testing.T) {
+	hops := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+		case "/hop":
+			hops++
+			http.Redirect(w, r, "/final.txt", http.StatusFound)
+		case "/final.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /secret\n"))
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL + "/secret")
+	data, err := fetchRobots(context.Background(), http.DefaultTransport, u, "circus/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Disallow: /secret"; !strings.Contains(string(data), expected) {
+		t.Errorf("expected redirected robots.txt containing %q, got %q", expected, string(data))
+	}
+	if hops != 1 {
+		t.Errorf("expected 1 intermediate hop, got %v", hops)
+	}
+}
+
+func TestRobotsServerErrorDisallows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	respect := true
+	p := New()
+	p.Default.RespectRobots = &respect
+	u, _ := url.Parse(server.URL + "/page")
+	allowed, err := p.Allowed(context.Background(), http.DefaultTransport, u)
+	if err != nil {
+		t.Fatalf("expected 5xx robots.txt to disallow rather than error, got %s", err)
+	}
+	if allowed {
+		t.Error("expected 5xx robots.txt to disallow fetching")
+	}
+	if entry := p.robots[u.Host]; entry == nil || entry.ttl != robotsUnavailableTTL {
+		t.Errorf("expected unavailable robots.txt to be cached for %v", robotsUnavailableTTL)
+	}
+}
@@ -0,0 +1,72 @@
+package fetchpolicy
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport is an http.RoundTripper which enforces a Policy for every request
+// passing through it, including redirects.
+type Transport struct {
+	Base   http.RoundTripper
+	Policy *Policy
+}
+
+// NewTransport wraps base with policy enforcement.  A nil base means
+// http.DefaultTransport.
+func NewTransport(base http.RoundTripper, policy *Policy) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{
+		Base:   base,
+		Policy: policy,
+	}
+	return t
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	allowed, err := t.Policy.Allowed(req.Context(), t.Base, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, &DisallowedError{URL: req.URL.String()}
+	}
+
+	release, err := t.Policy.Acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per the http.RoundTripper contract the original request must not be
+	// modified.
+	clone := req.WithContext(req.Context())
+	clone.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		clone.Header[k] = append([]string(nil), v...)
+	}
+	t.Policy.Apply(clone)
+
+	resp, err := t.Base.RoundTrip(clone)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// Hold the concurrency slot until the caller is done with the body.
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
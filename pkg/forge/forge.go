@@ -0,0 +1,161 @@
+package forge
+
+// Clients for code forge APIs (GitHub, GitLab), which describe repository
+// links far better than their HTML pages do.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var blankLinesExpr = regexp.MustCompile(`[ \t]*\n\s*\n\s*`)
+
+// Repo is a code repository's metadata and README.
+type Repo struct {
+	Forge         string     `json:"forge"` // Name of the forge, e.g. "github".
+	Path          string     `json:"path"`  // Owner and name, e.g. "golang/go".
+	URL           string     `json:"url"`
+	Description   string     `json:"description,omitempty"`
+	Stars         int        `json:"stars"`
+	Forks         int        `json:"forks"`
+	Language      string     `json:"language,omitempty"` // Predominant language.
+	License       string     `json:"license,omitempty"`  // SPDX identifier when known, otherwise the name.
+	Topics        []string   `json:"topics,omitempty"`
+	DefaultBranch string     `json:"defaultBranch,omitempty"`
+	LastCommit    *time.Time `json:"lastCommit,omitempty"` // Of the default branch.
+	AvatarURL     string     `json:"avatarURL,omitempty"`
+	Archived      bool       `json:"archived,omitempty"`
+
+	// README is the rendered README HTML, whose relative links and images
+	// resolve against READMEBase.
+	README     string `json:"-"`
+	READMEBase string `json:"-"`
+}
+
+// Forge fetches repositories from one forge.
+type Forge interface {
+	Name() string
+
+	// Parse returns the path of the repository whose main page is url, and
+	// false when url is not a repository page on this forge.
+	Parse(url string) (string, bool)
+
+	// Repo fetches the repository at path.
+	Repo(ctx context.Context, path string) (*Repo, error)
+}
+
+// READMEText returns the README with markup removed and blocks separated by
+// blank lines.
+func (r *Repo) READMEText() string {
+	var (
+		buf  = &strings.Builder{}
+		z    = html.NewTokenizer(strings.NewReader(r.README))
+		skip int
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(blankLinesExpr.ReplaceAllString(buf.String(), "\n\n"))
+		case html.TextToken:
+			if skip == 0 {
+				buf.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Script, atom.Style, atom.Svg:
+				skip++
+			case atom.P, atom.Br, atom.Pre, atom.Li, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Tr, atom.Div, atom.Blockquote:
+				buf.WriteString("\n\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if a := atom.Lookup(name); (a == atom.Script || a == atom.Style || a == atom.Svg) && skip > 0 {
+				skip--
+			}
+		}
+	}
+}
+
+// pathSegments returns the non-empty path segments of rawURL when its host
+// is host (ignoring any "www." prefix), otherwise nil.
+func pathSegments(rawURL string, host string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	if !strings.EqualFold(strings.TrimPrefix(u.Hostname(), "www."), host) {
+		return nil
+	}
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// getJSON fetches u and unmarshals the response body into v.
+func getJSON(ctx context.Context, client *http.Client, u string, header http.Header, v interface{}) error {
+	body, err := get(ctx, client, u, header)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unmarshalling %v: %s", u, err)
+	}
+	return nil
+}
+
+// errNotFound is returned by get for 404 responses.
+type errNotFound string
+
+func (e errNotFound) Error() string {
+	return fmt.Sprintf("fetching %v: not found", string(e))
+}
+
+func get(ctx context.Context, client *http.Client, u string, header http.Header) ([]byte, error) {
+	return do(ctx, client, "GET", u, header, nil)
+}
+
+func do(ctx context.Context, client *http.Client, method string, u string, header http.Header, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request to %v: %s", u, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %v: %s", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound(u)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("fetching %v: non-2xx response status-code=%v", u, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %s", u, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,366 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGitHubParse(t *testing.T) {
+	g := NewGitHub()
+	testCases := []struct {
+		url  string
+		path string
+		ok   bool
+	}{
+		{"https://github.com/golang/go", "golang/go", true},
+		{"https://www.github.com/golang/go/", "golang/go", true},
+		{"http://github.com/golang/go.git", "golang/go", true},
+		{"https://github.com/golang/go/tree/master", "golang/go", true},
+		{"https://github.com/golang/go/tree/release-branch.go1.11/src", "golang/go", true},
+		{"https://github.com/golang/go?tab=readme", "golang/go", true},
+		{"https://github.com/golang", "", false},
+		{"https://github.com/golang/go/issues/1", "", false},
+		{"https://github.com/golang/go/blob/master/README.md", "", false},
+		{"https://github.com/topics/go", "", false},
+		{"https://github.com/Explore/repos", "", false},
+		{"https://github.com/orgs/golang", "", false},
+		{"https://gist.github.com/golang/go", "", false},
+		{"https://gitlab.com/golang/go", "", false},
+		{"ftp://github.com/golang/go", "", false},
+	}
+	for _, testCase := range testCases {
+		path, ok := g.Parse(testCase.url)
+		if path != testCase.path || ok != testCase.ok {
+			t.Errorf("Parse(%q) = %q, %v, expected %q, %v", testCase.url, path, ok, testCase.path, testCase.ok)
+		}
+	}
+}
+
+func TestGitLabParse(t *testing.T) {
+	g := NewGitLab()
+	testCases := []struct {
+		url  string
+		path string
+		ok   bool
+	}{
+		{"https://gitlab.com/gitlab-org/gitlab", "gitlab-org/gitlab", true},
+		{"https://gitlab.com/gitlab-org/gitlab.git", "gitlab-org/gitlab", true},
+		{"https://gitlab.com/gitlab-org/gitlab/-/tree/master", "gitlab-org/gitlab", true},
+		{"https://gitlab.com/gitlab-org/charts/gitlab-runner", "gitlab-org/charts/gitlab-runner", true},
+		{"https://gitlab.com/a/b/c/d/-/tree/main/docs", "a/b/c/d", true},
+		{"https://gitlab.com/gitlab-org", "", false},
+		{"https://gitlab.com/gitlab-org/gitlab/-/issues/1", "", false},
+		{"https://gitlab.com/gitlab-org/gitlab/-/blob/master/README.md", "", false},
+		{"https://gitlab.com/gitlab-org/gitlab/-", "", false},
+		{"https://gitlab.com/explore/projects", "", false},
+		{"https://gitlab.com/groups/gitlab-org/charts", "", false},
+		{"https://gitlab.com/users/sign_in", "", false},
+		{"https://github.com/gitlab-org/gitlab", "", false},
+	}
+	for _, testCase := range testCases {
+		path, ok := g.Parse(testCase.url)
+		if path != testCase.path || ok != testCase.ok {
+			t.Errorf("Parse(%q) = %q, %v, expected %q, %v", testCase.url, path, ok, testCase.path, testCase.ok)
+		}
+	}
+}
+
+// fakeForge serves canned responses keyed by escaped request path and query.
+type fakeForge struct {
+	responses map[string]string
+	statuses  map[string]int
+	requests  []*http.Request
+}
+
+func (f *fakeForge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	key := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		key += "?" + r.URL.RawQuery
+	}
+	if status, ok := f.statuses[key]; ok {
+		http.Error(w, `{"message":"error"}`, status)
+		return
+	}
+	body, ok := f.responses[key]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(body))
+}
+
+func newFakeForge(f *fakeForge) (*GitHub, *GitLab, func()) {
+	server := httptest.NewServer(f)
+	github := NewGitHub()
+	github.BaseURL = server.URL
+	github.Token = "gh-token"
+	gitlab := NewGitLab()
+	gitlab.BaseURL = server.URL + "/api/v4"
+	gitlab.Token = "gl-token"
+	return github, gitlab, server.Close
+}
+
+const githubRepoJSON = `{
+	"full_name": "golang/go",
+	"html_url": "https://github.com/golang/go",
+	"description": "The Go programming language",
+	"stargazers_count": 100000,
+	"forks_count": 15000,
+	"language": "Go",
+	"topics": ["go", "language"],
+	"default_branch": "master",
+	"pushed_at": "2018-06-01T10:00:00Z",
+	"license": {"spdx_id": "BSD-3-Clause", "name": "BSD 3-Clause \"New\" or \"Revised\" License"},
+	"owner": {"avatar_url": "https://avatars.githubusercontent.com/u/4314092"}
+}`
+
+func TestGitHubRepo(t *testing.T) {
+	f := &fakeForge{
+		responses: map[string]string{
+			"/repos/golang/go":                    githubRepoJSON,
+			"/repos/golang/go/readme":             `<div id="readme"><h1>The Go Programming Language</h1><p>Go is an open source programming language.</p></div>`,
+			"/repos/golang/go/commits?per_page=1": `[{"commit": {"committer": {"date": "2018-06-01T12:00:00Z"}}}]`,
+		},
+	}
+	github, _, done := newFakeForge(f)
+	defer done()
+
+	repo, err := github.Repo(context.Background(), "golang/go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Repo{
+		Forge:         "github",
+		Path:          "golang/go",
+		URL:           "https://github.com/golang/go",
+		Description:   "The Go programming language",
+		Stars:         100000,
+		Forks:         15000,
+		Language:      "Go",
+		License:       "BSD-3-Clause",
+		Topics:        []string{"go", "language"},
+		DefaultBranch: "master",
+		AvatarURL:     "https://avatars.githubusercontent.com/u/4314092",
+	}
+	checkRepo(t, repo, expected, time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	if expected := "https://github.com/golang/go/raw/master/"; repo.READMEBase != expected {
+		t.Errorf("expected README base %q, got %q", expected, repo.READMEBase)
+	}
+	if expected := "The Go Programming Language\n\nGo is an open source programming language."; repo.READMEText() != expected {
+		t.Errorf("expected README text %q, got %q", expected, repo.READMEText())
+	}
+
+	for _, r := range f.requests {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer gh-token" {
+			t.Errorf("%v: expected token to be sent, got Authorization %q", r.URL, auth)
+		}
+	}
+	if accept := f.requests[1].Header.Get("Accept"); accept != "application/vnd.github.html+json" {
+		t.Errorf("expected README to be requested as HTML, got Accept %q", accept)
+	}
+}
+
+func TestGitHubRepoWithoutReadmeOrCommits(t *testing.T) {
+	// GitHub answers 409 Conflict for the commits of an empty repository.
+	f := &fakeForge{
+		responses: map[string]string{
+			"/repos/golang/go": githubRepoJSON,
+		},
+		statuses: map[string]int{
+			"/repos/golang/go/commits?per_page=1": http.StatusConflict,
+		},
+	}
+	github, _, done := newFakeForge(f)
+	defer done()
+
+	repo, err := github.Repo(context.Background(), "golang/go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.README != "" {
+		t.Errorf("expected no README, got %q", repo.README)
+	}
+	if repo.LastCommit == nil || !repo.LastCommit.Equal(time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected last commit to fall back to pushed_at, got %v", repo.LastCommit)
+	}
+}
+
+func TestGitHubRepoErrors(t *testing.T) {
+	f := &fakeForge{
+		responses: map[string]string{
+			"/repos/golang/go": githubRepoJSON,
+		},
+		statuses: map[string]int{
+			"/repos/golang/go/readme": http.StatusInternalServerError,
+		},
+	}
+	github, _, done := newFakeForge(f)
+	defer done()
+
+	if _, err := github.Repo(context.Background(), "golang/missing"); err == nil {
+		t.Errorf("expected an error for a missing repository")
+	} else if _, ok := err.(errNotFound); !ok {
+		t.Errorf("expected errNotFound for a missing repository, got %T: %s", err, err)
+	}
+	if _, err := github.Repo(context.Background(), "golang/go"); err == nil {
+		t.Errorf("expected an error when the README cannot be fetched")
+	}
+}
+
+const gitlabProjectJSON = `{
+	"path_with_namespace": "gitlab-org/charts/gitlab-runner",
+	"web_url": "https://gitlab.com/gitlab-org/charts/gitlab-runner",
+	"description": "GitLab Runner Helm chart",
+	"star_count": 120,
+	"forks_count": 80,
+	"topics": ["helm"],
+	"default_branch": "main",
+	"last_activity_at": "2018-06-01T10:00:00Z",
+	"avatar_url": "https://gitlab.com/uploads/avatar.png",
+	"readme_url": "https://gitlab.com/gitlab-org/charts/gitlab-runner/-/blob/main/docs/README.md",
+	"license": {"key": "mit", "name": "MIT License", "nickname": ""}
+}`
+
+func TestGitLabRepo(t *testing.T) {
+	const project = "/api/v4/projects/gitlab-org%2Fcharts%2Fgitlab-runner"
+	f := &fakeForge{
+		responses: map[string]string{
+			project + "?license=true": gitlabProjectJSON,
+			project + "/languages":    `{"Go Template": 40.5, "Shell": 59.5}`,
+			project + "/repository/files/docs%2FREADME.md/raw?ref=main": "# Runner\n\nInstalls the runner.",
+			project + "/repository/commits?per_page=1":                  `[{"committed_date": "2018-06-01T12:00:00Z"}]`,
+			"/api/v4/markdown": `{"html": "<h1>Runner</h1><p>Installs the runner.</p>"}`,
+		},
+	}
+	_, gitlab, done := newFakeForge(f)
+	defer done()
+
+	repo, err := gitlab.Repo(context.Background(), "gitlab-org/charts/gitlab-runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Repo{
+		Forge:         "gitlab",
+		Path:          "gitlab-org/charts/gitlab-runner",
+		URL:           "https://gitlab.com/gitlab-org/charts/gitlab-runner",
+		Description:   "GitLab Runner Helm chart",
+		Stars:         120,
+		Forks:         80,
+		Language:      "Shell",
+		License:       "MIT License",
+		Topics:        []string{"helm"},
+		DefaultBranch: "main",
+		AvatarURL:     "https://gitlab.com/uploads/avatar.png",
+	}
+	checkRepo(t, repo, expected, time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	if expected := "<h1>Runner</h1><p>Installs the runner.</p>"; repo.README != expected {
+		t.Errorf("expected README %q, got %q", expected, repo.README)
+	}
+
+	for _, r := range f.requests {
+		if token := r.Header.Get("PRIVATE-TOKEN"); token != "gl-token" {
+			t.Errorf("%v: expected token to be sent, got %q", r.URL, token)
+		}
+		if r.URL.Path == "/api/v4/markdown" {
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("expected markdown request to be JSON")
+			}
+		}
+	}
+}
+
+func TestGitLabRepoMarkdownFallback(t *testing.T) {
+	const project = "/api/v4/projects/group%2Fproject"
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case project:
+			w.Write([]byte(`{"path_with_namespace": "group/project", "web_url": "https://gitlab.com/group/project", "default_branch": "main", "readme_url": "https://gitlab.com/group/project/-/blob/main/README.md"}`))
+		case project + "/repository/files/README.md/raw":
+			w.Write([]byte("<script>alert(1)</script>"))
+		case "/api/v4/markdown":
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &payload)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gitlab := NewGitLab()
+	gitlab.BaseURL = server.URL + "/api/v4"
+	repo, err := gitlab.Repo(context.Background(), "group/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>"; repo.README != expected {
+		t.Errorf("expected escaped raw README %q, got %q", expected, repo.README)
+	}
+	if payload["project"] != "group/project" || payload["gfm"] != true {
+		t.Errorf("unexpected markdown request payload: %v", payload)
+	}
+}
+
+func TestGitLabRepoWithoutReadmeOrCommits(t *testing.T) {
+	const project = "/api/v4/projects/group%2Fempty"
+	f := &fakeForge{
+		responses: map[string]string{
+			project + "?license=true": `{"path_with_namespace": "group/empty", "web_url": "https://gitlab.com/group/empty", "last_activity_at": "2018-06-01T10:00:00Z"}`,
+		},
+	}
+	_, gitlab, done := newFakeForge(f)
+	defer done()
+
+	repo, err := gitlab.Repo(context.Background(), "group/empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.README != "" || repo.Language != "" || repo.License != "" {
+		t.Errorf("expected an empty repository, got %+v", repo)
+	}
+	if repo.LastCommit == nil || !repo.LastCommit.Equal(time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected last commit to fall back to last_activity_at, got %v", repo.LastCommit)
+	}
+}
+
+func TestGitLabRepoMissingReadme(t *testing.T) {
+	// The project advertises a README which has since been deleted.
+	const project = "/api/v4/projects/group%2Fproject"
+	f := &fakeForge{
+		responses: map[string]string{
+			project + "?license=true": `{"path_with_namespace": "group/project", "web_url": "https://gitlab.com/group/project", "default_branch": "main", "readme_url": "https://gitlab.com/group/project/-/blob/main/README.md"}`,
+		},
+	}
+	_, gitlab, done := newFakeForge(f)
+	defer done()
+
+	repo, err := gitlab.Repo(context.Background(), "group/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.README != "" {
+		t.Errorf("expected no README, got %q", repo.README)
+	}
+	if _, err := gitlab.Repo(context.Background(), "group/missing"); err == nil {
+		t.Errorf("expected an error for a missing project")
+	}
+}
+
+func checkRepo(t *testing.T, actual *Repo, expected *Repo, lastCommit time.Time) {
+	if actual.LastCommit == nil || !actual.LastCommit.Equal(lastCommit) {
+		t.Errorf("expected last commit %v, got %v", lastCommit, actual.LastCommit)
+	}
+	a := *actual
+	a.LastCommit, a.README, a.READMEBase = nil, "", ""
+	if !reflect.DeepEqual(&a, expected) {
+		t.Errorf("expected repo %+v, got %+v", expected, &a)
+	}
+}
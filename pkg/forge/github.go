@@ -0,0 +1,155 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// GitHubBaseURL is the public GitHub REST API endpoint.
+	GitHubBaseURL = "https://api.github.com"
+
+	// GitHubHost serves the repository pages GitHub handles.
+	GitHubHost = "github.com"
+)
+
+// githubReserved are first path segments of github.com pages which are not
+// repository owners.
+var githubReserved = map[string]struct{}{
+	"about":       {},
+	"apps":        {},
+	"collections": {},
+	"enterprise":  {},
+	"explore":     {},
+	"features":    {},
+	"login":       {},
+	"marketplace": {},
+	"orgs":        {},
+	"settings":    {},
+	"sponsors":    {},
+	"topics":      {},
+	"trending":    {},
+}
+
+// GitHub is a client for the GitHub REST API.
+type GitHub struct {
+	BaseURL    string       // Override to point at a local stand-in or GitHub Enterprise.
+	Host       string       // Host of the repository pages to handle.
+	Token      string       // Optional, raises the API rate limit.
+	HTTPClient *http.Client // Defaults to http.DefaultClient.
+}
+
+// NewGitHub returns a client for github.com.
+func NewGitHub() *GitHub {
+	g := &GitHub{
+		BaseURL:    GitHubBaseURL,
+		Host:       GitHubHost,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	return g
+}
+
+// Name implements Forge.
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+// Parse implements Forge.  Repository pages are /<owner>/<name>, optionally
+// followed by /tree/<branch>.
+func (g *GitHub) Parse(url string) (string, bool) {
+	segments := pathSegments(url, g.Host)
+	if len(segments) != 2 && !(len(segments) >= 3 && segments[2] == "tree") {
+		return "", false
+	}
+	if _, ok := githubReserved[strings.ToLower(segments[0])]; ok {
+		return "", false
+	}
+	return segments[0] + "/" + strings.TrimSuffix(segments[1], ".git"), true
+}
+
+type githubRepo struct {
+	FullName        string    `json:"full_name"`
+	HTMLURL         string    `json:"html_url"`
+	Description     string    `json:"description"`
+	StargazersCount int       `json:"stargazers_count"`
+	ForksCount      int       `json:"forks_count"`
+	Language        string    `json:"language"`
+	Topics          []string  `json:"topics"`
+	DefaultBranch   string    `json:"default_branch"`
+	PushedAt        time.Time `json:"pushed_at"`
+	Archived        bool      `json:"archived"`
+	License         *struct {
+		SPDXID string `json:"spdx_id"`
+		Name   string `json:"name"`
+	} `json:"license"`
+	Owner struct {
+		AvatarURL string `json:"avatar_url"`
+	} `json:"owner"`
+}
+
+type githubCommit struct {
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// Repo implements Forge.
+func (g *GitHub) Repo(ctx context.Context, path string) (*Repo, error) {
+	base := fmt.Sprintf("%v/repos/%v", strings.TrimRight(g.BaseURL, "/"), path)
+
+	gr := &githubRepo{}
+	if err := getJSON(ctx, g.HTTPClient, base, g.header("application/vnd.github+json"), gr); err != nil {
+		return nil, err
+	}
+	repo := &Repo{
+		Forge:         g.Name(),
+		Path:          gr.FullName,
+		URL:           gr.HTMLURL,
+		Description:   gr.Description,
+		Stars:         gr.StargazersCount,
+		Forks:         gr.ForksCount,
+		Language:      gr.Language,
+		Topics:        gr.Topics,
+		DefaultBranch: gr.DefaultBranch,
+		AvatarURL:     gr.Owner.AvatarURL,
+		Archived:      gr.Archived,
+		READMEBase:    fmt.Sprintf("%v/raw/%v/", gr.HTMLURL, gr.DefaultBranch),
+	}
+	if gr.License != nil {
+		repo.License = gr.License.SPDXID
+		if repo.License == "" || repo.License == "NOASSERTION" {
+			repo.License = gr.License.Name
+		}
+	}
+
+	readme, err := get(ctx, g.HTTPClient, base+"/readme", g.header("application/vnd.github.html+json"))
+	if _, ok := err.(errNotFound); err != nil && !ok {
+		return nil, fmt.Errorf("fetching readme: %s", err)
+	}
+	repo.README = string(readme)
+
+	var commits []githubCommit
+	if err := getJSON(ctx, g.HTTPClient, base+"/commits?per_page=1", g.header("application/vnd.github+json"), &commits); err == nil && len(commits) > 0 {
+		repo.LastCommit = &commits[0].Commit.Committer.Date
+	} else if !gr.PushedAt.IsZero() {
+		// Empty repositories have no commits.
+		repo.LastCommit = &gr.PushedAt
+	}
+
+	return repo, nil
+}
+
+func (g *GitHub) header(accept string) http.Header {
+	h := http.Header{}
+	h.Set("Accept", accept)
+	h.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.Token != "" {
+		h.Set("Authorization", "Bearer "+g.Token)
+	}
+	return h
+}
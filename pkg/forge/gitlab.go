@@ -0,0 +1,206 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// GitLabBaseURL is the gitlab.com REST API endpoint.
+	GitLabBaseURL = "https://gitlab.com/api/v4"
+
+	// GitLabHost serves the repository pages GitLab handles.
+	GitLabHost = "gitlab.com"
+)
+
+// gitlabReserved are first path segments of gitlab.com pages which are not
+// project namespaces.
+var gitlabReserved = map[string]struct{}{
+	"dashboard": {},
+	"explore":   {},
+	"groups":    {},
+	"help":      {},
+	"projects":  {},
+	"search":    {},
+	"users":     {},
+}
+
+// GitLab is a client for the GitLab REST API.
+type GitLab struct {
+	BaseURL    string       // Override to point at a local stand-in or self-hosted instance.
+	Host       string       // Host of the repository pages to handle.
+	Token      string       // Optional, for private projects and higher rate limits.
+	HTTPClient *http.Client // Defaults to http.DefaultClient.
+}
+
+// NewGitLab returns a client for gitlab.com.
+func NewGitLab() *GitLab {
+	g := &GitLab{
+		BaseURL:    GitLabBaseURL,
+		Host:       GitLabHost,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	return g
+}
+
+// Name implements Forge.
+func (g *GitLab) Name() string {
+	return "gitlab"
+}
+
+// Parse implements Forge.  Projects may be nested in subgroups, so
+// repository pages are /<namespace>.../<name>, optionally followed by
+// /-/tree/<branch>.
+func (g *GitLab) Parse(url string) (string, bool) {
+	segments := pathSegments(url, g.Host)
+	for i, s := range segments {
+		if s == "-" {
+			if i+1 >= len(segments) || segments[i+1] != "tree" {
+				return "", false
+			}
+			segments = segments[:i]
+			break
+		}
+	}
+	if len(segments) < 2 {
+		return "", false
+	}
+	if _, ok := gitlabReserved[strings.ToLower(segments[0])]; ok {
+		return "", false
+	}
+	segments[len(segments)-1] = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	return strings.Join(segments, "/"), true
+}
+
+type gitlabProject struct {
+	PathWithNamespace string    `json:"path_with_namespace"`
+	WebURL            string    `json:"web_url"`
+	Description       string    `json:"description"`
+	StarCount         int       `json:"star_count"`
+	ForksCount        int       `json:"forks_count"`
+	Topics            []string  `json:"topics"`
+	DefaultBranch     string    `json:"default_branch"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	AvatarURL         string    `json:"avatar_url"`
+	ReadmeURL         string    `json:"readme_url"`
+	Archived          bool      `json:"archived"`
+	License           *struct {
+		Key      string `json:"key"`
+		Name     string `json:"name"`
+		Nickname string `json:"nickname"`
+	} `json:"license"`
+}
+
+type gitlabCommit struct {
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+// Repo implements Forge.
+func (g *GitLab) Repo(ctx context.Context, path string) (*Repo, error) {
+	api := strings.TrimRight(g.BaseURL, "/")
+	base := fmt.Sprintf("%v/projects/%v", api, url.PathEscape(path))
+
+	gp := &gitlabProject{}
+	if err := getJSON(ctx, g.HTTPClient, base+"?license=true", g.header(), gp); err != nil {
+		return nil, err
+	}
+	repo := &Repo{
+		Forge:         g.Name(),
+		Path:          gp.PathWithNamespace,
+		URL:           gp.WebURL,
+		Description:   gp.Description,
+		Stars:         gp.StarCount,
+		Forks:         gp.ForksCount,
+		Topics:        gp.Topics,
+		DefaultBranch: gp.DefaultBranch,
+		AvatarURL:     gp.AvatarURL,
+		Archived:      gp.Archived,
+		READMEBase:    fmt.Sprintf("%v/-/raw/%v/", gp.WebURL, gp.DefaultBranch),
+	}
+	if gp.License != nil {
+		repo.License = gp.License.Nickname
+		if repo.License == "" {
+			repo.License = gp.License.Name
+		}
+	}
+
+	// Languages are reported as percentages of the code.
+	languages := map[string]float64{}
+	if err := getJSON(ctx, g.HTTPClient, base+"/languages", g.header(), &languages); err == nil {
+		var top float64
+		for language, share := range languages {
+			if share > top || (share == top && language < repo.Language) {
+				repo.Language, top = language, share
+			}
+		}
+	}
+
+	if gp.ReadmeURL != "" {
+		readme, err := g.readme(ctx, api, base, gp)
+		if _, ok := err.(errNotFound); err != nil && !ok {
+			return nil, fmt.Errorf("fetching readme: %s", err)
+		}
+		repo.README = readme
+	}
+
+	var commits []gitlabCommit
+	if err := getJSON(ctx, g.HTTPClient, base+"/repository/commits?per_page=1", g.header(), &commits); err == nil && len(commits) > 0 {
+		repo.LastCommit = &commits[0].CommittedDate
+	} else if !gp.LastActivityAt.IsZero() {
+		repo.LastCommit = &gp.LastActivityAt
+	}
+
+	return repo, nil
+}
+
+// readme fetches the project README and renders it to HTML with the GitLab
+// Markdown API.  When rendering fails the raw README is kept as
+// preformatted text.
+func (g *GitLab) readme(ctx context.Context, api string, base string, gp *gitlabProject) (string, error) {
+	name := gp.ReadmeURL[strings.LastIndex(gp.ReadmeURL, "/")+1:]
+	if i := strings.Index(gp.ReadmeURL, "/-/blob/"+gp.DefaultBranch+"/"); i >= 0 {
+		name = gp.ReadmeURL[i+len("/-/blob/"+gp.DefaultBranch+"/"):]
+	}
+	u := fmt.Sprintf("%v/repository/files/%v/raw?ref=%v", base, url.PathEscape(name), url.QueryEscape(gp.DefaultBranch))
+	raw, err := get(ctx, g.HTTPClient, u, g.header())
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"text":    string(raw),
+		"gfm":     true,
+		"project": gp.PathWithNamespace,
+	})
+	if err != nil {
+		return "", err
+	}
+	header := g.header()
+	header.Set("Content-Type", "application/json")
+	body, err := do(ctx, g.HTTPClient, "POST", api+"/markdown", header, bytes.NewReader(payload))
+	rendered := struct {
+		HTML string `json:"html"`
+	}{}
+	if err == nil {
+		err = json.Unmarshal(body, &rendered)
+	}
+	if err != nil || rendered.HTML == "" {
+		return "<pre>" + html.EscapeString(string(raw)) + "</pre>", nil
+	}
+	return rendered.HTML, nil
+}
+
+func (g *GitLab) header() http.Header {
+	h := http.Header{}
+	if g.Token != "" {
+		h.Set("PRIVATE-TOKEN", g.Token)
+	}
+	return h
+}
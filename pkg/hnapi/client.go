@@ -0,0 +1,242 @@
+package hnapi
+
+// Client for the Hacker News Firebase API (https://github.com/HackerNews/API).
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the production Firebase API endpoint.
+	DefaultBaseURL = "https://hacker-news.firebaseio.com/v0"
+
+	// DefaultMaxComments caps the number of comments fetched per thread.
+	DefaultMaxComments = 500
+
+	// DefaultConcurrency is the number of items fetched in parallel.
+	DefaultConcurrency = 8
+)
+
+// Item is a story, comment, job or poll as returned by the API.
+type Item struct {
+	ID          int64   `json:"id"`
+	Type        string  `json:"type"`
+	By          string  `json:"by"`
+	Time        int64   `json:"time"` // Unix seconds.
+	Text        string  `json:"text"` // HTML.
+	Parent      int64   `json:"parent"`
+	Kids        []int64 `json:"kids"` // In ranked display order.
+	Deleted     bool    `json:"deleted"`
+	Dead        bool    `json:"dead"`
+	Score       int     `json:"score"`
+	Title       string  `json:"title"`
+	URL         string  `json:"url"`
+	Descendants int     `json:"descendants"`
+}
+
+// Client fetches items from the API.
+type Client struct {
+	BaseURL     string       // Override to point at a local fake.
+	HTTPClient  *http.Client // Defaults to http.DefaultClient.
+	MaxComments int
+	Concurrency int
+}
+
+// New returns a Client for the production API.
+func New() *Client {
+	c := &Client{
+		BaseURL:     DefaultBaseURL,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		MaxComments: DefaultMaxComments,
+		Concurrency: DefaultConcurrency,
+	}
+	return c
+}
+
+// Item fetches a single item.  A nil item and nil error are returned when
+// the item does not exist.
+func (c *Client) Item(ctx context.Context, id int64) (*Item, error) {
+	u := fmt.Sprintf("%v/item/%v.json", strings.TrimRight(c.BaseURL, "/"), id)
+
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetching item %v: %s", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("fetching item %v: non-2xx response status-code=%v", id, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading item %v: %s", id, err)
+	}
+
+	var item *Item
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("unmarshalling item %v: %s", id, err)
+	}
+	return item, nil
+}
+
+// List fetches the IDs in one of the API's story lists, e.g. "topstories",
+// "beststories" or "askstories".
+func (c *Client) List(ctx context.Context, name string) ([]int64, error) {
+	u := fmt.Sprintf("%v/%v.json", strings.TrimRight(c.BaseURL, "/"), name)
+
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %v: %s", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("fetching %v: non-2xx response status-code=%v", name, resp.StatusCode)
+	}
+	var ids []int64
+	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("unmarshalling %v: %s", name, err)
+	}
+	return ids, nil
+}
+
+func (c *Client) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// Thread fetches the comment tree beneath story id, breadth-first so that
+// when MaxComments is reached it is the deepest replies which are omitted.
+// Deleted and dead comments are dropped along with their replies.
+func (c *Client) Thread(ctx context.Context, id int64) ([]*Comment, error) {
+	story, err := c.Item(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if story == nil {
+		return nil, fmt.Errorf("item %v not found", id)
+	}
+
+	var (
+		roots    = []*Comment{}
+		parents  = map[int64]*Comment{}
+		level    = story.Kids
+		fetched  int
+		max      = c.MaxComments
+		parallel = c.Concurrency
+	)
+	if max <= 0 {
+		max = DefaultMaxComments
+	}
+	if parallel <= 0 {
+		parallel = DefaultConcurrency
+	}
+
+	for len(level) > 0 && fetched < max {
+		if len(level) > max-fetched {
+			level = level[0 : max-fetched]
+		}
+		items, err := c.items(ctx, level, parallel)
+		if err != nil {
+			return nil, err
+		}
+		fetched += len(level)
+
+		var next []int64
+		for _, item := range items {
+			if item == nil || item.Deleted || item.Dead || item.Type != "comment" {
+				continue
+			}
+			comment := newComment(item)
+			parents[item.ID] = comment
+			if parent, ok := parents[item.Parent]; ok {
+				parent.Replies = append(parent.Replies, comment)
+			} else {
+				roots = append(roots, comment)
+			}
+			next = append(next, item.Kids...)
+		}
+		level = next
+	}
+
+	for _, root := range roots {
+		root.count()
+	}
+	return roots, nil
+}
+
+// items fetches ids concurrently, preserving their order.
+func (c *Client) items(ctx context.Context, ids []int64, parallel int) ([]*Item, error) {
+	var (
+		items = make([]*Item, len(ids))
+		errs  = make([]error, len(ids))
+		sem   = make(chan struct{}, parallel)
+		wg    sync.WaitGroup
+	)
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			items[i], errs[i] = c.Item(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// RefreshInterval returns how long to wait between metadata refreshes of a
+// story of the given age.  Scores move quickly while a story is on the front
+// page and hardly at all after a few weeks.
+func RefreshInterval(age time.Duration) time.Duration {
+	switch {
+	case age < 6*time.Hour:
+		return 30 * time.Minute
+	case age < 48*time.Hour:
+		return 2 * time.Hour
+	case age < 7*24*time.Hour:
+		return 12 * time.Hour
+	case age < 30*24*time.Hour:
+		return 3 * 24 * time.Hour
+	case age < 365*24*time.Hour:
+		return 30 * 24 * time.Hour
+	default:
+		return 90 * 24 * time.Hour
+	}
+}
+
+// RefreshDue reports whether a story submitted at the given time and last
+// refreshed at last should be polled again at now.
+func RefreshDue(submitted time.Time, last time.Time, now time.Time) bool {
+	if last.IsZero() {
+		return true
+	}
+	age := last.Sub(submitted)
+	if submitted.IsZero() {
+		age = now.Sub(last)
+	}
+	return now.Sub(last) >= RefreshInterval(age)
+}
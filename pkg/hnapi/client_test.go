@@ -0,0 +1,239 @@
+package hnapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFirebase serves items and story lists the way the HN API does,
+// including the literal "null" body for unknown items.
+type fakeFirebase struct {
+	items    map[int64]string
+	lists    map[string]string
+	failures map[int64]int // Status codes to respond with instead.
+
+	mu        sync.Mutex
+	requested []int64
+}
+
+func (f *fakeFirebase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var id int64
+	if _, err := fmt.Sscanf(r.URL.Path, "/v0/item/%d.json", &id); err == nil {
+		f.mu.Lock()
+		f.requested = append(f.requested, id)
+		f.mu.Unlock()
+		if status, ok := f.failures[id]; ok {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if item, ok := f.items[id]; ok {
+			w.Write([]byte(item))
+		} else {
+			w.Write([]byte("null"))
+		}
+		return
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v0/"), ".json")
+	if list, ok := f.lists[name]; ok {
+		w.Write([]byte(list))
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func newFakeClient(f *fakeFirebase) (*Client, func()) {
+	server := httptest.NewServer(f)
+	c := New()
+	c.BaseURL = server.URL + "/v0"
+	return c, server.Close
+}
+
+func comment(id int64, parent int64, by string, text string, kids ...int64) string {
+	return fmt.Sprintf(`{"id":%v,"type":"comment","by":%q,"time":1500000000,"parent":%v,"text":%q,"kids":%v}`, id, by, parent, text, ids(kids))
+}
+
+func ids(kids []int64) string {
+	s := make([]string, len(kids))
+	for i, kid := range kids {
+		s[i] = fmt.Sprint(kid)
+	}
+	return "[" + strings.Join(s, ",") + "]"
+}
+
+func TestItem(t *testing.T) {
+	f := &fakeFirebase{
+		items: map[int64]string{
+			1: `{"id":1,"type":"story","by":"pg","time":1160418111,"title":"Y Combinator","url":"http://ycombinator.com","score":57,"descendants":15,"kids":[15,234509]}`,
+		},
+		failures: map[int64]int{3: http.StatusServiceUnavailable},
+	}
+	c, done := newFakeClient(f)
+	defer done()
+
+	item, err := c.Item(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Title != "Y Combinator" || item.Score != 57 || item.Descendants != 15 || len(item.Kids) != 2 {
+		t.Errorf("unexpected item: %+v", item)
+	}
+
+	if item, err = c.Item(context.Background(), 2); err != nil || item != nil {
+		t.Errorf("expected nil item and error for a missing item, got %+v, %v", item, err)
+	}
+	if _, err = c.Item(context.Background(), 3); err == nil {
+		t.Errorf("expected an error for a 503 response")
+	}
+}
+
+func TestList(t *testing.T) {
+	f := &fakeFirebase{
+		lists: map[string]string{"topstories": `[3,1,2]`},
+	}
+	c, done := newFakeClient(f)
+	defer done()
+
+	list, err := c.List(context.Background(), "topstories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[3,1,2]"; ids(list) != expected {
+		t.Errorf("expected %v, got %v", expected, ids(list))
+	}
+	if _, err := c.List(context.Background(), "nosuchstories"); err == nil {
+		t.Errorf("expected an error for an unknown list")
+	}
+}
+
+func TestThread(t *testing.T) {
+	f := &fakeFirebase{
+		items: map[int64]string{
+			100: `{"id":100,"type":"story","title":"Story","kids":[101,102,103,104]}`,
+			101: comment(101, 100, "alice", "<p>first", 105, 106),
+			102: `{"id":102,"type":"comment","deleted":true,"parent":100,"kids":[107]}`,
+			103: `{"id":103,"type":"comment","dead":true,"by":"spam","parent":100,"text":"spam"}`,
+			104: comment(104, 100, "bob", "second"),
+			105: comment(105, 101, "carol", "reply", 108),
+			106: comment(106, 101, "dave", "another reply"),
+			107: comment(107, 102, "erin", "orphaned by a deleted parent"),
+			108: comment(108, 105, "frank", "deep"),
+		},
+	}
+	c, done := newFakeClient(f)
+	defer done()
+
+	thread, err := c.Thread(context.Background(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 2 || thread[0].ID != 101 || thread[1].ID != 104 {
+		t.Fatalf("expected top-level comments 101 and 104, got %+v", thread)
+	}
+	if thread[0].Descendants != 3 {
+		t.Errorf("expected 3 descendants beneath 101, got %v", thread[0].Descendants)
+	}
+	if expected := time.Unix(1500000000, 0).UTC(); !thread[0].Time.Equal(expected) {
+		t.Errorf("expected time %v, got %v", expected, thread[0].Time)
+	}
+
+	var order []int64
+	for _, comment := range Flatten(thread) {
+		order = append(order, comment.ID)
+	}
+	if expected := "[101,105,108,106,104]"; ids(order) != expected {
+		t.Errorf("expected depth-first order %v, got %v", expected, ids(order))
+	}
+	for _, id := range f.requested {
+		if id == 107 {
+			t.Errorf("replies to a deleted comment should not be fetched")
+		}
+	}
+}
+
+func TestThreadMaxComments(t *testing.T) {
+	f := &fakeFirebase{
+		items: map[int64]string{
+			1: `{"id":1,"type":"story","kids":[2,3]}`,
+			2: comment(2, 1, "a", "x", 4),
+			3: comment(3, 1, "b", "y", 5),
+			4: comment(4, 2, "c", "z"),
+			5: comment(5, 3, "d", "w"),
+		},
+	}
+	c, done := newFakeClient(f)
+	defer done()
+	c.MaxComments = 3
+
+	thread, err := c.Thread(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(Flatten(thread)); n != 3 {
+		t.Errorf("expected 3 comments, got %v", n)
+	}
+	if len(thread) != 2 {
+		t.Errorf("expected both top-level comments to be kept, got %v", len(thread))
+	}
+}
+
+func TestThreadErrors(t *testing.T) {
+	f := &fakeFirebase{
+		items: map[int64]string{
+			1: `{"id":1,"type":"story","kids":[2]}`,
+		},
+		failures: map[int64]int{2: http.StatusInternalServerError},
+	}
+	c, done := newFakeClient(f)
+	defer done()
+
+	if _, err := c.Thread(context.Background(), 1); err == nil {
+		t.Errorf("expected an error when a comment cannot be fetched")
+	}
+	if _, err := c.Thread(context.Background(), 9); err == nil {
+		t.Errorf("expected an error for a missing story")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.requested = nil
+	if _, err := c.Thread(ctx, 1); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+	if len(f.requested) != 0 {
+		t.Errorf("expected no requests once cancelled, got %v", f.requested)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	c := &Comment{Text: `First &amp; foremost<p>See <a href="https://example.com">here</a>`}
+	if expected, actual := "First & foremost\n\nSee here", c.PlainText(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		submitted time.Time
+		last      time.Time
+		due       bool
+	}{
+		{now.Add(-time.Hour), time.Time{}, true},
+		{now.Add(-time.Hour), now.Add(-10 * time.Minute), false},
+		{now.Add(-time.Hour), now.Add(-40 * time.Minute), true},
+		{now.Add(-60 * 24 * time.Hour), now.Add(-7 * 24 * time.Hour), false},
+		{time.Time{}, now.Add(-time.Hour), true},
+	}
+	for i, testCase := range testCases {
+		if actual := RefreshDue(testCase.submitted, testCase.last, now); actual != testCase.due {
+			t.Errorf("[%v] RefreshDue(%v, %v) = %v, expected %v", i, testCase.submitted, testCase.last, actual, testCase.due)
+		}
+	}
+}
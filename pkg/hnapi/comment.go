@@ -0,0 +1,95 @@
+package hnapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"jaytaylor.com/circus/pkg/htmlmd"
+)
+
+const itemURLBase = "https://news.ycombinator.com/"
+
+// Comment is a node in a discussion thread.
+type Comment struct {
+	ID          int64      `json:"id"`
+	By          string     `json:"by"`
+	Time        time.Time  `json:"time"`
+	Text        string     `json:"text"`                  // HTML, as returned by the API.
+	Replies     []*Comment `json:"replies,omitempty"`     // In ranked display order.
+	Descendants int        `json:"descendants,omitempty"` // Total number of replies beneath this comment which were fetched.
+}
+
+func newComment(item *Item) *Comment {
+	c := &Comment{
+		ID:   item.ID,
+		By:   item.By,
+		Time: time.Unix(item.Time, 0).UTC(),
+		Text: item.Text,
+	}
+	return c
+}
+
+// URL returns the permalink of the comment.
+func (c *Comment) URL() string {
+	return fmt.Sprintf("%vitem?id=%v", itemURLBase, c.ID)
+}
+
+// Markdown renders the comment text as sanitized Markdown.
+func (c *Comment) Markdown() string {
+	md, err := htmlmd.ConvertString(c.Text, itemURLBase)
+	if err != nil {
+		return ""
+	}
+	return md
+}
+
+// PlainText returns the comment text with markup removed and paragraphs
+// separated by blank lines.
+func (c *Comment) PlainText() string {
+	var (
+		buf = &strings.Builder{}
+		z   = html.NewTokenizer(strings.NewReader(c.Text))
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(buf.String())
+		case html.TextToken:
+			buf.Write(z.Text())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if a := atom.Lookup(name); a == atom.P || a == atom.Br || a == atom.Pre {
+				buf.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+func (c *Comment) count() int {
+	c.Descendants = 0
+	for _, reply := range c.Replies {
+		c.Descendants += 1 + reply.count()
+	}
+	return c.Descendants
+}
+
+// Flatten returns every comment in thread, depth-first in display order.
+func Flatten(thread []*Comment) []*Comment {
+	var all []*Comment
+	for _, c := range thread {
+		all = append(all, c)
+		all = append(all, Flatten(c.Replies)...)
+	}
+	return all
+}
+
+// Top returns up to n top-level comments, in the thread's ranked order.
+func Top(thread []*Comment, n int) []*Comment {
+	if n < len(thread) {
+		return thread[0:n]
+	}
+	return thread
+}
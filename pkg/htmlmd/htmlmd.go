@@ -0,0 +1,455 @@
+package htmlmd
+
+// Converts extracted article HTML to sanitized Markdown, preserving code
+// blocks (with language hints), headings, lists, links and images.  Raw HTML
+// is never passed through.
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// indent marks list continuation indentation until rendering is complete, so
+// it survives the trimming of incidental leading whitespace.
+const indent = "\x01"
+
+var (
+	// skipped elements are dropped along with all of their content.
+	skipped = map[atom.Atom]struct{}{
+		atom.Script:   {},
+		atom.Style:    {},
+		atom.Noscript: {},
+		atom.Iframe:   {},
+		atom.Object:   {},
+		atom.Embed:    {},
+		atom.Form:     {},
+		atom.Button:   {},
+		atom.Input:    {},
+		atom.Select:   {},
+		atom.Textarea: {},
+		atom.Svg:      {},
+		atom.Canvas:   {},
+		atom.Nav:      {},
+		atom.Template: {},
+	}
+
+	// blocks are rendered as paragraphs.
+	blocks = map[atom.Atom]struct{}{
+		atom.P:          {},
+		atom.Div:        {},
+		atom.Section:    {},
+		atom.Article:    {},
+		atom.Main:       {},
+		atom.Header:     {},
+		atom.Footer:     {},
+		atom.Aside:      {},
+		atom.Figure:     {},
+		atom.Figcaption: {},
+		atom.Dl:         {},
+		atom.Dt:         {},
+		atom.Dd:         {},
+		atom.Details:    {},
+		atom.Summary:    {},
+		atom.Address:    {},
+	}
+
+	languageClassExpr = regexp.MustCompile(`^(?:language|lang|highlight-source|highlight|brush:)-?(.+)$`)
+	languageSafeExpr  = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]*$`)
+	whitespaceExpr    = regexp.MustCompile(`\s+`)
+	blankLinesExpr    = regexp.MustCompile(`\n{3,}`)
+	orderedMarkerExpr = regexp.MustCompile(`^(\d+)([.)]\s)`)
+	placeholderExpr   = regexp.MustCompile(`\x00(\d+)\x00`)
+	inlineEscaper     = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `&lt;`, `>`, `&gt;`)
+)
+
+// Convert renders node and its descendants as Markdown.  Relative link and
+// image references are resolved against baseURL.
+func Convert(node *html.Node, baseURL string) string {
+	c := &converter{}
+	if u, err := url.Parse(baseURL); err == nil {
+		c.base = u
+	}
+	md := c.render(node)
+	return c.finish(md)
+}
+
+// ConvertString parses an HTML fragment and renders it as Markdown.
+func ConvertString(fragment string, baseURL string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(fragment))
+	if err != nil {
+		return "", fmt.Errorf("parsing html: %s", err)
+	}
+	return Convert(doc, baseURL), nil
+}
+
+type converter struct {
+	base *url.URL
+	code []string // Rendered code blocks, kept out of whitespace normalization.
+}
+
+func (c *converter) render(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(whitespaceExpr.ReplaceAllString(n.Data, " "))
+	case html.DocumentNode:
+		return c.children(n)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if _, ok := skipped[n.DataAtom]; ok {
+		return ""
+	}
+	if _, ok := blocks[n.DataAtom]; ok {
+		return "\n\n" + c.children(n) + "\n\n"
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := oneLine(c.children(n))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+
+	case atom.Br:
+		return "  \n"
+
+	case atom.Hr:
+		return "\n\n---\n\n"
+
+	case atom.Pre:
+		return "\n\n" + c.codeBlock(n) + "\n\n"
+
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return inlineCode(textContent(n))
+
+	case atom.Em, atom.I, atom.Cite:
+		return wrapInline(c.children(n), "*")
+
+	case atom.Strong, atom.B:
+		return wrapInline(c.children(n), "**")
+
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(c.children(n), "~~")
+
+	case atom.A:
+		text := oneLine(c.children(n))
+		href := c.resolve(attr(n, "href"), true)
+		if href == "" || text == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+
+	case atom.Img:
+		src := c.resolve(attr(n, "src"), false)
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeText(oneLine(attr(n, "alt"))) + "](" + src + ")"
+
+	case atom.Ul, atom.Ol:
+		return "\n\n" + c.list(n) + "\n\n"
+
+	case atom.Blockquote:
+		inner := strings.TrimSpace(c.finishBlock(c.children(n)))
+		if inner == "" {
+			return ""
+		}
+		return "\n\n" + prefixLines(inner, "> ", "> ") + "\n\n"
+
+	case atom.Table:
+		return "\n\n" + c.table(n) + "\n\n"
+	}
+
+	return c.children(n)
+}
+
+func (c *converter) children(n *html.Node) string {
+	buf := &bytes.Buffer{}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		buf.WriteString(c.render(child))
+	}
+	return buf.String()
+}
+
+// codeBlock renders a <pre> element as a fenced code block.  The block is
+// stored and a placeholder returned so later whitespace normalization cannot
+// alter it.
+func (c *converter) codeBlock(pre *html.Node) string {
+	var (
+		code  = strings.Trim(textContent(pre), "\n")
+		lang  = codeLanguage(pre)
+		fence = "```"
+	)
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	c.code = append(c.code, fence+lang+"\n"+code+"\n"+fence)
+	return fmt.Sprintf("\x00%d\x00", len(c.code)-1)
+}
+
+func (c *converter) list(n *html.Node) string {
+	var (
+		items   []string
+		ordered = n.DataAtom == atom.Ol
+		number  = 1
+	)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		content := strings.TrimSpace(c.finishBlock(c.children(child)))
+		items = append(items, prefixLines(content, marker, strings.Repeat(indent, len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if child.DataAtom != atom.Tr {
+				walk(child)
+				continue
+			}
+			var cells []string
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					cells = append(cells, strings.Replace(oneLine(c.children(cell)), "|", `\|`, -1))
+				}
+			}
+			if len(cells) > 0 {
+				rows = append(rows, cells)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", width))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolve makes ref absolute and only permits http(s) (and mailto for links)
+// schemes.  Unsafe or unparseable references yield "".
+func (c *converter) resolve(ref string, link bool) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "mailto":
+		if !link {
+			return ""
+		}
+	default:
+		return ""
+	}
+	return strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(u.String())
+}
+
+// finishBlock normalizes whitespace in rendered block content.
+func (c *converter) finishBlock(md string) string {
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else if !strings.HasSuffix(line, "  ") {
+			lines[i] = strings.TrimRight(line, " ")
+		}
+		lines[i] = strings.TrimLeft(lines[i], " ")
+	}
+	return blankLinesExpr.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+// finish normalizes whitespace and restores the code blocks.  Code blocks
+// nested in lists or blockquotes have their continuation lines prefixed to
+// match.
+func (c *converter) finish(md string) string {
+	lines := strings.Split(strings.TrimSpace(c.finishBlock(md)), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "\x00") {
+			continue
+		}
+		buf := &bytes.Buffer{}
+		last := 0
+		for _, m := range placeholderExpr.FindAllStringSubmatchIndex(line, -1) {
+			n, err := strconv.Atoi(line[m[2]:m[3]])
+			if err != nil || n >= len(c.code) {
+				continue
+			}
+			buf.WriteString(line[last:m[0]])
+			// Continuation lines line up with everything before the
+			// placeholder, keeping blockquote markers.
+			cont := strings.Map(func(r rune) rune {
+				if r == '>' {
+					return r
+				}
+				return ' '
+			}, lastLine(buf.String()))
+			buf.WriteString(prefixLines(c.code[n], "", cont))
+			last = m[1]
+		}
+		buf.WriteString(line[last:])
+		lines[i] = buf.String()
+	}
+	return strings.Replace(strings.Join(lines, "\n"), indent, " ", -1)
+}
+
+// lastLine returns the text after the final newline in s.
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\n")+1:]
+}
+
+// codeLanguage looks for a language hint on the <pre> or a nested <code>
+// element, e.g. class="language-go" or data-lang="python".  Hints are
+// restricted to a conservative character set.
+func codeLanguage(pre *html.Node) string {
+	candidates := []*html.Node{pre}
+	for child := pre.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Code {
+			candidates = append(candidates, child)
+		}
+	}
+	for _, n := range candidates {
+		if lang := strings.ToLower(strings.TrimSpace(attr(n, "data-lang"))); languageSafeExpr.MatchString(lang) {
+			return lang
+		}
+		for _, class := range strings.Fields(strings.ToLower(attr(n, "class"))) {
+			if m := languageClassExpr.FindStringSubmatch(class); m != nil && languageSafeExpr.MatchString(m[1]) {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+func inlineCode(code string) string {
+	code = whitespaceExpr.ReplaceAllString(code, " ")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+// wrapInline surrounds inline content with a Markdown delimiter, keeping
+// leading and trailing whitespace outside of the delimiters.
+func wrapInline(content string, delim string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	var (
+		lead  = content[0:strings.Index(content, trimmed)]
+		trail = content[len(lead)+len(trimmed):]
+	)
+	return lead + delim + trimmed + delim + trail
+}
+
+func escapeText(s string) string {
+	s = inlineEscaper.Replace(s)
+	// Text which would otherwise be parsed as a heading or list marker.
+	trimmed := strings.TrimLeft(s, " ")
+	lead := s[0 : len(s)-len(trimmed)]
+	switch {
+	case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "+ "), strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "="):
+		return lead + `\` + trimmed
+	case orderedMarkerExpr.MatchString(trimmed):
+		return lead + orderedMarkerExpr.ReplaceAllString(trimmed, `$1\$2`)
+	}
+	return s
+}
+
+func oneLine(s string) string {
+	return strings.TrimSpace(whitespaceExpr.ReplaceAllString(s, " "))
+}
+
+func prefixLines(s string, first string, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = strings.TrimRight(rest, " "+indent)
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	buf := &bytes.Buffer{}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			buf.WriteString("\n")
+			continue
+		}
+		buf.WriteString(textContent(child))
+	}
+	return buf.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
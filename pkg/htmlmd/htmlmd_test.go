@@ -0,0 +1,148 @@
+package htmlmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertCodePlaceholders(t *testing.T) {
+	testCases := []struct {
+		name     string
+		html     string
+		expected []string
+	}{
+		{
+			name:     "inline code spans",
+			html:     `<p>Use <code>a</code> and <code>b</code> together.</p>`,
+			expected: []string{"Use `a` and `b` together."},
+		},
+		{
+			name:     "code blocks sharing a line",
+			html:     `<table><tr><td><pre>first()</pre><pre>second()</pre></td></tr></table>`,
+			expected: []string{"first()", "second()"},
+		},
+		{
+			name: "code block in blockquote",
+			html: `<blockquote><pre>one
+two</pre></blockquote>`,
+			expected: []string{"> ```\n> one\n> two\n> ```"},
+		},
+	}
+	for _, testCase := range testCases {
+		md, err := ConvertString(testCase.html, "https://example.com/")
+		if err != nil {
+			t.Fatalf("%v: %s", testCase.name, err)
+		}
+		if strings.Contains(md, "\x00") {
+			t.Errorf("%v: placeholder left in output %q", testCase.name, md)
+		}
+		for _, expected := range testCase.expected {
+			if !strings.Contains(md, expected) {
+				t.Errorf("%v: expected %q in output %q", testCase.name, expected, md)
+			}
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "language class",
+			html:     "<pre><code class=\"language-go\">func main() {}\n</code></pre>",
+			expected: "```go\nfunc main() {}\n```",
+		},
+		{
+			name:     "data-lang",
+			html:     `<pre data-lang="Python"><code>print(1)</code></pre>`,
+			expected: "```python\nprint(1)\n```",
+		},
+		{
+			name:     "highlight class",
+			html:     `<div class="highlight"><pre class="highlight-source-js">f()</pre></div>`,
+			expected: "```js\nf()\n```",
+		},
+		{
+			name:     "unsafe language hint",
+			html:     "<pre><code class=\"language-a`b\">x</code></pre>",
+			expected: "```\nx\n```",
+		},
+		{
+			name:     "pre without code",
+			html:     "<pre>\nline 1\n  indented  *kept*\n\n\nafter blanks\n</pre>",
+			expected: "```\nline 1\n  indented  *kept*\n\n\nafter blanks\n```",
+		},
+		{
+			name:     "pre with breaks and fences",
+			html:     "<pre>a ``` b<br>c</pre>",
+			expected: "````\na ``` b\nc\n````",
+		},
+		{
+			name:     "headings",
+			html:     "<h1>Title</h1><h2> Sub \n <em>title</em></h2><h3> </h3><h6>Deep</h6>",
+			expected: "# Title\n\n## Sub *title*\n\n###### Deep",
+		},
+		{
+			name:     "nested lists",
+			html:     "<ul><li>one<ul><li>nested</li><li>again</li></ul></li><li>two</li></ul>",
+			expected: "- one\n\n  - nested\n  - again\n- two",
+		},
+		{
+			name:     "nested ordered lists",
+			html:     "<ol><li>first<ol><li>a</li><li>b</li></ol></li><li>second</li></ol>",
+			expected: "1. first\n\n   1. a\n   2. b\n2. second",
+		},
+		{
+			name:     "code block in list",
+			html:     "<ul><li>Run:<pre><code class=\"language-sh\">go test\ngo vet</code></pre></li></ul>",
+			expected: "- Run:\n\n  ```sh\n  go test\n  go vet\n  ```",
+		},
+		{
+			name:     "links",
+			html:     `<p><a href="/about">About  us</a>, <a href="page">a page</a>, <a href="/wiki/Go_(language)">Go</a> and <a href="mailto:a@example.com">mail</a>.</p>`,
+			expected: "[About us](https://example.com/about), [a page](https://example.com/docs/page), [Go](https://example.com/wiki/Go_%28language%29) and [mail](mailto:a@example.com).",
+		},
+		{
+			name:     "unsafe and empty links",
+			html:     `<p><a href="javascript:alert(1)">script</a> <a href="#top">top</a><a href="/x"></a> <a>plain</a></p>`,
+			expected: "script top plain",
+		},
+		{
+			name:     "images",
+			html:     `<p><img src="/a.png" alt="An [image]"> <img src="//cdn.example.com/b.jpg"></p>`,
+			expected: "![An \\[image\\]](https://example.com/a.png) ![](https://cdn.example.com/b.jpg)",
+		},
+		{
+			name:     "unsafe images",
+			html:     `<p>x<img src="data:image/png;base64,AAAA"><img src="mailto:a@example.com"><img></p>`,
+			expected: "x",
+		},
+		{
+			name:     "linked image",
+			html:     `<a href="/full.png"><img src="/thumb.png" alt="thumb"></a>`,
+			expected: "[![thumb](https://example.com/thumb.png)](https://example.com/full.png)",
+		},
+		{
+			name:     "skipped elements",
+			html:     `<p>kept</p><script>alert(1)</script><nav><a href="/">home</a></nav><form><input value="x"></form>`,
+			expected: "kept",
+		},
+		{
+			name:     "escaping",
+			html:     `<p># not a heading</p><p>1. not a list</p><p>a*b_c &lt;tag&gt;</p>`,
+			expected: "\\# not a heading\n\n1\\. not a list\n\na\\*b\\_c &lt;tag&gt;",
+		},
+	}
+	for _, testCase := range testCases {
+		md, err := ConvertString(testCase.html, "https://example.com/docs/")
+		if err != nil {
+			t.Fatalf("%v: %s", testCase.name, err)
+		}
+		if md != testCase.expected {
+			t.Errorf("%v:\nexpected %q\n     got %q", testCase.name, testCase.expected, md)
+		}
+	}
+}
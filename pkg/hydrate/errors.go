@@ -0,0 +1,66 @@
+package hydrate
+
+import (
+	"fmt"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// ExitCodes maps each failure kind to a distinct process exit status.
+var ExitCodes = map[domain.FailureKind]int{
+	domain.FailureInternal:        1,
+	domain.FailureFetch:           10,
+	domain.FailureHTTPStatus:      11,
+	domain.FailureArchiveFallback: 12,
+	domain.FailurePDFConvert:      13,
+	domain.FailureExtractEmpty:    14,
+	domain.FailureNER:             15,
+	domain.FailureStage:           16,
+}
+
+// Error annotates an error with the failure category it belongs to.
+type Error struct {
+	URL        string
+	Kind       domain.FailureKind
+	StatusCode int
+	Err        error
+}
+
+// NewError returns an Error of the given kind for url.
+func NewError(url string, kind domain.FailureKind, err error) *Error {
+	e := &Error{
+		URL:  url,
+		Kind: kind,
+		Err:  err,
+	}
+	return e
+}
+
+// WrapError prefixes err with msg.  When err is already an *Error its kind
+// and status code are retained, otherwise kind is used.
+func WrapError(url string, kind domain.FailureKind, msg string, err error) *Error {
+	e := NewError(url, kind, fmt.Errorf("%v: %s", msg, err))
+	if inner, ok := err.(*Error); ok {
+		e.Kind = inner.Kind
+		e.StatusCode = inner.StatusCode
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Failure produces the machine-readable failure record for the error.
+func (e *Error) Failure() *domain.Failure {
+	f := &domain.Failure{
+		Kind:       e.Kind,
+		URL:        e.URL,
+		Message:    e.Err.Error(),
+		StatusCode: e.StatusCode,
+		ExitCode:   ExitCodes[e.Kind],
+		Timestamp:  time.Now().UTC(),
+	}
+	return f
+}
@@ -0,0 +1,155 @@
+package hydrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// DefaultExecTimeout bounds each run of an Exec stage.
+var DefaultExecTimeout = 30 * time.Second
+
+func init() {
+	RegisterStage("exec", NewExec)
+}
+
+// Exec runs an external program as a stage, so stages can be written in any
+// language.  The program is given the document as JSON on stdin:
+//
+//	{"phase": "enrich", "url": "...", "html": "...", "context": {...}}
+//
+// and answers on stdout with a JSON object of extensions to set on the
+// context, and optionally an error message to fail hydration with:
+//
+//	{"extensions": {"linkrot": {"dead": 3}}, "error": ""}
+//
+// Empty output changes nothing.
+type Exec struct {
+	StageName string   `json:"name"`    // Defaults to the program's base name.
+	Command   []string `json:"command"` // Program and arguments.
+
+	Timeout Duration `json:"timeout"` // Must be positive.
+}
+
+// NewExec creates an Exec stage from its JSON configuration.
+func NewExec(config json.RawMessage) (Stage, error) {
+	e := &Exec{
+		Timeout: Duration(DefaultExecTimeout),
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, e); err != nil {
+			return nil, fmt.Errorf("parsing exec stage config: %s", err)
+		}
+	}
+	if len(e.Command) == 0 {
+		return nil, errors.New("exec stage requires a command")
+	}
+	if e.Timeout <= 0 {
+		return nil, fmt.Errorf("exec stage timeout must be positive, got %s", time.Duration(e.Timeout))
+	}
+	if e.StageName == "" {
+		e.StageName = filepath.Base(e.Command[0])
+	}
+	return e, nil
+}
+
+type execInput struct {
+	Phase   Phase           `json:"phase"`
+	URL     string          `json:"url"`
+	HTML    string          `json:"html"`
+	Context *domain.Context `json:"context"`
+}
+
+type execOutput struct {
+	Extensions map[string]json.RawMessage `json:"extensions"`
+	Error      string                     `json:"error"`
+}
+
+// Name implements Stage.
+func (e *Exec) Name() string {
+	return e.StageName
+}
+
+// Run implements Stage.
+func (e *Exec) Run(ctx context.Context, doc *Document) error {
+	input, err := json.Marshal(&execInput{
+		Phase:   doc.Phase,
+		URL:     doc.URL,
+		HTML:    string(doc.Content),
+		Context: doc.Context,
+	})
+	if err != nil {
+		return fmt.Errorf("serializing document: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(e.Timeout))
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s running %v", time.Duration(e.Timeout), e.Command[0])
+		}
+		return fmt.Errorf("running %v: %s (stderr=%v)", e.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil
+	}
+	output := &execOutput{}
+	if err := json.Unmarshal(stdout.Bytes(), output); err != nil {
+		return fmt.Errorf("parsing output of %v: %s", e.Command[0], err)
+	}
+	if output.Error != "" {
+		return errors.New(output.Error)
+	}
+	if len(output.Extensions) > 0 && doc.Context.Extensions == nil {
+		doc.Context.Extensions = map[string]json.RawMessage{}
+	}
+	for name, raw := range output.Extensions {
+		doc.Context.Extensions[name] = raw
+	}
+	return nil
+}
+
+// Duration is a time.Duration which is given in stage configs as a string
+// such as "30s".  Plain numbers are interpreted as seconds.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case float64:
+		*d = Duration(t * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("parsing duration %q: %s", t, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration value %s", string(data))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package hydrate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func TestNewExecTimeout(t *testing.T) {
+	testCases := []struct {
+		config  string
+		timeout time.Duration
+		valid   bool
+	}{
+		{`{"command": ["true"]}`, DefaultExecTimeout, true},
+		{`{"command": ["true"], "timeout": "2s"}`, 2 * time.Second, true},
+		{`{"command": ["true"], "timeout": 1.5}`, 1500 * time.Millisecond, true},
+		{`{"command": ["true"], "timeout": "0s"}`, 0, false},
+		{`{"command": ["true"], "timeout": 0}`, 0, false},
+		{`{"command": ["true"], "timeout": "-1s"}`, 0, false},
+		{`{"command": ["true"], "timeout": "soon"}`, 0, false},
+		{`{"timeout": "2s"}`, 0, false},
+	}
+	for _, testCase := range testCases {
+		stage, err := NewExec(json.RawMessage(testCase.config))
+		if !testCase.valid {
+			if err == nil {
+				t.Errorf("expected config %s to be rejected", testCase.config)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("config %s: %s", testCase.config, err)
+			continue
+		}
+		if actual := time.Duration(stage.(*Exec).Timeout); actual != testCase.timeout {
+			t.Errorf("config %s: expected timeout %s, got %s", testCase.config, testCase.timeout, actual)
+		}
+	}
+}
+
+func TestExecRun(t *testing.T) {
+	stage, err := NewExec(json.RawMessage(`{"command": ["sh", "-c", "cat >/dev/null; echo '{\"extensions\": {\"linkrot\": {\"dead\": 3}}}'"], "timeout": "5s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &Document{Phase: PhaseEnrich, URL: "https://example.com/", Context: &domain.Context{}}
+	if err := stage.Run(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := `{"dead": 3}`, string(doc.Context.Extensions["linkrot"]); actual != expected {
+		t.Errorf("expected extension %s, got %s", expected, actual)
+	}
+
+	stage, err = NewExec(json.RawMessage(`{"command": ["sleep", "5"], "timeout": "50ms"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Run(context.Background(), doc); err == nil {
+		t.Errorf("expected a timeout error")
+	}
+}
@@ -0,0 +1,232 @@
+package hydrate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/canonical"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+	"jaytaylor.com/circus/pkg/warc"
+)
+
+// NewClient returns an HTTP client which applies policy to every request.
+func NewClient(timeout time.Duration, policy *fetchpolicy.Policy) *http.Client {
+	c := &http.Client{
+		Timeout: timeout,
+		Transport: fetchpolicy.NewTransport(&http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: timeout,
+			}).Dial,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			ExpectContinueTimeout: 1 * time.Second,
+		}, policy),
+	}
+	return c
+}
+
+// download fetches url, following redirects and meta refreshes.  The returned
+// URLs record the redirect chain along with the final and canonical URLs.
+// When recorder is non-nil every HTTP exchange made is captured by it.
+func (h *Hydrator) download(ctx context.Context, url string, recorder *warc.Recorder) ([]byte, *domain.URLs, error) {
+	urls := &domain.URLs{
+		Original: url,
+	}
+	target := url
+	for hops := 0; ; hops++ {
+		data, finalURL, archived, err := h.fetch(ctx, target, urls, recorder)
+		if err != nil {
+			return nil, nil, err
+		}
+		urls.Final = finalURL
+		if archived {
+			// Canonical declarations in archived copies refer to the archive.
+			urls.Canonical = canonical.Clean(finalURL)
+			return data, urls, nil
+		}
+		declared := canonical.FromHTML(data, finalURL)
+		if declared.MetaRefresh != "" && declared.MetaRefresh != finalURL && hops < h.MaxMetaRefreshes {
+			log.WithField("url", finalURL).WithField("refresh", declared.MetaRefresh).Debug("Following meta refresh")
+			urls.Redirects = append(urls.Redirects, declared.MetaRefresh)
+			target = declared.MetaRefresh
+			continue
+		}
+		urls.Canonical = canonical.Resolve(finalURL, declared)
+		return data, urls, nil
+	}
+}
+
+// fetch performs a single GET of url, recording redirects in urls.  When the
+// response is non-2xx, the latest capture from the first archive provider to
+// have one is downloaded instead and archived will be true.
+func (h *Hydrator) fetch(ctx context.Context, url string, urls *domain.URLs, recorder *warc.Recorder) (data []byte, finalURL string, archived bool, err error) {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return nil, "", false, NewError(url, domain.FailureInternal, err)
+	}
+
+	client := *h.Client
+	if recorder != nil {
+		client.Transport = recorder.Wrap(client.Transport)
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= h.MaxRedirects {
+			return fmt.Errorf("stopped after %v redirects", h.MaxRedirects)
+		}
+		urls.Redirects = append(urls.Redirects, req.URL.String())
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", false, NewError(url, domain.FailureFetch, err)
+	}
+
+	finalURL = resp.Request.URL.String()
+
+	if resp.StatusCode/100 != 2 {
+		log.WithField("url", url).WithField("status-code", resp.StatusCode).Error("Received non-2xx response from URL (falling back to archives)")
+		resp.Body.Close()
+		statusErr := &Error{
+			URL:        url,
+			Kind:       domain.FailureHTTPStatus,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("received non-2xx response status code=%v", resp.StatusCode),
+		}
+		capture := h.latestCapture(ctx, url)
+		if capture == "" {
+			return nil, "", false, statusErr
+		}
+		if req, err = newGetRequest(ctx, capture); err != nil {
+			return nil, "", false, NewError(url, domain.FailureArchiveFallback, err)
+		}
+		client.CheckRedirect = nil // Archive redirects are not part of the story's redirect chain.
+		if resp, err = client.Do(req); err != nil {
+			log.WithField("url", capture).Errorf("Received error from URL: %s", err)
+			return nil, "", false, NewError(url, domain.FailureArchiveFallback, fmt.Errorf("even archive fallback failed: %s", err))
+		}
+		if resp.StatusCode/100 != 2 {
+			log.WithField("url", capture).WithField("status-code", resp.StatusCode).Error("Received non-2xx response from URL")
+			resp.Body.Close()
+			return nil, "", false, &Error{
+				URL:        url,
+				Kind:       domain.FailureArchiveFallback,
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("even archive fallback produced non-2xx response status code=%v", resp.StatusCode),
+			}
+		}
+		archived = true
+	}
+
+	if data, err = ioutil.ReadAll(resp.Body); err != nil {
+		resp.Body.Close()
+		return nil, "", false, NewError(url, domain.FailureFetch, fmt.Errorf("reading body from %v: %s", url, err))
+	}
+	if err := resp.Body.Close(); err != nil {
+		return data, "", false, NewError(url, domain.FailureFetch, fmt.Errorf("closing body from %v: %s", url, err))
+	}
+
+	return data, finalURL, archived, nil
+}
+
+// latestCapture asks each archive provider in turn for a capture of url,
+// returning "" when none has one.
+func (h *Hydrator) latestCapture(ctx context.Context, url string) string {
+	for _, archive := range h.Archives {
+		capture, err := archive.Latest(ctx, url)
+		if err != nil {
+			log.WithField("url", url).WithField("archive", archive.Name()).Errorf("Searching archive: %s", err)
+			continue
+		}
+		if capture != "" {
+			log.WithField("url", url).WithField("archive", archive.Name()).WithField("capture", capture).Info("Found archived capture")
+			return capture
+		}
+	}
+	return ""
+}
+
+// get downloads url without following meta refreshes or falling back to
+// archives.
+func (h *Hydrator) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("received non-2xx response status code=%v from %v", resp.StatusCode, url)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body from %v: %s", url, err)
+	}
+	return data, nil
+}
+
+func newGetRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequest("", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating get request to %v: %s", url, err)
+	}
+
+	// User-Agent, cookies and any other headers are applied per-domain by the
+	// fetch policy transport.
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
+
+	return req.WithContext(ctx), nil
+}
+
+// handlePDF downloads the PDF at url and converts it to HTML with pdf2htmlEX.
+// The conversion is killed when ctx is done or PDFTimeout passes.
+func (h *Hydrator) handlePDF(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.PDFTimeout)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "circus-pdf")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := h.get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading PDF: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "in.pdf"), data, os.FileMode(int(0644))); err != nil {
+		return nil, fmt.Errorf("writing PDF: %s", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "pdf2htmlEX", "--auto-hint", "1", "--correct-text-visibility", "1", "--process-annotation", "1", "--dest-dir", dir, filepath.Join(dir, "in.pdf"), "out.html")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Errorf("Timed out after %s processing PDF from %v", h.PDFTimeout, url)
+			return nil, fmt.Errorf("timed out after %s processing PDF from %v", h.PDFTimeout, url)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("converting PDF to HTML: %s (output=%v)", err, string(out))
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(dir, "out.html"))
+	if err != nil {
+		return nil, err
+	}
+	return text, nil
+}
@@ -0,0 +1,209 @@
+package hydrate
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	goose "jaytaylor.com/GoOse"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/academic"
+	"jaytaylor.com/circus/pkg/canonical"
+	"jaytaylor.com/circus/pkg/forge"
+	"jaytaylor.com/circus/pkg/htmlmd"
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+// Handler hydrates a particular kind of link, such as a code repository, in
+// place of downloading the page and extracting its article.
+type Handler interface {
+	Name() string
+
+	// Match reports whether the handler applies to url.
+	Match(url string) bool
+
+	// Handle produces the article for url, along with how url was resolved.
+	Handle(ctx context.Context, url string) (*domain.Article, *domain.URLs, error)
+}
+
+// Forges handles links to code repositories, whose pages GoOse makes poor
+// articles of, with the forge APIs.  The article is the repository README,
+// and its metadata is kept as Article.Repo.
+type Forges struct {
+	Forges []forge.Forge
+}
+
+// NewForges returns a handler for github.com and gitlab.com repositories.
+func NewForges() *Forges {
+	f := &Forges{
+		Forges: []forge.Forge{
+			forge.NewGitHub(),
+			forge.NewGitLab(),
+		},
+	}
+	return f
+}
+
+// Name implements Handler.
+func (f *Forges) Name() string {
+	return "forge"
+}
+
+// Match implements Handler.
+func (f *Forges) Match(url string) bool {
+	_, _, ok := f.find(url)
+	return ok
+}
+
+// Handle implements Handler.
+func (f *Forges) Handle(ctx context.Context, rawURL string) (*domain.Article, *domain.URLs, error) {
+	fg, path, ok := f.find(rawURL)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a repository url: %v", rawURL)
+	}
+	repo, err := fg.Repo(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching %v repository %v: %s", fg.Name(), path, err)
+	}
+
+	title := repo.Path
+	if repo.Description != "" {
+		title = fmt.Sprintf("%v: %v", repo.Path, repo.Description)
+	}
+	gArticle := &goose.Article{
+		Title:           title,
+		MetaDescription: repo.Description,
+		CleanedText:     repo.READMEText(),
+		TopImage:        repo.AvatarURL,
+		FinalURL:        repo.URL,
+		CanonicalLink:   repo.URL,
+	}
+	if u, err := url.Parse(repo.URL); err == nil {
+		gArticle.Domain = u.Hostname()
+	}
+	if gArticle.CleanedText == "" {
+		gArticle.CleanedText = repo.Description
+	}
+
+	article := &domain.Article{
+		Article: gArticle,
+		Repo:    repo,
+	}
+	if repo.README != "" {
+		if article.Markdown, err = htmlmd.ConvertString(repo.README, repo.READMEBase); err != nil {
+			return nil, nil, fmt.Errorf("converting readme: %s", err)
+		}
+	}
+
+	urls := &domain.URLs{
+		Original:  rawURL,
+		Final:     repo.URL,
+		Canonical: canonical.Clean(repo.URL),
+	}
+	return article, urls, nil
+}
+
+// find returns the forge hosting the repository at url, and its path.
+func (f *Forges) find(url string) (forge.Forge, string, bool) {
+	for _, fg := range f.Forges {
+		if path, ok := fg.Parse(url); ok {
+			return fg, path, true
+		}
+	}
+	return nil, "", false
+}
+
+// Papers handles links to academic papers on arXiv, the ACM Digital Library
+// and doi.org with their metadata APIs, rather than the abstract pages and
+// PDFs.  Abstract, PDF and versioned links to the same paper all resolve to
+// one canonical URL, and the citation metadata is kept as Article.Citation.
+type Papers struct {
+	ArXiv *academic.ArXiv
+	DOI   *academic.DOIResolver
+}
+
+// NewPapers returns a handler using the public arXiv API and doi.org.
+func NewPapers() *Papers {
+	p := &Papers{
+		ArXiv: academic.NewArXiv(),
+		DOI:   academic.NewDOIResolver(),
+	}
+	return p
+}
+
+// Name implements Handler.
+func (p *Papers) Name() string {
+	return "paper"
+}
+
+// Match implements Handler.
+func (p *Papers) Match(url string) bool {
+	if _, ok := academic.ParseArXiv(url); ok {
+		return true
+	}
+	_, ok := academic.ParseDOI(url)
+	return ok
+}
+
+// Handle implements Handler.
+func (p *Papers) Handle(ctx context.Context, rawURL string) (*domain.Article, *domain.URLs, error) {
+	var (
+		paper *academic.Paper
+		err   error
+	)
+	if id, ok := academic.ParseArXiv(rawURL); ok {
+		if paper, err = p.ArXiv.Paper(ctx, id); err != nil {
+			return nil, nil, fmt.Errorf("fetching arxiv paper %v: %s", id, err)
+		}
+	} else if doi, ok := academic.ParseDOI(rawURL); ok {
+		if paper, err = p.DOI.Paper(ctx, doi); err != nil {
+			return nil, nil, fmt.Errorf("resolving doi %v: %s", doi, err)
+		}
+	} else {
+		return nil, nil, fmt.Errorf("not a paper url: %v", rawURL)
+	}
+
+	gArticle := &goose.Article{
+		Title:           paper.Title,
+		MetaDescription: paper.Abstract,
+		CleanedText:     paper.Abstract,
+		FinalURL:        paper.URL,
+		CanonicalLink:   paper.URL,
+		PublishDate:     paper.Published,
+	}
+	if u, err := url.Parse(paper.URL); err == nil {
+		gArticle.Domain = u.Hostname()
+	}
+	if gArticle.CleanedText == "" {
+		gArticle.CleanedText = paper.Title
+	}
+
+	article := &domain.Article{
+		Article:  gArticle,
+		Markdown: paperMarkdown(paper),
+		Citation: paper,
+	}
+	urls := &domain.URLs{
+		Original:  rawURL,
+		Final:     paper.URL,
+		Canonical: canonical.Clean(paper.URL),
+	}
+	return article, urls, nil
+}
+
+// paperMarkdown returns the body of a paper's article: its authors, abstract
+// and a link to the full text.
+func paperMarkdown(paper *academic.Paper) string {
+	var parts []string
+	if len(paper.Authors) > 0 {
+		parts = append(parts, "*"+textmanip.MarkdownEscape(strings.Join(paper.Authors, ", "))+"*")
+	}
+	if paper.Abstract != "" {
+		parts = append(parts, textmanip.MarkdownEscape(paper.Abstract))
+	}
+	if paper.PDFURL != "" {
+		parts = append(parts, fmt.Sprintf("[Full text (PDF)](%v)", paper.PDFURL))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,473 @@
+package hydrate
+
+// Downloads articles, extracts their main content and enriches it with
+// statistics, Markdown, mirrored images, a summary and named entities.  A
+// Hydrator holds all of its settings and collaborators, so it can be embedded
+// in any Go program and several can be used side by side.
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	goose "jaytaylor.com/GoOse"
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/academic"
+	"jaytaylor.com/circus/pkg/assets"
+	"jaytaylor.com/circus/pkg/canonical"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+	"jaytaylor.com/circus/pkg/forge"
+	"jaytaylor.com/circus/pkg/hnapi"
+	"jaytaylor.com/circus/pkg/htmlmd"
+	"jaytaylor.com/circus/pkg/render"
+	"jaytaylor.com/circus/pkg/snapshot"
+	"jaytaylor.com/circus/pkg/summarize"
+	"jaytaylor.com/circus/pkg/textstats"
+	"jaytaylor.com/circus/pkg/warc"
+)
+
+var (
+	DefaultTimeout          = 10 * time.Second
+	DefaultPDFTimeout       = 30 * time.Second
+	DefaultSummarySentences = 3
+	DefaultRenderMinWords   = 150
+	DefaultMaxRedirects     = 10
+	DefaultMaxMetaRefreshes = 3
+)
+
+// Hydrator turns URLs into story contexts.
+type Hydrator struct {
+	// Client makes every request for pages, PDFs, images and snapshot
+	// resources.  See NewClient for one which applies a fetch policy.
+	Client *http.Client
+
+	Extractor Extractor
+	NER       NER // Nil skips named-entity tagging.
+
+	// Archives are tried in order for pages which cannot be fetched or have
+	// no article text.
+	Archives []ArchiveProvider
+
+	// Renderer, when set, renders pages yielding fewer than RenderMinWords
+	// words in a headless browser.
+	Renderer       *render.Renderer
+	RenderMinWords int
+
+	Assets           *assets.Store // Nil disables image mirroring.
+	SummarySentences int           // Zero disables summaries.
+
+	// SnapshotDir, when set, receives single-file HTML and WARC snapshots of
+	// each downloaded page, served under SnapshotURL.
+	SnapshotDir string
+	SnapshotURL string
+
+	HN *hnapi.Client // Used by AttachComments.
+
+	PDFTimeout       time.Duration
+	MaxRedirects     int
+	MaxMetaRefreshes int
+
+	// Handlers are tried in order before the generic download and
+	// extraction.  When the matching handler fails, hydration falls back to
+	// the generic path.
+	Handlers []Handler
+
+	// Stages are run after the Hydrator's own work in each phase.  See
+	// AddStage and LoadStages.
+	Stages map[Phase][]Stage
+}
+
+// New returns a Hydrator with the default fetch policy, GoOse extraction,
+// archive.is fallback, GitHub and GitLab repository handling and arXiv and
+// DOI paper handling, and no NER.
+func New() *Hydrator {
+	client := NewClient(DefaultTimeout, fetchpolicy.New())
+	hn := hnapi.New()
+	hn.HTTPClient = client
+	github := forge.NewGitHub()
+	github.HTTPClient = client
+	gitlab := forge.NewGitLab()
+	gitlab.HTTPClient = client
+	arXiv := academic.NewArXiv()
+	arXiv.HTTPClient = client
+	doi := academic.NewDOIResolver()
+	doi.HTTPClient = client
+	h := &Hydrator{
+		Client:           client,
+		Extractor:        Goose{},
+		Archives:         []ArchiveProvider{&ArchiveIs{Timeout: DefaultTimeout}},
+		RenderMinWords:   DefaultRenderMinWords,
+		SummarySentences: DefaultSummarySentences,
+		SnapshotURL:      "/snapshots",
+		HN:               hn,
+		PDFTimeout:       DefaultPDFTimeout,
+		MaxRedirects:     DefaultMaxRedirects,
+		MaxMetaRefreshes: DefaultMaxMetaRefreshes,
+		Handlers: []Handler{
+			&Forges{Forges: []forge.Forge{github, gitlab}},
+			&Papers{ArXiv: arXiv, DOI: doi},
+		},
+	}
+	return h
+}
+
+// Hydrate downloads, extracts and tags the article at url.
+func (h *Hydrator) Hydrate(ctx context.Context, url string) (*domain.Context, error) {
+	var (
+		content  []byte
+		urls     *domain.URLs
+		recorder *warc.Recorder
+		err      error
+	)
+
+	for _, handler := range h.Handlers {
+		if !handler.Match(url) {
+			continue
+		}
+		article, urls, err := handler.Handle(ctx, url)
+		if err == nil {
+			return h.hydrateHandled(ctx, url, article, urls)
+		}
+		if ctx.Err() != nil {
+			return nil, NewError(url, domain.FailureFetch, ctx.Err())
+		}
+		log.WithField("url", url).WithField("handler", handler.Name()).Warnf("Falling back to generic hydration: %s", err)
+		break
+	}
+
+	if strings.HasSuffix(strings.ToLower(url), ".pdf") { // TODO: Make more robust, with a proper HTTP header content-type check.
+		if content, err = h.handlePDF(ctx, url); err != nil {
+			return nil, NewError(url, domain.FailurePDFConvert, fmt.Errorf("downloading and converting PDF to HTML: %s", err))
+		}
+		urls = &domain.URLs{
+			Original:  url,
+			Canonical: canonical.Clean(url),
+		}
+	} else {
+		if h.SnapshotDir != "" {
+			recorder = &warc.Recorder{}
+		}
+		if content, urls, err = h.download(ctx, url, recorder); err != nil {
+			return nil, WrapError(url, domain.FailureFetch, "downloading article", err)
+		}
+	}
+
+	doc := &Document{
+		URL:     url,
+		Content: content,
+		Context: &domain.Context{
+			URLs: urls,
+		},
+	}
+	if err := h.runStages(ctx, PhaseFetch, doc); err != nil {
+		return nil, err
+	}
+	if err := h.extract(ctx, doc, true); err != nil {
+		return nil, err
+	}
+
+	if recorder != nil {
+		base := url
+		if urls.Final != "" {
+			base = urls.Final
+		}
+		if doc.Context.LocalSnapshots, err = h.writeSnapshots(ctx, url, base, content, recorder); err != nil {
+			log.WithField("url", url).Warnf("Writing local snapshots: %s", err)
+		}
+	}
+
+	return h.finish(ctx, doc)
+}
+
+// HydrateHTML extracts and tags already downloaded HTML content.  URL, which
+// may be empty, is where the content came from.  The page itself is not
+// refetched, and no archive fallback is made.
+func (h *Hydrator) HydrateHTML(ctx context.Context, url string, content []byte) (*domain.Context, error) {
+	doc := &Document{
+		URL:     url,
+		Content: content,
+		Context: &domain.Context{},
+	}
+	if url != "" {
+		doc.Context.URLs = &domain.URLs{
+			Original:  url,
+			Final:     url,
+			Canonical: canonical.Resolve(url, canonical.FromHTML(content, url)),
+		}
+	}
+	if err := h.runStages(ctx, PhaseFetch, doc); err != nil {
+		return nil, err
+	}
+	if err := h.extract(ctx, doc, false); err != nil {
+		return nil, err
+	}
+	return h.finish(ctx, doc)
+}
+
+// hydrateHandled enriches and tags an article produced by a handler.
+func (h *Hydrator) hydrateHandled(ctx context.Context, url string, article *domain.Article, urls *domain.URLs) (*domain.Context, error) {
+	doc := &Document{
+		URL: url,
+		Context: &domain.Context{
+			URLs: urls,
+		},
+	}
+	if err := h.runStages(ctx, PhaseFetch, doc); err != nil {
+		return nil, err
+	}
+	if article.Stats == nil {
+		article.Stats = textstats.Analyze(article.CleanedText)
+	}
+	doc.Context.Article = article
+	if err := h.enrich(ctx, doc); err != nil {
+		return nil, err
+	}
+	return h.finish(ctx, doc)
+}
+
+// finish runs the post-process and persist stages.
+func (h *Hydrator) finish(ctx context.Context, doc *Document) (*domain.Context, error) {
+	if err := h.runStages(ctx, PhasePostProcess, doc); err != nil {
+		return nil, err
+	}
+	if err := h.runStages(ctx, PhasePersist, doc); err != nil {
+		return nil, err
+	}
+	return doc.Context, nil
+}
+
+// extract finds the main article in the document content and enriches it.
+// When fallback is true and no article text is found, the page is rendered or
+// its latest archived capture used instead.
+func (h *Hydrator) extract(ctx context.Context, doc *Document, fallback bool) error {
+	target := doc.URL
+	gArticle, err := h.Extractor.Extract(target, doc.Content)
+	if err != nil {
+		return NewError(target, domain.FailureInternal, fmt.Errorf("extracting article: %s", err))
+	}
+
+	article := &domain.Article{
+		Article: gArticle,
+	}
+
+	if fallback && h.Renderer != nil && len(strings.Fields(article.CleanedText)) < h.RenderMinWords {
+		if rendered := h.renderArticle(ctx, target); rendered != nil && len(rendered.CleanedText) > len(article.CleanedText) {
+			article.Article = rendered
+		}
+	}
+
+	if len(article.CleanedText) == 0 {
+		if !fallback {
+			return NewError(target, domain.FailureExtractEmpty, errors.New("no content found in article"))
+		}
+		article, err = h.archivedArticle(ctx, target)
+		if err != nil {
+			return NewError(target, domain.FailureArchiveFallback, fmt.Errorf("no content found in article, and fallback error was: %s", err))
+		}
+		if len(article.CleanedText) == 0 {
+			return NewError(target, domain.FailureExtractEmpty, errors.New("no content found in article, even after applying archive fallback"))
+		}
+	}
+
+	article.Stats = textstats.Analyze(article.CleanedText)
+
+	if article.TopNode != nil && len(article.TopNode.Nodes) > 0 {
+		base := target
+		if urls := doc.Context.URLs; urls != nil && urls.Final != "" {
+			base = urls.Final
+		}
+		article.Markdown = htmlmd.Convert(article.TopNode.Nodes[0], base)
+	}
+
+	doc.Context.Article = article
+	return h.enrich(ctx, doc)
+}
+
+// enrich mirrors images, summarizes and tags the extracted article, running
+// the extract, enrich and NER stages along the way.
+func (h *Hydrator) enrich(ctx context.Context, doc *Document) error {
+	article := doc.Context.Article
+	if err := h.runStages(ctx, PhaseExtract, doc); err != nil {
+		return err
+	}
+
+	if h.Assets != nil {
+		h.mirrorImages(ctx, article)
+	}
+
+	if h.SummarySentences > 0 {
+		summary := summarize.Summarize(article.CleanedText, h.SummarySentences)
+		article.Summary = summary.Summary
+		article.KeySentences = summary.KeySentences
+	}
+
+	if err := h.runStages(ctx, PhaseEnrich, doc); err != nil {
+		return err
+	}
+
+	if h.NER != nil {
+		var err error
+		if article.NamedEntities, err = h.NER.NamedEntities(ctx, article.CleanedText); err != nil {
+			return NewError(doc.URL, domain.FailureNER, fmt.Errorf("tagging article: %s", err))
+		}
+	}
+
+	return h.runStages(ctx, PhaseNER, doc)
+}
+
+// renderArticle loads target in the headless browser and extracts the
+// article from the rendered DOM.  Nil is returned when rendering fails.
+func (h *Hydrator) renderArticle(ctx context.Context, target string) *goose.Article {
+	log.WithField("url", target).Debug("Too little text found, rendering page in browser")
+	content, err := h.Renderer.Render(ctx, target)
+	if err != nil {
+		log.WithField("url", target).Warnf("Rendering page: %s", err)
+		return nil
+	}
+	gArticle, err := h.Extractor.Extract(target, content)
+	if err != nil {
+		log.WithField("url", target).Warnf("Extracting rendered article: %s", err)
+		return nil
+	}
+	return gArticle
+}
+
+// archivedArticle extracts the article from the latest archived capture of
+// url.  The article is empty when no archive has a capture.
+func (h *Hydrator) archivedArticle(ctx context.Context, url string) (*domain.Article, error) {
+	var content []byte
+	if capture := h.latestCapture(ctx, url); capture != "" {
+		var err error
+		if content, err = h.get(ctx, capture); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	gArticle, err := h.Extractor.Extract(url, content)
+	if err != nil {
+		return nil, err
+	}
+	article := &domain.Article{
+		Article: gArticle,
+	}
+	return article, nil
+}
+
+// mirrorImages copies the top image and every image referenced by the
+// article Markdown into the asset store, then points the Markdown at the
+// local copies.  Images which fail to mirror are left as remote references.
+func (h *Hydrator) mirrorImages(ctx context.Context, article *domain.Article) {
+	srcs := assets.ImageRefs(article.Markdown)
+	if article.TopImage != "" {
+		srcs = append([]string{article.TopImage}, srcs...)
+	}
+
+	var (
+		local  = map[string]string{}
+		seen   = map[string]struct{}{}
+		stored = map[string]struct{}{}
+	)
+	for i, src := range srcs {
+		if _, ok := seen[src]; ok {
+			continue
+		}
+		seen[src] = struct{}{}
+		img, err := h.Assets.Mirror(ctx, src)
+		if err != nil {
+			log.Warnf("Mirroring image: %s", err)
+			continue
+		}
+		local[src] = img.URL
+		if _, ok := stored[img.SHA256]; ok {
+			continue
+		}
+		stored[img.SHA256] = struct{}{}
+		img.Top = i == 0 && src == article.TopImage
+		article.Images = append(article.Images, img)
+	}
+	article.Markdown = assets.Rewrite(article.Markdown, local)
+}
+
+// AttachComments fetches the HN discussion thread for story id into dctx and
+// tags the named entities mentioned across it.  dctx is left untouched when
+// either step fails, so callers may carry on without the thread.
+func (h *Hydrator) AttachComments(ctx context.Context, dctx *domain.Context, id int64) error {
+	thread, err := h.HN.Thread(ctx, id)
+	if err != nil {
+		return NewError(fmt.Sprint(id), domain.FailureFetch, fmt.Errorf("fetching comments: %s", err))
+	}
+	if err := ctx.Err(); err != nil {
+		return NewError(fmt.Sprint(id), domain.FailureFetch, err)
+	}
+
+	var texts []string
+	for _, comment := range hnapi.Flatten(thread) {
+		texts = append(texts, comment.PlainText())
+	}
+	if len(texts) == 0 || h.NER == nil {
+		dctx.Thread = thread
+		return nil
+	}
+
+	entities, err := h.NER.NamedEntities(ctx, strings.Join(texts, "\n\n"))
+	if err != nil {
+		return NewError(fmt.Sprint(id), domain.FailureNER, fmt.Errorf("tagging comments: %s", err))
+	}
+	dctx.Thread, dctx.ThreadEntities = thread, entities
+	return nil
+}
+
+// writeSnapshots saves a single-file HTML copy of content and a WARC of the
+// recorded HTTP exchanges to the snapshot directory.
+func (h *Hydrator) writeSnapshots(ctx context.Context, target string, base string, content []byte, recorder *warc.Recorder) ([]*domain.LocalSnapshot, error) {
+	if err := os.MkdirAll(h.SnapshotDir, os.FileMode(int(0755))); err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %s", err)
+	}
+
+	sum := sha256.Sum256([]byte(target))
+	name := fmt.Sprintf("%x-%v", sum[0:8], time.Now().UTC().Format("20060102150405"))
+	snapshots := []*domain.LocalSnapshot{}
+
+	single, err := snapshot.New(h.Client).Single(ctx, content, base)
+	if err != nil {
+		return snapshots, fmt.Errorf("creating single-file html snapshot: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(h.SnapshotDir, name+".html"), single, os.FileMode(int(0644))); err != nil {
+		return snapshots, fmt.Errorf("writing html snapshot: %s", err)
+	}
+	snapshots = append(snapshots, h.localSnapshot(domain.SnapshotHTML, name+".html"))
+
+	f, err := os.Create(filepath.Join(h.SnapshotDir, name+".warc.gz"))
+	if err != nil {
+		return snapshots, fmt.Errorf("creating warc snapshot: %s", err)
+	}
+	if err := recorder.WriteTo(warc.NewWriter(f, true), "circus-hydrator"); err != nil {
+		f.Close()
+		return snapshots, fmt.Errorf("writing warc snapshot: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return snapshots, fmt.Errorf("closing warc snapshot: %s", err)
+	}
+	snapshots = append(snapshots, h.localSnapshot(domain.SnapshotWARC, name+".warc.gz"))
+
+	return snapshots, nil
+}
+
+func (h *Hydrator) localSnapshot(format string, path string) *domain.LocalSnapshot {
+	s := &domain.LocalSnapshot{
+		Format: format,
+		Path:   path,
+		URL:    strings.TrimRight(h.SnapshotURL, "/") + "/" + path,
+	}
+	return s
+}
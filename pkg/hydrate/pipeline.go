@@ -0,0 +1,150 @@
+package hydrate
+
+// Pipeline stages hook custom processing, such as link-rot checks or extra
+// metadata, into hydration without changes to the Hydrator itself.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"jaytaylor.com/circus/domain"
+)
+
+// Phase names a step of hydration.  Stages added to a phase run in order,
+// after the Hydrator's own work for that phase.
+type Phase string
+
+const (
+	PhaseFetch       Phase = "fetch"        // Content downloaded; there is no Article yet.
+	PhaseExtract     Phase = "extract"      // Article extracted, with statistics and Markdown.
+	PhaseEnrich      Phase = "enrich"       // Images mirrored and summary written.
+	PhaseNER         Phase = "ner"          // Named entities tagged.
+	PhasePostProcess Phase = "post-process" // Local snapshots written.
+	PhasePersist     Phase = "persist"      // Context complete, for saving it elsewhere.
+)
+
+// Phases lists every phase, in the order they run.
+var Phases = []Phase{
+	PhaseFetch,
+	PhaseExtract,
+	PhaseEnrich,
+	PhaseNER,
+	PhasePostProcess,
+	PhasePersist,
+}
+
+// Document is the hydration in progress, as seen by stages.
+type Document struct {
+	Phase   Phase
+	URL     string // May be empty for HTML with no known origin.
+	Content []byte // The downloaded HTML; nil for links hydrated by a Handler.
+	Context *domain.Context
+}
+
+// Stage is a step of custom processing.  Stages annotate the document's
+// context, typically with Context.SetExtension, and fetch stages may replace
+// its content.  An error aborts hydration.
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, doc *Document) error
+}
+
+// StageConstructor creates a stage from its JSON configuration, which may be
+// nil.
+type StageConstructor func(config json.RawMessage) (Stage, error)
+
+var stageRegistry = map[string]StageConstructor{}
+
+// RegisterStage makes a stage available to NewStage and stage files under
+// name.
+func RegisterStage(name string, constructor StageConstructor) {
+	stageRegistry[name] = constructor
+}
+
+// StageNames returns the registered stage names, sorted.
+func StageNames() []string {
+	names := make([]string, 0, len(stageRegistry))
+	for name := range stageRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStage creates the stage registered under name.
+func NewStage(name string, config json.RawMessage) (Stage, error) {
+	constructor, ok := stageRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized stage %q, must be one of: %v", name, strings.Join(StageNames(), ", "))
+	}
+	return constructor(config)
+}
+
+// StageConfig is an entry of a stages file.
+type StageConfig struct {
+	Phase  Phase           `json:"phase"`
+	Stage  string          `json:"stage"`            // Registered stage name.
+	Config json.RawMessage `json:"config,omitempty"` // Passed to the stage constructor.
+}
+
+// AddStage appends stage to the stages run in phase.
+func (h *Hydrator) AddStage(phase Phase, stage Stage) {
+	if h.Stages == nil {
+		h.Stages = map[Phase][]Stage{}
+	}
+	h.Stages[phase] = append(h.Stages[phase], stage)
+}
+
+// LoadStages creates the stages listed in filename, a JSON array of
+// StageConfig, for use as Hydrator.Stages.
+func LoadStages(filename string) (map[Phase][]Stage, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading stages: %s", err)
+	}
+	var configs []StageConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parsing stages %v: %s", filename, err)
+	}
+	stages := map[Phase][]Stage{}
+	for i, sc := range configs {
+		if !validPhase(sc.Phase) {
+			return nil, fmt.Errorf("stage %v in %v: unrecognized phase %q", i, filename, sc.Phase)
+		}
+		stage, err := NewStage(sc.Stage, sc.Config)
+		if err != nil {
+			return nil, fmt.Errorf("stage %v in %v: %s", i, filename, err)
+		}
+		stages[sc.Phase] = append(stages[sc.Phase], stage)
+	}
+	return stages, nil
+}
+
+func validPhase(phase Phase) bool {
+	for _, p := range Phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// runStages runs the stages of phase over doc.
+func (h *Hydrator) runStages(ctx context.Context, phase Phase, doc *Document) error {
+	doc.Phase = phase
+	for _, stage := range h.Stages[phase] {
+		if err := ctx.Err(); err != nil {
+			return NewError(doc.URL, domain.FailureStage, err)
+		}
+		log.WithField("url", doc.URL).WithField("phase", phase).WithField("stage", stage.Name()).Debug("Running stage")
+		if err := stage.Run(ctx, doc); err != nil {
+			return NewError(doc.URL, domain.FailureStage, fmt.Errorf("%v stage %v: %s", phase, stage.Name(), err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package hydrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	goose "jaytaylor.com/GoOse"
+	archiveis "jaytaylor.com/archive.is"
+	"jaytaylor.com/circus/domain"
+)
+
+// Extractor finds the main article in an HTML document fetched from url,
+// which may be empty.
+type Extractor interface {
+	Extract(url string, content []byte) (*goose.Article, error)
+}
+
+// Goose extracts articles with GoOse.
+type Goose struct{}
+
+// Extract implements Extractor.
+func (Goose) Extract(url string, content []byte) (*goose.Article, error) {
+	return goose.New().ExtractFromRawHTML(url, string(content))
+}
+
+// NER tags the named entities mentioned in text.
+type NER interface {
+	NamedEntities(ctx context.Context, text string) (domain.NamedEntities, error)
+}
+
+// NLPWeb is the client for the nlpweb.py named-entity recognition sidecar.
+type NLPWeb struct {
+	BaseURL  string       // e.g. "http://127.0.0.1:8000".
+	Instance string       // spaCy model size, one of "sm", "md" or "lg".
+	Client   *http.Client // Defaults to http.DefaultClient.
+}
+
+// NewNLPWeb returns a client for the nlpweb.py instance at baseURL.
+func NewNLPWeb(baseURL string) *NLPWeb {
+	n := &NLPWeb{
+		BaseURL:  baseURL,
+		Instance: "lg",
+	}
+	return n
+}
+
+// NamedEntities implements NER.
+func (n *NLPWeb) NamedEntities(ctx context.Context, text string) (domain.NamedEntities, error) {
+	u := fmt.Sprintf("%v/v1/named-entities?instance=%v", n.BaseURL, n.Instance)
+	req, err := http.NewRequest("POST", u, bytes.NewBufferString(text))
+	if err != nil {
+		return nil, fmt.Errorf("creating ner request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("submitting to ner extractor: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("ner submission received non-2xx response status-code=%v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading ner submission body: %s", err)
+	}
+	nes := domain.NamedEntities{}
+	if err := json.Unmarshal(body, &nes); err != nil {
+		return nil, fmt.Errorf("unmarshalling named entities: %s", err)
+	}
+	return nes, nil
+}
+
+// ArchiveProvider locates archived copies of pages, for pages which can no
+// longer be fetched or have no article text.
+type ArchiveProvider interface {
+	Name() string
+
+	// Latest returns the URL of the most recent capture of url, or "" when
+	// there is none.
+	Latest(ctx context.Context, url string) (string, error)
+}
+
+// ArchiveIs finds captures on archive.is.
+type ArchiveIs struct {
+	Timeout time.Duration
+}
+
+// Name implements ArchiveProvider.
+func (a *ArchiveIs) Name() string {
+	return "archive.is"
+}
+
+// Latest implements ArchiveProvider.
+func (a *ArchiveIs) Latest(ctx context.Context, url string) (string, error) {
+	type result struct {
+		snapshots []archiveis.Snapshot
+		err       error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		snapshots, err := archiveis.Search(url, a.Timeout)
+		ch <- result{snapshots, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return "", r.err
+		}
+		if len(r.snapshots) == 0 {
+			return "", nil
+		}
+		return r.snapshots[0].URL, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,486 @@
+package jobqueue
+
+// A small persistent work queue.  Every job is kept as a JSON file in the
+// queue directory, so queued and interrupted jobs survive restarts.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"jaytaylor.com/circus/domain"
+)
+
+// State is the lifecycle stage of a job.
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Done      State = "done"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// DefaultRetention is how long settled jobs are kept by default.
+const DefaultRetention = 7 * 24 * time.Hour
+
+// pruneInterval is how often running queues remove expired jobs.
+const pruneInterval = time.Hour
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrSettled  = errors.New("job already finished")
+	ErrClosed   = errors.New("queue closed")
+)
+
+// Job is a unit of work along with its outcome.
+type Job struct {
+	ID       string          `json:"id"`
+	State    State           `json:"state"`
+	Request  json.RawMessage `json:"request"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Failure  *domain.Failure `json:"failure,omitempty"`
+	Attempts int             `json:"attempts"`
+	Created  time.Time       `json:"created"`
+	Started  *time.Time      `json:"started,omitempty"`
+	Finished *time.Time      `json:"finished,omitempty"`
+}
+
+// Settled reports whether the job has reached a final state.
+func (job *Job) Settled() bool {
+	return job.State == Done || job.State == Failed || job.State == Cancelled
+}
+
+// Handler performs a job, returning a JSON-serializable result.  ctx is
+// cancelled when the job is cancelled or the queue is closed.  Errors with a
+// Failure() *domain.Failure method have their failure record kept as-is.
+type Handler func(ctx context.Context, job *Job) (interface{}, error)
+
+// Queue holds jobs in memory, mirrored to one file per job in Dir.
+type Queue struct {
+	Dir string
+
+	// Retention is how long jobs are kept after settling, as they hold
+	// entire pushed pages and results.  Zero keeps them forever.
+	Retention time.Duration
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*Job
+	order   []string
+	cancels map[string]context.CancelFunc
+	waiters map[string]chan struct{}
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open loads the queue persisted in dir, creating the directory when needed.
+// Jobs which were running when the previous process stopped are re-queued.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, os.FileMode(int(0755))); err != nil {
+		return nil, fmt.Errorf("creating queue directory: %s", err)
+	}
+	q := &Queue{
+		Dir:       dir,
+		Retention: DefaultRetention,
+		jobs:      map[string]*Job{},
+		cancels:   map[string]context.CancelFunc{},
+		waiters:   map[string]chan struct{}{},
+		done:      make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing queue directory: %s", err)
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading job %v: %s", name, err)
+		}
+		job := &Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			log.WithField("file", name).Warnf("Skipping unreadable job: %s", err)
+			continue
+		}
+		if job.State == Running {
+			log.WithField("job", job.ID).Info("Re-queueing interrupted job")
+			job.State = Queued
+			job.Started = nil
+			if err := q.save(job); err != nil {
+				return nil, err
+			}
+		}
+		q.jobs[job.ID] = job
+		q.order = append(q.order, job.ID)
+	}
+	sort.Slice(q.order, func(i, j int) bool {
+		a, b := q.jobs[q.order[i]], q.jobs[q.order[j]]
+		if a.Created.Equal(b.Created) {
+			return a.ID < b.ID
+		}
+		return a.Created.Before(b.Created)
+	})
+	return q, nil
+}
+
+// Submit adds a job for request, which must be JSON-serializable.
+func (q *Queue) Submit(request interface{}) (*Job, error) {
+	bs, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("serializing job request: %s", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:      id,
+		State:   Queued,
+		Request: bs,
+		Created: time.Now().UTC(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+	q.jobs[job.ID] = job
+	q.order = append(q.order, job.ID)
+	q.cond.Signal()
+	return copyJob(job), nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (q *Queue) Get(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+// List returns snapshots of all jobs, oldest first.  When states are given
+// only jobs in one of those states are included.
+func (q *Queue) List(states ...State) []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := []*Job{}
+	for _, id := range q.order {
+		job := q.jobs[id]
+		if len(states) > 0 && !hasState(states, job.State) {
+			continue
+		}
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs
+}
+
+// Counts returns the number of jobs in each state.
+func (q *Queue) Counts() map[State]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := map[State]int{}
+	for _, job := range q.jobs {
+		counts[job.State]++
+	}
+	return counts
+}
+
+// Wait blocks until the job has settled or ctx is done, then returns the
+// latest snapshot of the job.
+func (q *Queue) Wait(ctx context.Context, id string) (*Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if job.Settled() {
+		defer q.mu.Unlock()
+		return copyJob(job), nil
+	}
+	ch, ok := q.waiters[id]
+	if !ok {
+		ch = make(chan struct{})
+		q.waiters[id] = ch
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+	return q.Get(id)
+}
+
+// Cancel stops a queued or running job.  A running job's handler has its
+// context cancelled and any result it produces is discarded.
+func (q *Queue) Cancel(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if job.Settled() {
+		return copyJob(job), ErrSettled
+	}
+	if cancel, ok := q.cancels[id]; ok {
+		cancel()
+		delete(q.cancels, id)
+	}
+	job.State = Cancelled
+	if err := q.settle(job); err != nil {
+		return nil, err
+	}
+	return copyJob(job), nil
+}
+
+// Prune removes jobs which settled more than Retention ago, returning how
+// many were removed.
+func (q *Queue) Prune() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.Retention <= 0 {
+		return 0, nil
+	}
+	var (
+		cutoff = time.Now().Add(-q.Retention)
+		kept   = make([]string, 0, len(q.order))
+		pruned int
+	)
+	for i, id := range q.order {
+		job := q.jobs[id]
+		if !job.Settled() || job.Finished == nil || job.Finished.After(cutoff) {
+			kept = append(kept, id)
+			continue
+		}
+		if err := os.Remove(filepath.Join(q.Dir, job.ID+".json")); err != nil && !os.IsNotExist(err) {
+			q.order = append(kept, q.order[i:]...)
+			return pruned, fmt.Errorf("removing job %v: %s", job.ID, err)
+		}
+		delete(q.jobs, id)
+		pruned++
+	}
+	q.order = kept
+	return pruned, nil
+}
+
+// Start launches workers goroutines which run queued jobs through handler,
+// and prunes expired jobs now and periodically until the queue is closed.
+func (q *Queue) Start(workers int, handler Handler) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work(handler)
+	}
+	q.wg.Add(1)
+	go q.prune()
+}
+
+// Close stops accepting jobs, cancels running jobs and waits for the workers
+// to exit.  Jobs interrupted this way are left queued for the next Open.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	for _, cancel := range q.cancels {
+		cancel()
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+func (q *Queue) prune() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := q.Prune(); err != nil {
+			log.Errorf("Pruning jobs: %s", err)
+		} else if n > 0 {
+			log.WithField("jobs", n).Info("Pruned expired jobs")
+		}
+		select {
+		case <-ticker.C:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *Queue) work(handler Handler) {
+	defer q.wg.Done()
+
+	for {
+		q.mu.Lock()
+		job := q.next()
+		for job == nil && !q.closed {
+			q.cond.Wait()
+			job = q.next()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		now := time.Now().UTC()
+		job.State = Running
+		job.Started = &now
+		job.Attempts++
+		if err := q.save(job); err != nil {
+			log.WithField("job", job.ID).Error(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		q.cancels[job.ID] = cancel
+		snapshot := copyJob(job)
+		q.mu.Unlock()
+
+		log.WithField("job", job.ID).Debug("Running job")
+		result, err := handler(ctx, snapshot)
+		cancel()
+
+		q.mu.Lock()
+		delete(q.cancels, job.ID)
+		if job.State != Running {
+			// Cancelled while running.
+			q.mu.Unlock()
+			continue
+		}
+		if q.closed {
+			job.State = Queued
+			job.Started = nil
+			if err := q.save(job); err != nil {
+				log.WithField("job", job.ID).Error(err)
+			}
+			q.mu.Unlock()
+			continue
+		}
+		q.finish(job, result, err)
+		q.mu.Unlock()
+	}
+}
+
+// next returns the oldest queued job.  Must be called with mu held.
+func (q *Queue) next() *Job {
+	for _, id := range q.order {
+		if job := q.jobs[id]; job.State == Queued {
+			return job
+		}
+	}
+	return nil
+}
+
+// finish records the outcome of running job.  Must be called with mu held.
+func (q *Queue) finish(job *Job, result interface{}, err error) {
+	if err == nil {
+		if job.Result, err = json.Marshal(result); err != nil {
+			err = fmt.Errorf("serializing job result: %s", err)
+		}
+	}
+	if err != nil {
+		job.State = Failed
+		job.Result = nil
+		if f, ok := err.(interface {
+			Failure() *domain.Failure
+		}); ok {
+			job.Failure = f.Failure()
+		} else {
+			job.Failure = &domain.Failure{
+				Kind:      domain.FailureInternal,
+				Message:   err.Error(),
+				Timestamp: time.Now().UTC(),
+			}
+		}
+		log.WithField("job", job.ID).WithField("kind", job.Failure.Kind).Warnf("Job failed: %s", job.Failure.Message)
+	} else {
+		job.State = Done
+		log.WithField("job", job.ID).Debug("Job done")
+	}
+	if err := q.settle(job); err != nil {
+		log.WithField("job", job.ID).Error(err)
+	}
+}
+
+// settle persists a job which has reached a final state and wakes anyone
+// waiting on it.  Must be called with mu held.
+func (q *Queue) settle(job *Job) error {
+	now := time.Now().UTC()
+	job.Finished = &now
+	if ch, ok := q.waiters[job.ID]; ok {
+		close(ch)
+		delete(q.waiters, job.ID)
+	}
+	return q.save(job)
+}
+
+// save atomically writes job to the queue directory.
+func (q *Queue) save(job *Job) error {
+	bs, err := json.MarshalIndent(job, "", "    ")
+	if err != nil {
+		return fmt.Errorf("serializing job %v: %s", job.ID, err)
+	}
+	name := filepath.Join(q.Dir, job.ID+".json")
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, bs, os.FileMode(int(0644))); err != nil {
+		return fmt.Errorf("writing job %v: %s", job.ID, err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("writing job %v: %s", job.ID, err)
+	}
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating job id: %s", err)
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
+
+func copyJob(job *Job) *Job {
+	c := *job
+	return &c
+}
+
+func hasState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
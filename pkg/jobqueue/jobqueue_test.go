@@ -0,0 +1,371 @@
+package jobqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func tempQueue(t *testing.T) (*Queue, func()) {
+	dir, err := ioutil.TempDir("", "circus-jobqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return q, func() {
+		q.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// readJob reads the persisted copy of a job.
+func readJob(t *testing.T, q *Queue, id string) *Job {
+	data, err := ioutil.ReadFile(filepath.Join(q.Dir, id+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func wait(t *testing.T, q *Queue, id string) *Job {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Wait(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.Settled() {
+		t.Fatalf("job %v did not settle: %v", id, job.State)
+	}
+	return job
+}
+
+type failure struct{}
+
+func (failure) Error() string { return "forbidden" }
+
+func (failure) Failure() *domain.Failure {
+	return &domain.Failure{Kind: domain.FailureHTTPStatus, Message: "forbidden"}
+}
+
+func TestRunJobs(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	q.Start(2, func(ctx context.Context, job *Job) (interface{}, error) {
+		var req string
+		json.Unmarshal(job.Request, &req)
+		switch req {
+		case "fail":
+			return nil, errors.New("plain failure")
+		case "forbidden":
+			return nil, failure{}
+		case "unserializable":
+			return func() {}, nil
+		}
+		return map[string]string{"echo": req}, nil
+	})
+
+	testCases := []struct {
+		request string
+		state   State
+		result  string
+		kind    domain.FailureKind
+	}{
+		{"hello", Done, `{"echo":"hello"}`, ""},
+		{"fail", Failed, "", domain.FailureInternal},
+		{"forbidden", Failed, "", domain.FailureHTTPStatus},
+		{"unserializable", Failed, "", domain.FailureInternal},
+	}
+	for _, testCase := range testCases {
+		submitted, err := q.Submit(testCase.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job := wait(t, q, submitted.ID)
+		if job.State != testCase.state || string(job.Result) != testCase.result || job.Attempts != 1 || job.Started == nil || job.Finished == nil {
+			t.Errorf("%v: unexpected job %+v", testCase.request, job)
+		}
+		if testCase.kind != "" && (job.Failure == nil || job.Failure.Kind != testCase.kind) {
+			t.Errorf("%v: expected a %v failure, got %+v", testCase.request, testCase.kind, job.Failure)
+		}
+		persisted, result := readJob(t, q, job.ID), &bytes.Buffer{}
+		if len(persisted.Result) > 0 {
+			json.Compact(result, persisted.Result)
+		}
+		if persisted.State != job.State || result.String() != string(job.Result) {
+			t.Errorf("%v: persisted job %+v does not match %+v", testCase.request, persisted, job)
+		}
+	}
+	if counts := q.Counts(); counts[Done] != 1 || counts[Failed] != 3 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+	if jobs := q.List(Failed); len(jobs) != 3 {
+		t.Errorf("expected 3 failed jobs, got %v", len(jobs))
+	}
+}
+
+func TestOpenRequeuesRunning(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	var (
+		started = time.Now().UTC()
+		base    = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	jobs := []*Job{
+		{ID: "c", State: Running, Request: json.RawMessage(`"c"`), Attempts: 1, Created: base.Add(2 * time.Minute), Started: &started},
+		{ID: "a", State: Queued, Request: json.RawMessage(`"a"`), Created: base},
+		{ID: "b", State: Done, Request: json.RawMessage(`"b"`), Created: base.Add(time.Minute), Finished: &started},
+	}
+	for _, job := range jobs {
+		if err := q.save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(q.Dir, "corrupt.json"), []byte("{"), os.FileMode(int(0644)))
+
+	reopened, err := Open(q.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	var ids []string
+	for _, job := range reopened.List() {
+		ids = append(ids, job.ID)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected jobs oldest first %v, got %v", expected, ids)
+	}
+	job, err := reopened.Get("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != Queued || job.Started != nil || job.Attempts != 1 {
+		t.Errorf("expected the running job to be re-queued, got %+v", job)
+	}
+	if persisted := readJob(t, reopened, "c"); persisted.State != Queued {
+		t.Errorf("expected the re-queued state to be persisted, got %v", persisted.State)
+	}
+	if job, _ := reopened.Get("b"); job.State != Done {
+		t.Errorf("expected the settled job to be left alone, got %v", job.State)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	// Queued jobs are cancelled without running.
+	queued, err := q.Submit("queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Cancel(queued.ID)
+	if err != nil || job.State != Cancelled || job.Finished == nil {
+		t.Fatalf("expected a cancelled job, got %+v, %v", job, err)
+	}
+	if _, err := q.Cancel(queued.ID); err != ErrSettled {
+		t.Errorf("expected ErrSettled cancelling again, got %v", err)
+	}
+	if _, err := q.Cancel("missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Running jobs have their context cancelled and their result discarded.
+	var (
+		running  = make(chan struct{})
+		returned = make(chan struct{})
+	)
+	q.Start(1, func(ctx context.Context, job *Job) (interface{}, error) {
+		defer close(returned)
+		close(running)
+		<-ctx.Done()
+		return "discarded", nil
+	})
+	submitted, err := q.Submit("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running
+	if job, err := q.Cancel(submitted.ID); err != nil || job.State != Cancelled {
+		t.Fatalf("expected a cancelled job, got %+v, %v", job, err)
+	}
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler context was not cancelled")
+	}
+	// Let the worker record (and discard) the handler's result.
+	q.Close()
+	job, _ = q.Get(submitted.ID)
+	if job.State != Cancelled || job.Result != nil {
+		t.Errorf("expected the result to be discarded, got %+v", job)
+	}
+	if _, err := q.Submit("late"); err != ErrClosed {
+		t.Errorf("expected ErrClosed submitting to a closed queue, got %v", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	if _, err := q.Wait(context.Background(), "missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	submitted, err := q.Submit("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Waits give up with the latest snapshot when their context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	job, err := q.Wait(ctx, submitted.ID)
+	cancel()
+	if err != nil || job.State != Queued {
+		t.Errorf("expected a queued snapshot, got %+v, %v", job, err)
+	}
+
+	// Every waiter wakes when the job settles.
+	release := make(chan struct{})
+	results := make(chan *Job, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			job, err := q.Wait(context.Background(), submitted.ID)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- job
+		}()
+	}
+	q.Start(1, func(ctx context.Context, job *Job) (interface{}, error) {
+		<-release
+		return "ok", nil
+	})
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case job := <-results:
+			if job.State != Done {
+				t.Errorf("expected a done job, got %v", job.State)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("waiter was not woken")
+		}
+	}
+
+	// Settled jobs return immediately.
+	if job := wait(t, q, submitted.ID); string(job.Result) != `"ok"` {
+		t.Errorf("unexpected result %s", job.Result)
+	}
+}
+
+func TestCloseRequeuesRunning(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	running := make(chan struct{})
+	q.Start(1, func(ctx context.Context, job *Job) (interface{}, error) {
+		close(running)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	submitted, err := q.Submit("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-running
+	q.Close()
+
+	job, _ := q.Get(submitted.ID)
+	if job.State != Queued || job.Started != nil || job.Failure != nil {
+		t.Errorf("expected the interrupted job to be re-queued, got %+v", job)
+	}
+	if persisted := readJob(t, q, submitted.ID); persisted.State != Queued || persisted.Attempts != 1 {
+		t.Errorf("expected the re-queued job to be persisted, got %+v", persisted)
+	}
+
+	// The next queue picks it up again.
+	reopened, err := Open(q.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	reopened.Start(1, func(ctx context.Context, job *Job) (interface{}, error) {
+		return job.Attempts, nil
+	})
+	if job := wait(t, reopened, submitted.ID); job.State != Done || string(job.Result) != "2" {
+		t.Errorf("expected the job to complete on its second attempt, got %+v", job)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	q, cleanup := tempQueue(t)
+	defer cleanup()
+
+	var (
+		old    = time.Now().Add(-2 * time.Hour).UTC()
+		recent = time.Now().Add(-time.Minute).UTC()
+	)
+	jobs := []*Job{
+		{ID: "old-done", State: Done, Created: old.Add(-time.Minute), Finished: &old},
+		{ID: "old-failed", State: Failed, Created: old.Add(-time.Minute), Finished: &old},
+		{ID: "recent-done", State: Done, Created: recent.Add(-time.Minute), Finished: &recent},
+		{ID: "old-queued", State: Queued, Created: old},
+	}
+	for _, job := range jobs {
+		if err := q.save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q, err := Open(q.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	q.Retention = 0
+	if n, err := q.Prune(); n != 0 || err != nil {
+		t.Errorf("expected nothing pruned without a retention period, got %v, %v", n, err)
+	}
+
+	q.Retention = time.Hour
+	if n, err := q.Prune(); n != 2 || err != nil {
+		t.Errorf("expected 2 jobs pruned, got %v, %v", n, err)
+	}
+	var ids []string
+	for _, job := range q.List() {
+		ids = append(ids, job.ID)
+	}
+	if expected := []string{"old-queued", "recent-done"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v to remain, got %v", expected, ids)
+	}
+	for _, id := range []string{"old-done", "old-failed"} {
+		if _, err := os.Stat(filepath.Join(q.Dir, id+".json")); !os.IsNotExist(err) {
+			t.Errorf("expected %v to be deleted, got %v", id, err)
+		}
+		if _, err := q.Get(id); err != ErrNotFound {
+			t.Errorf("expected %v to be forgotten, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package logging
+
+// Log setup shared by every circus command.
+
+import (
+	"sync"
+
+	"github.com/onrik/logrus/filename"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+var hookOnce sync.Once
+
+// AddFlags registers the --quiet and --verbose flags on flags.
+func AddFlags(flags *pflag.FlagSet, quiet *bool, verbose *bool) {
+	flags.BoolVarP(quiet, "quiet", "q", false, "Activate quiet log output")
+	flags.BoolVarP(verbose, "verbose", "v", false, "Activate verbose log output")
+}
+
+// Init sets the log level, with quiet taking precedence over verbose, and
+// adds filenames and line numbers to log lines.
+func Init(quiet bool, verbose bool) {
+	hookOnce.Do(func() {
+		log.AddHook(filename.NewHook())
+	})
+
+	level := log.InfoLevel
+	if verbose {
+		level = log.DebugLevel
+	}
+	if quiet {
+		level = log.ErrorLevel
+	}
+	log.SetLevel(level)
+}
@@ -0,0 +1,239 @@
+package render
+
+// Renders JavaScript-heavy pages in a local headless Chromium via the
+// DevTools protocol, for sites which serve an empty shell to plain fetches.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	log "github.com/sirupsen/logrus"
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+)
+
+var (
+	DefaultTimeout     = 30 * time.Second
+	DefaultIdleTime    = 500 * time.Millisecond
+	DefaultIdleTimeout = 10 * time.Second
+
+	// ErrNoBrowser is returned by New when no browser binary can be found.
+	ErrNoBrowser = errors.New("no chromium or chrome binary found")
+
+	// ErrRoot is returned by Render when running as root without NoSandbox,
+	// as Chromium will only start as root with its sandbox disabled.
+	ErrRoot = errors.New("refusing to render as root without the browser sandbox (set NoSandbox to opt in)")
+
+	// browserNames are looked up on the PATH, in order of preference.
+	browserNames = []string{
+		"chromium",
+		"chromium-browser",
+		"google-chrome",
+		"google-chrome-stable",
+		"chrome",
+		"headless-shell",
+	}
+)
+
+// Renderer loads pages in headless Chromium and returns the resulting DOM.
+type Renderer struct {
+	BrowserPath string
+	UserAgent   string
+
+	// Policy, when set, must allow the page to be fetched, and the render
+	// counts against its per-host concurrency and delay limits.  Transport
+	// is used to fetch robots.txt, defaulting to http.DefaultTransport.
+	Policy    *fetchpolicy.Policy
+	Transport http.RoundTripper
+
+	// NoSandbox disables Chromium's sandbox, which is required to render as
+	// root.  Pages are then rendered without any isolation from the host.
+	NoSandbox bool
+
+	// Timeout bounds an entire render, including browser startup.
+	Timeout time.Duration
+
+	// IdleTime is how long the network must be quiet, after the load event,
+	// for the page to be considered rendered.
+	IdleTime time.Duration
+
+	// IdleTimeout caps the wait for network idle; pages which never go quiet
+	// (e.g. long-polling) are captured as they are when it expires.
+	IdleTimeout time.Duration
+}
+
+// New returns a renderer using the browser at browserPath, or the first
+// browser found by FindBrowser when browserPath is empty.
+func New(browserPath string) (*Renderer, error) {
+	if browserPath == "" {
+		if browserPath = FindBrowser(); browserPath == "" {
+			return nil, ErrNoBrowser
+		}
+	} else if _, err := os.Stat(browserPath); err != nil {
+		if browserPath, err = exec.LookPath(browserPath); err != nil {
+			return nil, fmt.Errorf("%s: %v", ErrNoBrowser, err)
+		}
+	}
+	r := &Renderer{
+		BrowserPath: browserPath,
+		Timeout:     DefaultTimeout,
+		IdleTime:    DefaultIdleTime,
+		IdleTimeout: DefaultIdleTimeout,
+	}
+	return r, nil
+}
+
+// FindBrowser returns the path to a Chromium or Chrome binary, preferring the
+// CHROME_PATH environment variable, or "" when there is none.
+func FindBrowser() string {
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	for _, name := range browserNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Render navigates to target and returns the page's outer HTML once the load
+// event has fired and the network has gone idle.  The browser is killed when
+// ctx is done or Timeout passes.  Only the page itself is checked against
+// Policy, not the resources it loads.
+func (r *Renderer) Render(ctx context.Context, target string) ([]byte, error) {
+	if os.Geteuid() == 0 && !r.NoSandbox {
+		return nil, ErrRoot
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url %v: %s", target, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	userAgent := r.UserAgent
+	if r.Policy != nil {
+		transport := r.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		allowed, err := r.Policy.Allowed(ctx, transport, u)
+		if err != nil {
+			return nil, fmt.Errorf("checking robots.txt for %v: %s", target, err)
+		}
+		if !allowed {
+			return nil, &fetchpolicy.DisallowedError{URL: target}
+		}
+		release, err := r.Policy.Acquire(ctx, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if ua := r.Policy.For(u.Host).UserAgent; ua != "" {
+			userAgent = ua
+		}
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(r.BrowserPath),
+		chromedp.DisableGPU,
+	)
+	if userAgent != "" {
+		opts = append(opts, chromedp.UserAgent(userAgent))
+	}
+	if r.NoSandbox {
+		opts = append(opts, chromedp.NoSandbox)
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	tracker := newActivityTracker()
+	chromedp.ListenTarget(browserCtx, tracker.observe)
+
+	var html string
+	err = chromedp.Run(browserCtx,
+		network.Enable(),
+		chromedp.Navigate(target),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return tracker.waitIdle(ctx, r.IdleTime, r.IdleTimeout)
+		}),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("rendering %v: %s", target, err)
+	}
+	return []byte(html), nil
+}
+
+// activityTracker counts in-flight network requests.
+type activityTracker struct {
+	mu       sync.Mutex
+	inflight map[network.RequestID]struct{}
+	last     time.Time
+}
+
+func newActivityTracker() *activityTracker {
+	t := &activityTracker{
+		inflight: map[network.RequestID]struct{}{},
+		last:     time.Now(),
+	}
+	return t
+}
+
+func (t *activityTracker) observe(ev interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		t.inflight[e.RequestID] = struct{}{}
+	case *network.EventLoadingFinished:
+		delete(t.inflight, e.RequestID)
+	case *network.EventLoadingFailed:
+		delete(t.inflight, e.RequestID)
+	default:
+		return
+	}
+	t.last = time.Now()
+}
+
+// waitIdle blocks until no requests have been in flight for idle, or
+// timeout passes.
+func (t *activityTracker) waitIdle(ctx context.Context, idle time.Duration, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		t.mu.Lock()
+		quiet := len(t.inflight) == 0 && time.Since(t.last) >= idle
+		pending := len(t.inflight)
+		t.mu.Unlock()
+		if quiet {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			log.WithField("pending-requests", pending).Debugf("Network not idle after %s, capturing page anyway", timeout)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"jaytaylor.com/circus/pkg/fetchpolicy"
+)
+
+func TestRenderRefusesRootWithSandbox(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("only applies when running as root")
+	}
+	r := &Renderer{BrowserPath: "/nonexistent/chromium", Timeout: DefaultTimeout}
+	if _, err := r.Render(context.Background(), "https://example.com/"); err != ErrRoot {
+		t.Errorf("expected ErrRoot, got %v", err)
+	}
+}
+
+func TestRenderHonorsPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		t.Errorf("unexpected request for %v", r.URL.Path)
+	}))
+	defer server.Close()
+
+	respect := true
+	policy := fetchpolicy.New()
+	policy.Default.RespectRobots = &respect
+
+	// The browser is never started for a disallowed page.
+	r := &Renderer{
+		BrowserPath: "/nonexistent/chromium",
+		Timeout:     DefaultTimeout,
+		Policy:      policy,
+		NoSandbox:   true,
+	}
+	_, err := r.Render(context.Background(), server.URL+"/private/page")
+	if _, ok := err.(*fetchpolicy.DisallowedError); !ok {
+		t.Errorf("expected a DisallowedError, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuthConfig holds the API tokens allowed to use the API, loaded from a JSON
+// file such as:
+//
+//	{
+//	    "tokens": {"laptop": "3c1f...", "phone": "9ab2..."},
+//	    "allowedOrigins": ["*"]
+//	}
+//
+// Token names only appear in logs.  AllowedOrigins controls CORS for browser
+// extensions and bookmarklets posting from other sites.
+type AuthConfig struct {
+	Tokens         map[string]string `json:"tokens"`
+	AllowedOrigins []string          `json:"allowedOrigins,omitempty"`
+}
+
+// LoadAuthConfig reads and validates the auth config at path.
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading auth config: %s", err)
+	}
+	config := &AuthConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unmarshalling auth config: %s", err)
+	}
+	if len(config.Tokens) == 0 {
+		return nil, errors.New("auth config has no tokens")
+	}
+	for name, token := range config.Tokens {
+		if len(token) < 16 {
+			return nil, fmt.Errorf("auth config token %q is too short (minimum 16 characters)", name)
+		}
+	}
+	return config, nil
+}
+
+// Authenticate returns the name of the token presented as a bearer token in
+// r's Authorization header, or false when it is missing or unknown.
+func (config *AuthConfig) Authenticate(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	presented := []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	for name, token := range config.Tokens {
+		if subtle.ConstantTimeCompare(presented, []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// requireToken wraps next so requests must carry a valid token, and answers
+// CORS preflight requests from allowed origins.
+func (config *AuthConfig) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && config.originAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		name, ok := config.Authenticate(r)
+		if !ok {
+			log.WithField("path", r.URL.Path).WithField("remote", r.RemoteAddr).Warn("Rejected unauthenticated request")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="circus"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		log.WithField("path", r.URL.Path).WithField("token", name).Debug("Authenticated request")
+		next(w, r)
+	}
+}
+
+func (config *AuthConfig) originAllowed(origin string) bool {
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package server
+
+// REST API for submitting hydration jobs to a long-running hydrator.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"jaytaylor.com/circus/pkg/jobqueue"
+)
+
+// DefaultMaxWait caps how long a long-poll request may block.
+const DefaultMaxWait = 60 * time.Second
+
+// MaxPageBytes limits the size of job and pushed page bodies.
+const MaxPageBytes = 32 << 20
+
+// HydrateRequest is the body of a job submission.  Either URL or HTML must
+// be given; when HTML is present it is used as the page content instead of
+// fetching URL.
+type HydrateRequest struct {
+	URL      string `json:"url"`
+	HTML     string `json:"html,omitempty"`
+	Title    string `json:"title,omitempty"`
+	HNID     int64  `json:"hnId,omitempty"`     // HN story ID, for comment capture.
+	Comments bool   `json:"comments,omitempty"` // Capture the HN comment thread.
+}
+
+// JobRequest is what the server queues: a HydrateRequest along with options
+// which only the server sets, and clients therefore cannot.
+type JobRequest struct {
+	HydrateRequest
+	Save bool `json:"save,omitempty"` // Store the result as a story; only set for authenticated /v1/pages submissions.
+}
+
+// Validate checks the request is well formed.
+func (req *HydrateRequest) Validate() error {
+	if req.URL == "" && req.HTML == "" {
+		return errors.New("one of url or html is required")
+	}
+	if req.URL != "" {
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url: %s", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid url %q: scheme must be http or https", req.URL)
+		}
+	}
+	if req.Comments && req.HNID == 0 {
+		return errors.New("comments requires hnId")
+	}
+	return nil
+}
+
+// Server exposes a job queue over HTTP:
+//
+//	POST   /v1/jobs             submit a HydrateRequest, returns the job
+//	GET    /v1/jobs[?state=s]   list jobs
+//	GET    /v1/jobs/{id}[?wait=30s]  get a job, optionally long-polling
+//	DELETE /v1/jobs/{id}        cancel a job
+//	POST   /v1/pages            push a rendered page to be saved as a story
+//	GET    /healthz             queue and NLP sidecar status
+//
+// When Auth is set the /v1 endpoints require one of its tokens; /v1/pages is
+// disabled when Auth is nil.
+type Server struct {
+	Queue      *jobqueue.Queue
+	NLPWebURL  string
+	HTTPClient *http.Client
+	MaxWait    time.Duration
+	Auth       *AuthConfig
+}
+
+// New returns a server for queue which checks the NLPWeb sidecar at
+// nlpWebURL.
+func New(queue *jobqueue.Queue, nlpWebURL string) *Server {
+	s := &Server{
+		Queue:      queue,
+		NLPWebURL:  nlpWebURL,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		MaxWait:    DefaultMaxWait,
+	}
+	return s
+}
+
+// Handler returns the HTTP handler for the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	if s.Auth != nil {
+		mux.HandleFunc("/v1/jobs", s.Auth.requireToken(s.jobs))
+		mux.HandleFunc("/v1/jobs/", s.Auth.requireToken(s.job))
+		mux.HandleFunc("/v1/pages", s.Auth.requireToken(s.pages))
+	} else {
+		mux.HandleFunc("/v1/jobs", s.jobs)
+		mux.HandleFunc("/v1/jobs/", s.job)
+	}
+	return logRequests(mux)
+}
+
+func (s *Server) jobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var states []jobqueue.State
+		for _, state := range r.URL.Query()["state"] {
+			states = append(states, jobqueue.State(state))
+		}
+		writeJSON(w, http.StatusOK, s.Queue.List(states...))
+
+	case http.MethodPost:
+		// Only JSON is accepted so that cross-site form posts, which browsers
+		// send without a CORS preflight, cannot submit jobs.
+		if !isJSON(r) {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("content-type must be application/json"))
+			return
+		}
+		req := &HydrateRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxPageBytes)).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %s", err))
+			return
+		}
+		if err := req.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.submit(w, &JobRequest{HydrateRequest: *req})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+	}
+}
+
+// PageRequest is the body of a /v1/pages submission: a page as rendered in
+// the browser.
+type PageRequest struct {
+	URL   string `json:"url"`
+	HTML  string `json:"html"`
+	Title string `json:"title,omitempty"`
+}
+
+// pages queues a pushed page for extraction and tagging without refetching
+// it, saving the result as a story.
+func (s *Server) pages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	page := &PageRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxPageBytes)).Decode(page); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %s", err))
+		return
+	}
+	if page.URL == "" || page.HTML == "" {
+		writeError(w, http.StatusBadRequest, errors.New("url and html are required"))
+		return
+	}
+	req := &JobRequest{
+		HydrateRequest: HydrateRequest{
+			URL:   page.URL,
+			HTML:  page.HTML,
+			Title: strings.TrimSpace(page.Title),
+		},
+		Save: true,
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.submit(w, req)
+}
+
+func (s *Server) submit(w http.ResponseWriter, request interface{}) {
+	job, err := s.Queue.Submit(request)
+	if err == jobqueue.ErrClosed {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) job(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/jobs/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, jobqueue.ErrNotFound)
+		return
+	}
+
+	var (
+		job *jobqueue.Job
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		if wait := r.URL.Query().Get("wait"); wait != "" {
+			d, parseErr := time.ParseDuration(wait)
+			if parseErr != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid wait duration: %s", parseErr))
+				return
+			}
+			if s.MaxWait > 0 && d > s.MaxWait {
+				d = s.MaxWait
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			job, err = s.Queue.Wait(ctx, id)
+			cancel()
+		} else {
+			job, err = s.Queue.Get(id)
+		}
+
+	case http.MethodDelete:
+		job, err = s.Queue.Cancel(id)
+		if err == jobqueue.ErrSettled {
+			writeJSON(w, http.StatusConflict, job)
+			return
+		}
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+
+	if err == jobqueue.ErrNotFound {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// Health is the /healthz response.
+type Health struct {
+	Status string                 `json:"status"` // "ok" or "degraded".
+	NLPWeb NLPWebHealth           `json:"nlpweb"`
+	Jobs   map[jobqueue.State]int `json:"jobs"`
+}
+
+// NLPWebHealth describes the reachability of the NLP sidecar.
+type NLPWebHealth struct {
+	URL     string `json:"url"`
+	OK      bool   `json:"ok"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	health := &Health{
+		Status: "ok",
+		NLPWeb: s.checkNLPWeb(),
+		Jobs:   s.Queue.Counts(),
+	}
+	status := http.StatusOK
+	if !health.NLPWeb.OK {
+		health.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+func (s *Server) checkNLPWeb() NLPWebHealth {
+	h := NLPWebHealth{
+		URL: s.NLPWebURL,
+	}
+	start := time.Now()
+	resp, err := s.HTTPClient.Get(strings.TrimRight(s.NLPWebURL, "/") + "/")
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	resp.Body.Close()
+	h.Latency = time.Since(start).String()
+	if resp.StatusCode/100 != 2 {
+		h.Error = fmt.Sprintf("received non-2xx response status code=%v", resp.StatusCode)
+		return h
+	}
+	h.OK = true
+	return h
+}
+
+// isJSON reports whether r's body is declared as JSON.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	bs, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		log.Errorf("Serializing response: %s", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(bs, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.WithField("method", r.Method).WithField("path", r.URL.Path).WithField("duration", time.Since(start)).Debug("Handled request")
+	})
+}
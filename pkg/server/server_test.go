@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jaytaylor.com/circus/pkg/jobqueue"
+)
+
+const testToken = "0123456789abcdef0123"
+
+func newTestServer(t *testing.T, auth *AuthConfig) (*httptest.Server, *jobqueue.Queue, func()) {
+	dir, err := ioutil.TempDir("", "circus-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := jobqueue.Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	s := New(queue, "")
+	s.Auth = auth
+	server := httptest.NewServer(s.Handler())
+	return server, queue, func() {
+		server.Close()
+		queue.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func do(t *testing.T, method string, u string, contentType string, token string, body string) int {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSubmitContentType(t *testing.T) {
+	server, _, done := newTestServer(t, nil)
+	defer done()
+
+	body := `{"url":"https://example.com/"}`
+	testCases := []struct {
+		contentType string
+		status      int
+	}{
+		{"", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"multipart/form-data; boundary=x", http.StatusUnsupportedMediaType},
+		{"application/json", http.StatusAccepted},
+		{"application/json; charset=utf-8", http.StatusAccepted},
+	}
+	for _, testCase := range testCases {
+		if status := do(t, "POST", server.URL+"/v1/jobs", testCase.contentType, "", body); status != testCase.status {
+			t.Errorf("POST with content-type %q: expected status %v, got %v", testCase.contentType, testCase.status, status)
+		}
+	}
+}
+
+func TestSubmitTooLarge(t *testing.T) {
+	server, queue, done := newTestServer(t, nil)
+	defer done()
+
+	body := `{"url":"https://example.com/","html":"` + strings.Repeat("x", MaxPageBytes) + `"}`
+	if status := do(t, "POST", server.URL+"/v1/jobs", "application/json", "", body); status != http.StatusBadRequest {
+		t.Errorf("expected status %v for an oversized job, got %v", http.StatusBadRequest, status)
+	}
+	if jobs := queue.List(); len(jobs) != 0 {
+		t.Errorf("expected no jobs to be queued, got %v", len(jobs))
+	}
+}
+
+func TestJobsRequireToken(t *testing.T) {
+	server, _, done := newTestServer(t, &AuthConfig{Tokens: map[string]string{"test": testToken}})
+	defer done()
+
+	body := `{"url":"https://example.com/"}`
+	testCases := []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"POST", "/v1/jobs", "", http.StatusUnauthorized},
+		{"POST", "/v1/jobs", "wrong-token-wrong-token", http.StatusUnauthorized},
+		{"GET", "/v1/jobs", "", http.StatusUnauthorized},
+		{"GET", "/v1/jobs/missing", "", http.StatusUnauthorized},
+		{"DELETE", "/v1/jobs/missing", "", http.StatusUnauthorized},
+		{"POST", "/v1/pages", "", http.StatusUnauthorized},
+		{"POST", "/v1/jobs", testToken, http.StatusAccepted},
+		{"GET", "/v1/jobs", testToken, http.StatusOK},
+		{"GET", "/v1/jobs/missing", testToken, http.StatusNotFound},
+		{"GET", "/healthz", "", http.StatusServiceUnavailable},
+	}
+	for _, testCase := range testCases {
+		if status := do(t, testCase.method, server.URL+testCase.path, "application/json", testCase.token, body); status != testCase.status {
+			t.Errorf("%v %v (token=%q): expected status %v, got %v", testCase.method, testCase.path, testCase.token, testCase.status, status)
+		}
+	}
+}
+
+func TestOnlyPagesSave(t *testing.T) {
+	server, queue, done := newTestServer(t, &AuthConfig{Tokens: map[string]string{"test": testToken}})
+	defer done()
+
+	if status := do(t, "POST", server.URL+"/v1/jobs", "application/json", testToken, `{"url":"https://example.com/","html":"<p>x</p>","save":true}`); status != http.StatusAccepted {
+		t.Fatalf("expected status %v, got %v", http.StatusAccepted, status)
+	}
+	if status := do(t, "POST", server.URL+"/v1/pages", "application/json", testToken, `{"url":"https://example.com/page","html":"<p>x</p>"}`); status != http.StatusAccepted {
+		t.Fatalf("expected status %v, got %v", http.StatusAccepted, status)
+	}
+
+	saves := map[string]bool{}
+	for _, job := range queue.List() {
+		req := &JobRequest{}
+		if err := json.Unmarshal(job.Request, req); err != nil {
+			t.Fatal(err)
+		}
+		saves[req.URL] = req.Save
+	}
+	if expected := map[string]bool{"https://example.com/": false, "https://example.com/page": true}; !reflect.DeepEqual(saves, expected) {
+		t.Errorf("expected queued save flags %v, got %v", expected, saves)
+	}
+}
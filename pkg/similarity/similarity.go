@@ -0,0 +1,202 @@
+package similarity
+
+// Related-story recommendations based on TF-IDF vectors of article text
+// combined with overlap of named entities.
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+const (
+	// maxTerms caps the number of highest-weighted terms kept per document.
+	maxTerms = 250
+
+	// textWeight is the share of the final score contributed by text
+	// similarity, the remainder comes from shared entities.
+	textWeight = 0.7
+)
+
+// Match is a related story along with its similarity score in [0, 1].
+type Match struct {
+	Context *domain.Context
+	Score   float64
+}
+
+// Index holds the vectors for a corpus of stories.
+type Index struct {
+	contexts []*domain.Context
+	vectors  []vector
+	entities []map[string]float64
+	position map[*domain.Context]int
+
+	mu    sync.Mutex
+	cache map[int][]Match
+}
+
+// vector is a sparse, L2-normalized term weight vector.
+type vector map[string]float64
+
+// NewIndex computes TF-IDF and entity vectors for every story in contexts.
+func NewIndex(contexts []*domain.Context) *Index {
+	idx := &Index{
+		contexts: contexts,
+		vectors:  make([]vector, len(contexts)),
+		entities: make([]map[string]float64, len(contexts)),
+		position: make(map[*domain.Context]int, len(contexts)),
+		cache:    map[int][]Match{},
+	}
+
+	var (
+		termFreqs = make([]map[string]int, len(contexts))
+		docFreqs  = map[string]int{}
+	)
+	for i, ctx := range contexts {
+		idx.position[ctx] = i
+		tf := map[string]int{}
+		if ctx.Article != nil && ctx.Article.Article != nil {
+			for _, word := range textmanip.Words(ctx.Article.CleanedText) {
+				if len(word) < 3 || textmanip.IsStopWord(word) {
+					continue
+				}
+				tf[word]++
+			}
+			idx.entities[i] = entityWeights(ctx.Article.NamedEntities)
+		}
+		for term := range tf {
+			docFreqs[term]++
+		}
+		termFreqs[i] = tf
+	}
+
+	n := float64(len(contexts))
+	for i, tf := range termFreqs {
+		v := vector{}
+		for term, freq := range tf {
+			v[term] = (1 + math.Log(float64(freq))) * math.Log(1+n/float64(docFreqs[term]))
+		}
+		idx.vectors[i] = truncate(v, maxTerms).normalize()
+	}
+	return idx
+}
+
+// Related returns the k stories most similar to ctx, best first.  Stories with
+// no similarity at all are never returned.
+func (idx *Index) Related(ctx *domain.Context, k int) []Match {
+	i, ok := idx.position[ctx]
+	if !ok || k <= 0 {
+		return nil
+	}
+
+	idx.mu.Lock()
+	matches, ok := idx.cache[i]
+	idx.mu.Unlock()
+
+	if !ok {
+		for j := range idx.contexts {
+			if j == i {
+				continue
+			}
+			score := textWeight*idx.vectors[i].cosine(idx.vectors[j]) + (1-textWeight)*weightedJaccard(idx.entities[i], idx.entities[j])
+			if score > 0 {
+				matches = append(matches, Match{Context: idx.contexts[j], Score: score})
+			}
+		}
+		sort.SliceStable(matches, func(a, b int) bool {
+			return matches[a].Score > matches[b].Score
+		})
+
+		idx.mu.Lock()
+		idx.cache[i] = matches
+		idx.mu.Unlock()
+	}
+
+	if k > len(matches) {
+		k = len(matches)
+	}
+	return matches[0:k]
+}
+
+func (v vector) normalize() vector {
+	var sum float64
+	for _, w := range v {
+		sum += w * w
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	for term, w := range v {
+		v[term] = w / norm
+	}
+	return v
+}
+
+func (v vector) cosine(other vector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+	var dot float64
+	for term, w := range v {
+		dot += w * other[term]
+	}
+	return dot
+}
+
+// truncate keeps only the n highest-weighted terms.
+func truncate(v vector, n int) vector {
+	if len(v) <= n {
+		return v
+	}
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(a, b int) bool {
+		if v[terms[a]] != v[terms[b]] {
+			return v[terms[a]] > v[terms[b]]
+		}
+		return terms[a] < terms[b]
+	})
+	out := make(vector, n)
+	for _, term := range terms[0:n] {
+		out[term] = v[term]
+	}
+	return out
+}
+
+// entityWeights maps each normalized entity to a frequency-dampened weight.
+func entityWeights(nes domain.NamedEntities) map[string]float64 {
+	weights := map[string]float64{}
+	for _, ne := range nes {
+		key := strings.ToLower(strings.TrimSpace(textmanip.ToASCII(ne.Entity)))
+		if key == "" {
+			continue
+		}
+		weights[key] += 1 + math.Log(float64(ne.Frequency)+1)
+	}
+	return weights
+}
+
+func weightedJaccard(a map[string]float64, b map[string]float64) float64 {
+	var min, max float64
+	for key, wa := range a {
+		wb := b[key]
+		min += math.Min(wa, wb)
+		max += math.Max(wa, wb)
+	}
+	for key, wb := range b {
+		if _, ok := a[key]; !ok {
+			max += wb
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	return min / max
+}
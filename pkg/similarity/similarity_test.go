@@ -0,0 +1,185 @@
+package similarity
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	goose "jaytaylor.com/GoOse"
+	"jaytaylor.com/circus/domain"
+)
+
+func story(id string, text string, entities ...string) *domain.Context {
+	ctx := &domain.Context{
+		Story:   &domain.Story{ID: domain.StoryID(id)},
+		Article: &domain.Article{Article: &goose.Article{CleanedText: text}},
+	}
+	for _, entity := range entities {
+		ctx.Article.NamedEntities = append(ctx.Article.NamedEntities, domain.NamedEntity{Entity: entity, Frequency: 1})
+	}
+	return ctx
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTFIDF(t *testing.T) {
+	idx := NewIndex([]*domain.Context{
+		story("a", "The golang compiler and the golang linker, by Go"),
+		story("b", "A golang runtime"),
+		story("c", "Python runtime internals"),
+		{Story: &domain.Story{ID: "no-article"}},
+	})
+
+	v := idx.vectors[0]
+	for _, term := range []string{"the", "and", "by", "go"} {
+		if _, ok := v[term]; ok {
+			t.Errorf("expected stop words and short words to be dropped, found %q in %v", term, v)
+		}
+	}
+	// Weights are (1 + ln tf) * ln(1 + n/df) before normalizing.
+	var (
+		golang   = (1 + math.Log(2)) * math.Log(1+4.0/2)
+		compiler = math.Log(1 + 4.0/1)
+	)
+	if !almostEqual(v["golang"]/v["compiler"], golang/compiler) {
+		t.Errorf("expected golang:compiler weights in the ratio %v, got %v", golang/compiler, v["golang"]/v["compiler"])
+	}
+	if v["compiler"] != v["linker"] {
+		t.Errorf("expected equally frequent terms to weigh the same, got %v and %v", v["compiler"], v["linker"])
+	}
+	if idx.vectors[2]["internals"] <= idx.vectors[2]["runtime"] {
+		t.Errorf("expected rarer terms to weigh more, got %v and %v", idx.vectors[2]["internals"], idx.vectors[2]["runtime"])
+	}
+
+	for i, v := range idx.vectors[0:3] {
+		var sum float64
+		for _, w := range v {
+			sum += w * w
+		}
+		if !almostEqual(sum, 1) {
+			t.Errorf("[%v]: expected a unit vector, got a squared norm of %v", i, sum)
+		}
+		if !almostEqual(v.cosine(v), 1) {
+			t.Errorf("[%v]: expected a cosine of 1 with itself, got %v", i, v.cosine(v))
+		}
+	}
+	if len(idx.vectors[3]) != 0 {
+		t.Errorf("expected an empty vector for a story without an article, got %v", idx.vectors[3])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	v := vector{"a": 1, "b": 3, "c": 2, "d": 2, "e": 0.5}
+	if expected := (vector{"b": 3, "c": 2, "d": 2}); !reflect.DeepEqual(truncate(v, 3), expected) {
+		t.Errorf("expected %v, got %v", expected, truncate(v, 3))
+	}
+	// Ties are broken by term.
+	if expected := (vector{"b": 3, "c": 2}); !reflect.DeepEqual(truncate(v, 2), expected) {
+		t.Errorf("expected %v, got %v", expected, truncate(v, 2))
+	}
+	if actual := truncate(v, 10); !reflect.DeepEqual(actual, v) {
+		t.Errorf("expected short vectors to be left alone, got %v", actual)
+	}
+}
+
+func TestEntityWeights(t *testing.T) {
+	weights := entityWeights(domain.NamedEntities{
+		{Entity: "Zürich", Frequency: 1},
+		{Entity: " zurich ", Frequency: 2},
+		{Entity: "Go", Frequency: 0},
+		{Entity: "  ", Frequency: 5},
+	})
+	expected := map[string]float64{
+		"zurich": 1 + math.Log(2) + 1 + math.Log(3),
+		"go":     1,
+	}
+	if len(weights) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, weights)
+	}
+	for key, weight := range expected {
+		if !almostEqual(weights[key], weight) {
+			t.Errorf("%v: expected weight %v, got %v", key, weight, weights[key])
+		}
+	}
+}
+
+func TestWeightedJaccard(t *testing.T) {
+	testCases := []struct {
+		a        map[string]float64
+		b        map[string]float64
+		expected float64
+	}{
+		{nil, nil, 0},
+		{map[string]float64{"x": 1}, nil, 0},
+		{map[string]float64{"x": 1}, map[string]float64{"y": 1}, 0},
+		{map[string]float64{"x": 1, "y": 2}, map[string]float64{"x": 1, "y": 2}, 1},
+		{map[string]float64{"x": 1, "y": 2}, map[string]float64{"x": 2, "z": 1}, 1.0 / 5},
+		{map[string]float64{"x": 3}, map[string]float64{"x": 1}, 1.0 / 3},
+	}
+	for _, testCase := range testCases {
+		if actual := weightedJaccard(testCase.a, testCase.b); !almostEqual(actual, testCase.expected) {
+			t.Errorf("weightedJaccard(%v, %v): expected %v, got %v", testCase.a, testCase.b, testCase.expected, actual)
+		}
+		if actual := weightedJaccard(testCase.b, testCase.a); !almostEqual(actual, testCase.expected) {
+			t.Errorf("weightedJaccard(%v, %v): expected %v, got %v", testCase.b, testCase.a, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRelated(t *testing.T) {
+	contexts := []*domain.Context{
+		story("query", "golang compiler optimizations inlining escape analysis", "Google"),
+		story("close", "golang compiler optimizations inlining escape analysis benchmarks"),
+		story("partial", "golang compiler optimizations inlining notes"),
+		story("entity", "gardening tomatoes", "Google"),
+		story("unrelated", "gardening cucumbers"),
+		story("far", "golang history trivia"),
+	}
+	idx := NewIndex(contexts)
+
+	ids := func(matches []Match) []string {
+		var ids []string
+		for i, match := range matches {
+			ids = append(ids, match.Context.ID.String())
+			if match.Score <= 0 || match.Score > 1+1e-9 {
+				t.Errorf("%v: score %v out of range", match.Context.ID, match.Score)
+			}
+			if i > 0 && match.Score > matches[i-1].Score {
+				t.Errorf("%v: expected scores in descending order, got %v after %v", match.Context.ID, match.Score, matches[i-1].Score)
+			}
+		}
+		return ids
+	}
+
+	testCases := []struct {
+		k        int
+		expected []string
+	}{
+		{1, []string{"close"}},
+		{3, []string{"close", "partial", "entity"}},
+		{10, []string{"close", "partial", "entity", "far"}},
+		{0, nil},
+		{-1, nil},
+	}
+	for _, testCase := range testCases {
+		if actual := ids(idx.Related(contexts[0], testCase.k)); !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("k=%v: expected %v, got %v", testCase.k, testCase.expected, actual)
+		}
+	}
+
+	// Shared entities alone contribute the remaining share of the score.
+	for _, match := range idx.Related(contexts[0], 10) {
+		if match.Context.ID == "entity" && !almostEqual(match.Score, 1-textWeight) {
+			t.Errorf("expected an entity-only match to score %v, got %v", 1-textWeight, match.Score)
+		}
+	}
+
+	if actual := ids(idx.Related(contexts[4], 10)); !reflect.DeepEqual(actual, []string{"entity"}) {
+		t.Errorf("expected only the story sharing a term, got %v", actual)
+	}
+	if matches := idx.Related(story("outsider", "golang compiler"), 10); matches != nil {
+		t.Errorf("expected no matches for a story outside the index, got %v", ids(matches))
+	}
+}
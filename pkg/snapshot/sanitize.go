@@ -0,0 +1,135 @@
+package snapshot
+
+// Allowlists of the markup kept in snapshots.  Snapshots are published on
+// the site's own origin, so anything which could run script or navigate the
+// page must not survive.
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ContentSecurityPolicy is embedded in every snapshot as a second line of
+// defense: no scripts, frames, forms or plugins, and only inline styles and
+// data: or remote images.
+const ContentSecurityPolicy = "default-src 'none'; img-src data: http: https:; style-src 'unsafe-inline' data:; font-src data:; form-action 'none'; base-uri 'none'"
+
+// allowedElements are kept, subject to attribute filtering.
+var allowedElements = map[atom.Atom]struct{}{
+	atom.Html: {}, atom.Head: {}, atom.Body: {}, atom.Title: {}, atom.Meta: {}, atom.Link: {}, atom.Style: {},
+	atom.A: {}, atom.Abbr: {}, atom.Address: {}, atom.Area: {}, atom.Article: {}, atom.Aside: {},
+	atom.B: {}, atom.Bdi: {}, atom.Bdo: {}, atom.Big: {}, atom.Blockquote: {}, atom.Br: {},
+	atom.Caption: {}, atom.Center: {}, atom.Cite: {}, atom.Code: {}, atom.Col: {}, atom.Colgroup: {},
+	atom.Data: {}, atom.Dd: {}, atom.Del: {}, atom.Details: {}, atom.Dfn: {}, atom.Div: {}, atom.Dl: {}, atom.Dt: {},
+	atom.Em: {}, atom.Figcaption: {}, atom.Figure: {}, atom.Font: {}, atom.Footer: {},
+	atom.H1: {}, atom.H2: {}, atom.H3: {}, atom.H4: {}, atom.H5: {}, atom.H6: {}, atom.Header: {}, atom.Hgroup: {}, atom.Hr: {},
+	atom.I: {}, atom.Img: {}, atom.Ins: {}, atom.Kbd: {}, atom.Li: {}, atom.Main: {}, atom.Map: {}, atom.Mark: {}, atom.Nav: {},
+	atom.Ol: {}, atom.P: {}, atom.Picture: {}, atom.Pre: {}, atom.Q: {}, atom.Rp: {}, atom.Rt: {}, atom.Ruby: {},
+	atom.S: {}, atom.Samp: {}, atom.Section: {}, atom.Small: {}, atom.Source: {}, atom.Span: {}, atom.Strike: {}, atom.Strong: {},
+	atom.Sub: {}, atom.Summary: {}, atom.Sup: {}, atom.Table: {}, atom.Tbody: {}, atom.Td: {}, atom.Tfoot: {}, atom.Th: {},
+	atom.Thead: {}, atom.Time: {}, atom.Tr: {}, atom.Tt: {}, atom.U: {}, atom.Ul: {}, atom.Var: {}, atom.Wbr: {},
+}
+
+// droppedElements are removed along with their content.  Any other element
+// is unwrapped, keeping its (filtered) children.
+var droppedElements = map[atom.Atom]struct{}{
+	atom.Script: {}, atom.Noscript: {}, atom.Template: {}, atom.Iframe: {}, atom.Frame: {}, atom.Frameset: {},
+	atom.Object: {}, atom.Embed: {}, atom.Applet: {}, atom.Param: {}, atom.Base: {},
+	atom.Form: {}, atom.Input: {}, atom.Button: {}, atom.Select: {}, atom.Option: {}, atom.Optgroup: {},
+	atom.Textarea: {}, atom.Datalist: {}, atom.Output: {}, atom.Keygen: {},
+	atom.Svg: {}, atom.Math: {}, atom.Canvas: {}, atom.Audio: {}, atom.Video: {}, atom.Track: {}, atom.Dialog: {},
+	atom.Xmp: {}, atom.Plaintext: {}, atom.Noembed: {}, atom.Noframes: {},
+}
+
+// allowedAttrs are kept on every allowed element.  URL attributes are
+// checked separately.
+var allowedAttrs = map[string]struct{}{
+	"abbr": {}, "align": {}, "alt": {}, "bgcolor": {}, "border": {}, "cellpadding": {}, "cellspacing": {},
+	"charset": {}, "class": {}, "color": {}, "colspan": {}, "content": {}, "datetime": {}, "dir": {},
+	"face": {}, "headers": {}, "height": {}, "hreflang": {}, "id": {}, "lang": {}, "media": {}, "name": {},
+	"nowrap": {}, "open": {}, "rel": {}, "reversed": {}, "rowspan": {}, "scope": {}, "size": {}, "sizes": {},
+	"span": {}, "start": {}, "style": {}, "summary": {}, "title": {}, "type": {}, "valign": {}, "value": {},
+	"width": {},
+}
+
+// urlAttrs are the URL-valued attributes kept, with the schemes each allows.
+var urlAttrs = map[string]func(string) bool{
+	"href": isLinkURL,
+	"src":  isImageURL,
+	"cite": isRemoteURL,
+}
+
+// filterAttrs drops every attribute of n which is not allowed, and URL
+// attributes whose scheme is not allowed.
+func filterAttrs(n *html.Node) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		key := strings.ToLower(a.Key)
+		if allowed, ok := urlAttrs[key]; ok {
+			if !allowed(a.Val) {
+				continue
+			}
+		} else if _, ok := allowedAttrs[key]; !ok {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+// allowedMeta reports whether a <meta> element is inert: a charset or named
+// metadata, but no http-equiv (refresh, set-cookie, etc.).
+func allowedMeta(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if strings.ToLower(a.Key) == "http-equiv" {
+			return false
+		}
+	}
+	return attr(n, "charset") != "" || attr(n, "name") != ""
+}
+
+// unwrap replaces n with its children, returning the first of them.
+func unwrap(n *html.Node) *html.Node {
+	first := n.FirstChild
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		n.Parent.InsertBefore(c, n)
+		c = next
+	}
+	n.Parent.RemoveChild(n)
+	return first
+}
+
+// safeURL resolves ref against base and returns it if it is an http or https
+// URL, or "" otherwise.  References which fail to parse, e.g. because of
+// embedded control characters, are dropped rather than kept verbatim.
+func safeURL(ref string, base *url.URL) string {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func isRemoteURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func isLinkURL(s string) bool {
+	return isRemoteURL(s) || strings.HasPrefix(s, "#")
+}
+
+func isImageURL(s string) bool {
+	return isRemoteURL(s) || strings.HasPrefix(strings.ToLower(s), "data:image/")
+}
+
+// cssEscaper keeps inlined stylesheet text from closing its <style> element.
+var cssEscaper = strings.NewReplacer("</", `<\/`)
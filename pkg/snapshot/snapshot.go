@@ -0,0 +1,360 @@
+package snapshot
+
+// Self-contained single-file HTML snapshots of fetched pages, with
+// stylesheets and images inlined so the page renders without network access.
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultMaxResourceBytes caps the size of each inlined stylesheet or image.
+const DefaultMaxResourceBytes = 10 * 1024 * 1024
+
+var (
+	cssURLExpr    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)`)
+	cssImportExpr = regexp.MustCompile(`@import\s+(?:url\()?\s*["']?([^"')\s;]+)["']?\s*\)?[^;]*;`)
+)
+
+// Snapshotter inlines the external resources of HTML documents.
+type Snapshotter struct {
+	Client           *http.Client // Defaults to http.DefaultClient.
+	MaxResourceBytes int64
+
+	cache map[string]string
+}
+
+// New returns a Snapshotter which downloads resources with client.
+func New(client *http.Client) *Snapshotter {
+	s := &Snapshotter{
+		Client:           client,
+		MaxResourceBytes: DefaultMaxResourceBytes,
+	}
+	return s
+}
+
+// Single renders content, fetched from baseURL, as a single self-contained
+// HTML document.  Only an allowlist of inert elements and attributes is
+// kept: scripts, frames, forms, SVG and meta refreshes are removed, links may
+// only point at http and https URLs, stylesheets become <style> elements and
+// images become data URIs.  Resources which cannot be fetched are left
+// pointing at their absolute remote URL.  A restrictive Content-Security-Policy
+// is embedded as well.
+func (s *Snapshotter) Single(ctx context.Context, content []byte, baseURL string) ([]byte, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base url %q: %s", baseURL, err)
+	}
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing html: %s", err)
+	}
+
+	s.cache = map[string]string{}
+
+	// A <base href> changes how relative references resolve.
+	if b := find(doc, atom.Base); b != nil {
+		if href := attr(b, "href"); href != "" {
+			if u, err := base.Parse(href); err == nil {
+				base = u
+			}
+		}
+	}
+
+	s.walk(ctx, doc, base)
+
+	// The parser always produces a <head>.  The policy must come first to
+	// cover everything after it.
+	if head := find(doc, atom.Head); head != nil {
+		csp := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "meta",
+			DataAtom: atom.Meta,
+			Attr: []html.Attribute{
+				{Key: "http-equiv", Val: "Content-Security-Policy"},
+				{Key: "content", Val: ContentSecurityPolicy},
+			},
+		}
+		head.InsertBefore(csp, head.FirstChild)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := html.Render(buf, doc); err != nil {
+		return nil, fmt.Errorf("rendering snapshot html: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Snapshotter) walk(ctx context.Context, n *html.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			// Including conditional comments, which some browsers parse.
+			n.RemoveChild(c)
+			c = next
+			continue
+		}
+		if c.Type != html.ElementNode {
+			c = next
+			continue
+		}
+
+		// Foreign (SVG and MathML) content is dropped whole.
+		if _, ok := droppedElements[c.DataAtom]; ok || c.Namespace != "" || (c.DataAtom == atom.Meta && !allowedMeta(c)) {
+			n.RemoveChild(c)
+			c = next
+			continue
+		}
+		if _, ok := allowedElements[c.DataAtom]; !ok {
+			if first := unwrap(c); first != nil {
+				next = first
+			}
+			c = next
+			continue
+		}
+
+		switch c.DataAtom {
+		case atom.Link:
+			if s.inlineStylesheet(ctx, c, base) {
+				c = next
+				continue
+			}
+			// Only icons are kept, inlined where possible.
+			if href := attr(c, "href"); !strings.Contains(strings.ToLower(attr(c, "rel")), "icon") || !isImageURL(href) {
+				n.RemoveChild(c)
+				c = next
+				continue
+			}
+
+		case atom.Style:
+			if c.FirstChild != nil && c.FirstChild.Type == html.TextNode {
+				c.FirstChild.Data = cssEscaper.Replace(s.inlineCSS(ctx, c.FirstChild.Data, base))
+			}
+
+		case atom.Img, atom.Source:
+			for _, name := range []string{"src", "data-src"} {
+				if src := attr(c, name); src != "" {
+					setAttr(c, "src", s.dataURI(ctx, src, base))
+					break
+				}
+			}
+
+		case atom.A, atom.Area:
+			if href := attr(c, "href"); href != "" {
+				setAttr(c, "href", resolve(href, base))
+			}
+		}
+
+		filterAttrs(c)
+		if style := attr(c, "style"); style != "" {
+			setAttr(c, "style", s.inlineCSS(ctx, style, base))
+		}
+		s.walk(ctx, c, base)
+		c = next
+	}
+}
+
+// inlineStylesheet replaces a <link rel="stylesheet"> with an equivalent
+// <style> element.  Returns true when the node was replaced.
+func (s *Snapshotter) inlineStylesheet(ctx context.Context, link *html.Node, base *url.URL) bool {
+	rel := strings.ToLower(attr(link, "rel"))
+	href := attr(link, "href")
+	if href == "" || !strings.Contains(rel, "stylesheet") || strings.Contains(rel, "alternate") {
+		if strings.Contains(rel, "icon") && href != "" {
+			setAttr(link, "href", s.dataURI(ctx, href, base))
+		}
+		return false
+	}
+	// Stylesheets which cannot be inlined are dropped, as the embedded
+	// policy would block them anyway.
+
+	cssURL, err := base.Parse(href)
+	if err != nil {
+		return false
+	}
+	data, _, err := s.fetch(ctx, cssURL.String())
+	if err != nil {
+		log.WithField("url", cssURL.String()).Debugf("Inlining stylesheet: %s", err)
+		link.Parent.RemoveChild(link)
+		return true
+	}
+
+	style := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "style",
+		DataAtom: atom.Style,
+	}
+	if media := attr(link, "media"); media != "" {
+		setAttr(style, "media", media)
+	}
+	style.AppendChild(&html.Node{
+		Type: html.TextNode,
+		Data: cssEscaper.Replace(s.inlineCSS(ctx, string(data), cssURL)),
+	})
+	link.Parent.InsertBefore(style, link)
+	link.Parent.RemoveChild(link)
+	return true
+}
+
+// inlineCSS replaces url() references in css with data URIs and expands
+// @import rules one level deep.
+func (s *Snapshotter) inlineCSS(ctx context.Context, css string, base *url.URL) string {
+	css = cssImportExpr.ReplaceAllStringFunc(css, func(rule string) string {
+		m := cssImportExpr.FindStringSubmatch(rule)
+		u, err := base.Parse(m[1])
+		if err != nil {
+			return ""
+		}
+		data, _, err := s.fetch(ctx, u.String())
+		if err != nil {
+			return ""
+		}
+		// Nested imports are not followed.
+		return cssURLExpr.ReplaceAllStringFunc(cssImportExpr.ReplaceAllString(string(data), ""), func(ref string) string {
+			return s.cssURL(ctx, ref, u)
+		})
+	})
+	return cssURLExpr.ReplaceAllStringFunc(css, func(ref string) string {
+		return s.cssURL(ctx, ref, base)
+	})
+}
+
+func (s *Snapshotter) cssURL(ctx context.Context, ref string, base *url.URL) string {
+	m := cssURLExpr.FindStringSubmatch(ref)
+	target := m[1] + m[2] + m[3]
+	if target == "" || strings.HasPrefix(target, "data:") || strings.HasPrefix(target, "#") {
+		return ref
+	}
+	uri := s.dataURI(ctx, target, base)
+	if uri == "" {
+		return `url("")`
+	}
+	return `url("` + uri + `")`
+}
+
+// dataURI fetches ref and encodes it as a data URI, falling back to the
+// absolute URL.
+func (s *Snapshotter) dataURI(ctx context.Context, ref string, base *url.URL) string {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	abs := safeURL(ref, base)
+	if abs == "" {
+		return ""
+	}
+	if uri, ok := s.cache[abs]; ok {
+		return uri
+	}
+	data, contentType, err := s.fetch(ctx, abs)
+	if err != nil {
+		log.WithField("url", abs).Debugf("Inlining resource: %s", err)
+		s.cache[abs] = abs
+		return abs
+	}
+	uri := "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	s.cache[abs] = uri
+	return uri
+}
+
+func (s *Snapshotter) fetch(ctx context.Context, u string) ([]byte, string, error) {
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil, "", fmt.Errorf("unsupported scheme in %v", u)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, "", fmt.Errorf("non-2xx response status-code=%v", resp.StatusCode)
+	}
+
+	max := s.MaxResourceBytes
+	if max <= 0 {
+		max = DefaultMaxResourceBytes
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > max {
+		return nil, "", fmt.Errorf("resource exceeds %v bytes", max)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// resolve returns ref as an absolute http or https URL, or as-is when it is a
+// fragment.  Anything else resolves to "" and is dropped by filterAttrs.
+func resolve(ref string, base *url.URL) string {
+	if strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	return safeURL(ref, base)
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, name string, value string) {
+	for i, a := range n.Attr {
+		if a.Key == name {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: name, Val: value})
+}
+
+func removeAttr(n *html.Node, name string) {
+	for i, a := range n.Attr {
+		if a.Key == name {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
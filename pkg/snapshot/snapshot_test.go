@@ -0,0 +1,141 @@
+package snapshot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSingleSanitizes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/evil.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`body { color: red } </style><script>alert(1)</script>`))
+		case "/pixel.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		html      string
+		forbidden []string
+		expected  []string
+	}{
+		{
+			name:      "script",
+			html:      `<script>alert(1)</script><p onclick="alert(1)">hi</p>`,
+			forbidden: []string{"<script", "onclick"},
+			expected:  []string{"<p>hi</p>"},
+		},
+		{
+			name:      "meta refresh",
+			html:      `<head><meta http-equiv=refresh content="0;url=javascript:alert(1)"><meta charset="utf-8"></head>`,
+			forbidden: []string{"refresh", "javascript:"},
+			expected:  []string{`<meta charset="utf-8"/>`},
+		},
+		{
+			name:      "embedded tab in scheme",
+			html:      "<a href=\"java&#x09;script:alert(1)\">x</a>",
+			forbidden: []string{"script:", "href"},
+			expected:  []string{"<a>x</a>"},
+		},
+		{
+			name:      "entity encoded scheme",
+			html:      `<a href="&#106;avascript:alert(1)">x</a>`,
+			forbidden: []string{"avascript:"},
+		},
+		{
+			name:      "form action",
+			html:      `<form action="javascript:alert(1)"><input value="x"></form>`,
+			forbidden: []string{"<form", "<input", "javascript:"},
+		},
+		{
+			name:      "button formaction",
+			html:      `<button formaction="javascript:alert(1)">go</button>`,
+			forbidden: []string{"<button", "formaction", "javascript:"},
+		},
+		{
+			name:      "svg animation",
+			html:      `<svg><a><animate attributeName=href to="javascript:alert(1)"/><text>x</text></a></svg>`,
+			forbidden: []string{"<svg", "<animate", "javascript:"},
+		},
+		{
+			name:      "data uri link",
+			html:      `<a href="data:text/html,<script>alert(1)</script>">x</a>`,
+			forbidden: []string{"data:text/html"},
+		},
+		{
+			name:      "unknown element unwrapped",
+			html:      `<custom-el data-x="1"><b>kept</b></custom-el>`,
+			forbidden: []string{"custom-el", "data-x"},
+			expected:  []string{"<b>kept</b>"},
+		},
+		{
+			name:      "stylesheet breakout",
+			html:      `<link rel="stylesheet" href="` + server.URL + `/evil.css"><p>x</p>`,
+			forbidden: []string{"</style><script"},
+			expected:  []string{"<style>body { color: red } <\\/style><script>alert(1)<\\/script></style>"},
+		},
+		{
+			name:      "css javascript url",
+			html:      `<p style="background: url(javascript:alert(1))">x</p>`,
+			forbidden: []string{"javascript:"},
+		},
+		{
+			name:     "links and images kept",
+			html:     `<a href="/about#team">about</a><img src="/pixel.png" alt="p">`,
+			expected: []string{`href="` + server.URL + `/about#team"`, `src="data:image/png;base64,`},
+		},
+	}
+	for _, testCase := range testCases {
+		out, err := New(nil).Single(context.Background(), []byte(testCase.html), server.URL+"/page")
+		if err != nil {
+			t.Fatalf("%v: %s", testCase.name, err)
+		}
+		snapshot := string(out)
+		if !strings.Contains(snapshot, `<meta http-equiv="Content-Security-Policy"`) {
+			t.Errorf("%v: expected embedded content security policy in %v", testCase.name, snapshot)
+		}
+		for _, forbidden := range testCase.forbidden {
+			if strings.Contains(strings.ToLower(snapshot), strings.ToLower(forbidden)) {
+				t.Errorf("%v: %q survived in %v", testCase.name, forbidden, snapshot)
+			}
+		}
+		for _, expected := range testCase.expected {
+			if !strings.Contains(snapshot, expected) {
+				t.Errorf("%v: expected %q in %v", testCase.name, expected, snapshot)
+			}
+		}
+	}
+}
+
+func TestSingleCancelled(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out, err := New(nil).Single(ctx, []byte(`<img src="/pixel.png">`), server.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests once cancelled, got %v", requests)
+	}
+	if expected := `src="` + server.URL + `/pixel.png"`; !strings.Contains(string(out), expected) {
+		t.Errorf("expected the image to be left as a remote reference %q, got %v", expected, string(out))
+	}
+}
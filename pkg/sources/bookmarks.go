@@ -0,0 +1,295 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"jaytaylor.com/circus/domain"
+)
+
+func init() {
+	Register("bookmarks-html", func(location string) (Source, error) {
+		return &BookmarksHTML{Location: location}, nil
+	})
+	Register("firefox", func(location string) (Source, error) {
+		return &FirefoxBookmarks{Location: location}, nil
+	})
+	Register("chrome", func(location string) (Source, error) {
+		return &ChromeBookmarks{Location: location}, nil
+	})
+}
+
+// BookmarksHTML reads the Netscape bookmark file format exported by every
+// major browser and most bookmarking services.  Folder names are recorded as
+// tags alongside any TAGS attribute.
+type BookmarksHTML struct {
+	Location string
+}
+
+// Name implements Source.
+func (s *BookmarksHTML) Name() string {
+	return domain.SourceBookmarks
+}
+
+// Stories implements Source.
+func (s *BookmarksHTML) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	return anchorStories(data, domain.SourceBookmarks, "add_date", true), nil
+}
+
+// anchorStories extracts a story from every <a href> in data.  timeAttr names
+// the attribute holding the Unix time the link was saved.  When folders is
+// true, the text of <h3> headings preceding nested <dl> lists (i.e. bookmark
+// folders) is added to each story's tags.
+func anchorStories(data []byte, source string, timeAttr string, folders bool) []*domain.Story {
+	var (
+		stories = []*domain.Story{}
+		z       = html.NewTokenizer(bytes.NewReader(data))
+		path    []string
+		heading string
+		current *domain.Story
+		inH3    bool
+	)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return stories
+
+		case html.StartTagToken, html.EndTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			if tt == html.EndTagToken {
+				switch a {
+				case atom.A:
+					if current != nil {
+						current.Title = strings.TrimSpace(current.Title)
+						if current.Title == "" {
+							current.Title = current.URL
+						}
+						stories = append(stories, current)
+						current = nil
+					}
+				case atom.H3:
+					inH3 = false
+				case atom.Dl:
+					if len(path) > 0 {
+						path = path[0 : len(path)-1]
+					}
+				}
+				continue
+			}
+
+			switch a {
+			case atom.H3:
+				inH3 = true
+				heading = ""
+			case atom.Dl:
+				path = append(path, strings.TrimSpace(heading))
+				heading = ""
+			case atom.A:
+				attrs := map[string]string{}
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					attrs[string(k)] = string(v)
+				}
+				href := strings.TrimSpace(attrs["href"])
+				if !bookmarkable(href) {
+					continue
+				}
+				current = &domain.Story{
+					ID:        hashID(source, href),
+					Source:    source,
+					URL:       href,
+					Timestamp: domain.Time{Time: unixTime(attrs[timeAttr])},
+					Tags:      splitTags(attrs["tags"], ","),
+				}
+				if folders {
+					for _, folder := range path {
+						if folder != "" {
+							current.Tags = append(current.Tags, folder)
+						}
+					}
+				}
+			}
+
+		case html.TextToken:
+			if current != nil {
+				current.Title += string(z.Text())
+			} else if inH3 {
+				heading += string(z.Text())
+			}
+		}
+	}
+}
+
+// FirefoxBookmarks reads a Firefox bookmark backup (bookmarks-*.json), as
+// made by Library > Import and Backup > Backup.
+type FirefoxBookmarks struct {
+	Location string
+}
+
+type firefoxNode struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	URI       string         `json:"uri"`
+	DateAdded int64          `json:"dateAdded"` // Microseconds since the Unix epoch.
+	Tags      string         `json:"tags"`
+	Children  []*firefoxNode `json:"children"`
+}
+
+// Name implements Source.
+func (s *FirefoxBookmarks) Name() string {
+	return domain.SourceBookmarks
+}
+
+// Stories implements Source.
+func (s *FirefoxBookmarks) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var root firefoxNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("unmarshalling firefox bookmarks: %s", err)
+	}
+
+	stories := []*domain.Story{}
+	var walk func(node *firefoxNode)
+	walk = func(node *firefoxNode) {
+		if node.Type == "text/x-moz-place" && bookmarkable(node.URI) {
+			title := node.Title
+			if title == "" {
+				title = node.URI
+			}
+			stories = append(stories, &domain.Story{
+				ID:        hashID(domain.SourceBookmarks, node.URI),
+				Source:    domain.SourceBookmarks,
+				Title:     title,
+				URL:       node.URI,
+				Timestamp: domain.Time{Time: time.Unix(0, node.DateAdded*int64(time.Microsecond)).UTC()},
+				Tags:      splitTags(node.Tags, ","),
+			})
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(&root)
+	return stories, nil
+}
+
+// ChromeBookmarks reads the Bookmarks JSON file from a Chrome (or Chromium
+// derived browser) profile directory.
+type ChromeBookmarks struct {
+	Location string
+}
+
+type chromeNode struct {
+	Type      string        `json:"type"`
+	Name      string        `json:"name"`
+	URL       string        `json:"url"`
+	DateAdded string        `json:"date_added"` // Microseconds since 1601-01-01.
+	Children  []*chromeNode `json:"children"`
+}
+
+// chromeEpochOffset is the number of seconds between 1601-01-01 and the Unix
+// epoch.
+const chromeEpochOffset = 11644473600
+
+// Name implements Source.
+func (s *ChromeBookmarks) Name() string {
+	return domain.SourceBookmarks
+}
+
+// Stories implements Source.  Folder names are recorded as tags.
+func (s *ChromeBookmarks) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Roots map[string]json.RawMessage `json:"roots"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("unmarshalling chrome bookmarks: %s", err)
+	}
+
+	stories := []*domain.Story{}
+	var walk func(node *chromeNode, folders []string)
+	walk = func(node *chromeNode, folders []string) {
+		if node.Type == "url" && bookmarkable(node.URL) {
+			title := node.Name
+			if title == "" {
+				title = node.URL
+			}
+			story := &domain.Story{
+				ID:     hashID(domain.SourceBookmarks, node.URL),
+				Source: domain.SourceBookmarks,
+				Title:  title,
+				URL:    node.URL,
+				Tags:   append([]string{}, folders...),
+			}
+			if micros, err := strconv.ParseInt(node.DateAdded, 10, 64); err == nil && micros > 0 {
+				story.Timestamp.Time = time.Unix(micros/1e6-chromeEpochOffset, (micros%1e6)*1e3).UTC()
+			}
+			stories = append(stories, story)
+		}
+		if node.Type == "folder" && node.Name != "" {
+			folders = append(folders, node.Name)
+		}
+		for _, child := range node.Children {
+			walk(child, folders)
+		}
+	}
+	for _, name := range []string{"bookmark_bar", "other", "synced"} {
+		raw, ok := file.Roots[name]
+		if !ok {
+			continue
+		}
+		var root chromeNode
+		if err := json.Unmarshal(raw, &root); err != nil {
+			return nil, fmt.Errorf("unmarshalling chrome bookmarks %v: %s", name, err)
+		}
+		// The root folders themselves are not meaningful tags.
+		for _, child := range root.Children {
+			walk(child, nil)
+		}
+	}
+	return stories, nil
+}
+
+// bookmarkable reports whether u is a web page worth hydrating, as opposed to
+// e.g. a bookmarklet or browser-internal page.
+func bookmarkable(u string) bool {
+	lower := strings.ToLower(u)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func unixTime(s string) time.Time {
+	secs, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).UTC()
+}
+
+func splitTags(s string, sep string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
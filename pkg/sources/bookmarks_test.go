@@ -0,0 +1,35 @@
+package sources
+
+import (
+	"testing"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func TestBookmarksHTML(t *testing.T) {
+	// Folders nest, and are popped again when their list closes.
+	checkStories(t, "bookmarks", load(t, "bookmarks-html", "bookmarks.html"), []*domain.Story{
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/top"), Source: domain.SourceBookmarks, Title: "Top level", URL: "https://example.com/top", Timestamp: unix(1500000000, 0), Tags: []string{"toplevel"}},
+		{ID: hashID(domain.SourceBookmarks, "https://go.dev/"), Source: domain.SourceBookmarks, Title: "The Go Programming Language", URL: "https://go.dev/", Timestamp: unix(1500000001, 0), Tags: []string{"go", "lang", "Programming"}},
+		{ID: hashID(domain.SourceBookmarks, "https://www.postgresql.org/"), Source: domain.SourceBookmarks, Title: "PostgreSQL", URL: "https://www.postgresql.org/", Timestamp: unix(1500000002, 0), Tags: []string{"Programming", "Databases"}},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/untitled"), Source: domain.SourceBookmarks, Title: "https://example.com/untitled", URL: "https://example.com/untitled", Tags: []string{"Programming"}},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/after"), Source: domain.SourceBookmarks, Title: "After folders", URL: "https://example.com/after", Timestamp: unix(1500000003, 0)},
+	})
+}
+
+func TestFirefoxBookmarks(t *testing.T) {
+	checkStories(t, "firefox", load(t, "firefox", "firefox.json"), []*domain.Story{
+		{ID: hashID(domain.SourceBookmarks, "https://go.dev/"), Source: domain.SourceBookmarks, Title: "Go", URL: "https://go.dev/", Timestamp: unix(1500000000, 123456000), Tags: []string{"go", "lang"}},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/untitled"), Source: domain.SourceBookmarks, Title: "https://example.com/untitled", URL: "https://example.com/untitled", Timestamp: unix(1500000001, 0)},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/nested"), Source: domain.SourceBookmarks, Title: "Nested", URL: "https://example.com/nested", Timestamp: unix(1500000002, 0)},
+	})
+}
+
+func TestChromeBookmarks(t *testing.T) {
+	// Root folders such as "Bookmarks bar" are not tags, nested folders are.
+	checkStories(t, "chrome", load(t, "chrome", "chrome.json"), []*domain.Story{
+		{ID: hashID(domain.SourceBookmarks, "https://go.dev/"), Source: domain.SourceBookmarks, Title: "Go", URL: "https://go.dev/", Timestamp: at(2020, 9, 12, 16, 0, 0), Tags: []string{}},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/deep"), Source: domain.SourceBookmarks, Title: "https://example.com/deep", URL: "https://example.com/deep", Timestamp: unix(1599926401, 500000000), Tags: []string{"Work", "Deep"}},
+		{ID: hashID(domain.SourceBookmarks, "https://example.com/other"), Source: domain.SourceBookmarks, Title: "Other", URL: "https://example.com/other", Tags: []string{}},
+	})
+}
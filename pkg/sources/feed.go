@@ -0,0 +1,173 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func init() {
+	Register(domain.SourceFeed, func(location string) (Source, error) {
+		return &Feed{Location: location}, nil
+	})
+}
+
+// Feed reads an RSS 2.0 or Atom feed.
+type Feed struct {
+	Location string
+}
+
+type rssFeed struct {
+	Items []struct {
+		Title    string   `xml:"title"`
+		Link     string   `xml:"link"`
+		GUID     string   `xml:"guid"`
+		PubDate  string   `xml:"pubDate"`
+		Comments string   `xml:"comments"`
+		Author   string   `xml:"author"`
+		Creator  string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Category []string `xml:"category"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Author    struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+	} `xml:"entry"`
+}
+
+// Name implements Source.
+func (s *Feed) Name() string {
+	return domain.SourceFeed
+}
+
+// Stories implements Source.
+func (s *Feed) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stories := []*domain.Story{}
+	switch root {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("unmarshalling rss feed: %s", err)
+		}
+		for _, item := range feed.Items {
+			key := item.GUID
+			if key == "" {
+				key = item.Link
+			}
+			author := item.Creator
+			if author == "" {
+				author = item.Author
+			}
+			stories = append(stories, &domain.Story{
+				ID:        hashID(domain.SourceFeed, key),
+				Source:    domain.SourceFeed,
+				Title:     strings.TrimSpace(item.Title),
+				URL:       strings.TrimSpace(item.Link),
+				Submitter: strings.TrimSpace(author),
+				Timestamp: domain.Time{Time: parseFeedTime(item.PubDate)},
+				Tags:      item.Category,
+				Permalink: strings.TrimSpace(item.Comments),
+			})
+		}
+
+	case "feed":
+		var feed atomFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("unmarshalling atom feed: %s", err)
+		}
+		for _, entry := range feed.Entries {
+			story := &domain.Story{
+				Source:    domain.SourceFeed,
+				Title:     strings.TrimSpace(entry.Title),
+				Submitter: strings.TrimSpace(entry.Author.Name),
+			}
+			for _, link := range entry.Links {
+				switch link.Rel {
+				case "", "alternate":
+					if story.URL == "" {
+						story.URL = link.Href
+					}
+				case "replies":
+					story.Permalink = link.Href
+				}
+			}
+			for _, category := range entry.Categories {
+				story.Tags = append(story.Tags, category.Term)
+			}
+			if story.Timestamp.Time = parseFeedTime(entry.Published); story.Timestamp.IsZero() {
+				story.Timestamp.Time = parseFeedTime(entry.Updated)
+			}
+			key := entry.ID
+			if key == "" {
+				key = story.URL
+			}
+			story.ID = hashID(domain.SourceFeed, key)
+			stories = append(stories, story)
+		}
+
+	default:
+		return nil, fmt.Errorf("unrecognized feed format with root element <%v>", root)
+	}
+	return stories, nil
+}
+
+func rootElement(data []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("parsing feed: %s", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+var feedTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,111 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+	"jaytaylor.com/circus/pkg/hnapi"
+)
+
+func init() {
+	Register(domain.SourceHN, func(location string) (Source, error) {
+		return &HN{Location: location}, nil
+	})
+	Register("hn-api", func(location string) (Source, error) {
+		return NewHNAPI(location), nil
+	})
+}
+
+// HN reads stories exported by hn-utils (hn-favorites / hn-upvotes).
+type HN struct {
+	Location string
+}
+
+// Name implements Source.
+func (s *HN) Name() string {
+	return domain.SourceHN
+}
+
+// Stories implements Source.
+func (s *HN) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var stories []*domain.Story
+	if err := json.Unmarshal(data, &stories); err != nil {
+		return nil, fmt.Errorf("unmarshalling hn stories: %s", err)
+	}
+	for _, story := range stories {
+		story.Source = domain.SourceHN
+	}
+	return stories, nil
+}
+
+// HNAPI reads one of the HN API's live story lists, e.g. "topstories".
+type HNAPI struct {
+	Client *hnapi.Client
+	List   string
+	Limit  int // Zero for no limit.
+}
+
+// NewHNAPI returns a source for the named list.  The list may be suffixed
+// with ":<n>" to only take the first n stories.
+func NewHNAPI(list string) *HNAPI {
+	s := &HNAPI{
+		Client: hnapi.New(),
+		List:   list,
+	}
+	if idx := strings.LastIndex(list, ":"); idx > 0 {
+		if n, err := strconv.Atoi(list[idx+1:]); err == nil {
+			s.List, s.Limit = list[0:idx], n
+		}
+	}
+	if s.List == "" {
+		s.List = "topstories"
+	}
+	return s
+}
+
+// Name implements Source.
+func (s *HNAPI) Name() string {
+	return domain.SourceHN
+}
+
+// Stories implements Source.
+func (s *HNAPI) Stories() ([]*domain.Story, error) {
+	ids, err := s.Client.List(context.Background(), s.List)
+	if err != nil {
+		return nil, err
+	}
+	if s.Limit > 0 && len(ids) > s.Limit {
+		ids = ids[0:s.Limit]
+	}
+
+	stories := []*domain.Story{}
+	for _, id := range ids {
+		item, err := s.Client.Item(context.Background(), id)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || item.Deleted || item.Dead || item.Type != "story" {
+			continue
+		}
+		stories = append(stories, &domain.Story{
+			ID:        domain.StoryID(strconv.FormatInt(item.ID, 10)),
+			Source:    domain.SourceHN,
+			Title:     item.Title,
+			URL:       item.URL,
+			Submitter: item.By,
+			Points:    item.Score,
+			Comments:  item.Descendants,
+			Timestamp: domain.Time{Time: time.Unix(item.Time, 0).UTC()},
+		})
+	}
+	return stories, nil
+}
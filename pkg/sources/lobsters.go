@@ -0,0 +1,96 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// LobstersBaseURL is prepended to Lobsters locations which are not already
+// URLs, e.g. "hottest", "newest" or "t/go".
+var LobstersBaseURL = "https://lobste.rs"
+
+func init() {
+	Register(domain.SourceLobsters, func(location string) (Source, error) {
+		return &Lobsters{Location: location}, nil
+	})
+}
+
+// Lobsters reads a Lobsters story listing in its JSON format, from a file,
+// URL or a path on LobstersBaseURL.
+type Lobsters struct {
+	Location string
+}
+
+type lobstersStory struct {
+	ShortID      string          `json:"short_id"`
+	Title        string          `json:"title"`
+	URL          string          `json:"url"`
+	Score        int             `json:"score"`
+	CommentCount int             `json:"comment_count"`
+	CreatedAt    time.Time       `json:"created_at"`
+	CommentsURL  string          `json:"comments_url"`
+	Submitter    json.RawMessage `json:"submitter_user"` // A username, or a user object in older versions.
+	Tags         []string        `json:"tags"`
+}
+
+// Name implements Source.
+func (s *Lobsters) Name() string {
+	return domain.SourceLobsters
+}
+
+// Stories implements Source.
+func (s *Lobsters) Stories() ([]*domain.Story, error) {
+	location := s.Location
+	if location != "-" && !strings.Contains(location, "://") {
+		if _, err := os.Stat(location); err != nil {
+			location = fmt.Sprintf("%v/%v.json", strings.TrimRight(LobstersBaseURL, "/"), strings.Trim(location, "/"))
+		}
+	}
+	data, err := open(location)
+	if err != nil {
+		return nil, err
+	}
+	var listing []lobstersStory
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("unmarshalling lobsters stories: %s", err)
+	}
+
+	stories := make([]*domain.Story, 0, len(listing))
+	for _, ls := range listing {
+		story := &domain.Story{
+			ID:        prefixedID(domain.SourceLobsters, ls.ShortID),
+			Source:    domain.SourceLobsters,
+			Title:     ls.Title,
+			URL:       ls.URL,
+			Submitter: lobstersUsername(ls.Submitter),
+			Points:    ls.Score,
+			Comments:  ls.CommentCount,
+			Timestamp: domain.Time{Time: ls.CreatedAt.UTC()},
+			Tags:      ls.Tags,
+			Permalink: ls.CommentsURL,
+		}
+		if story.URL == "" {
+			// Text posts link to their own discussion.
+			story.URL = ls.CommentsURL
+		}
+		stories = append(stories, story)
+	}
+	return stories, nil
+}
+
+func lobstersUsername(raw json.RawMessage) string {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return name
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	json.Unmarshal(raw, &user)
+	return user.Username
+}
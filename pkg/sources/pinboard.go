@@ -0,0 +1,67 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func init() {
+	Register(domain.SourcePinboard, func(location string) (Source, error) {
+		return &Pinboard{Location: location}, nil
+	})
+}
+
+// Pinboard reads a Pinboard JSON export (https://pinboard.in/export/format:json/),
+// which is also the format returned by the v1 posts/all API.
+type Pinboard struct {
+	Location string
+	User     string // Bookmark owner, used for submitter links.
+}
+
+type pinboardPost struct {
+	Href        string    `json:"href"`
+	Description string    `json:"description"` // The bookmark title.
+	Hash        string    `json:"hash"`
+	Time        time.Time `json:"time"`
+	Tags        string    `json:"tags"` // Space separated.
+	Shared      string    `json:"shared"`
+}
+
+// Name implements Source.
+func (s *Pinboard) Name() string {
+	return domain.SourcePinboard
+}
+
+// Stories implements Source.
+func (s *Pinboard) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	var posts []pinboardPost
+	if err := json.Unmarshal(data, &posts); err != nil {
+		return nil, fmt.Errorf("unmarshalling pinboard export: %s", err)
+	}
+
+	stories := make([]*domain.Story, 0, len(posts))
+	for _, post := range posts {
+		id := hashID(domain.SourcePinboard, post.Href)
+		if post.Hash != "" {
+			id = prefixedID(domain.SourcePinboard, post.Hash)
+		}
+		stories = append(stories, &domain.Story{
+			ID:        id,
+			Source:    domain.SourcePinboard,
+			Title:     post.Description,
+			URL:       post.Href,
+			Submitter: s.User,
+			Timestamp: domain.Time{Time: post.Time.UTC()},
+			Tags:      strings.Fields(post.Tags),
+		})
+	}
+	return stories, nil
+}
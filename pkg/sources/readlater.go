@@ -0,0 +1,144 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func init() {
+	Register(domain.SourcePocket, func(location string) (Source, error) {
+		return &Pocket{Location: location}, nil
+	})
+	Register(domain.SourceInstapaper, func(location string) (Source, error) {
+		return &Instapaper{Location: location}, nil
+	})
+}
+
+// Pocket reads a Pocket export, either the HTML file (ril_export.html) or the
+// CSV file with title, url, time_added, tags and status columns.
+type Pocket struct {
+	Location string
+}
+
+// Name implements Source.
+func (s *Pocket) Name() string {
+	return domain.SourcePocket
+}
+
+// Stories implements Source.
+func (s *Pocket) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		return anchorStories(data, domain.SourcePocket, "time_added", false), nil
+	}
+
+	rows, err := csvRecords(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading pocket csv: %s", err)
+	}
+	stories := []*domain.Story{}
+	for _, row := range rows {
+		if !bookmarkable(row["url"]) {
+			continue
+		}
+		stories = append(stories, &domain.Story{
+			ID:        hashID(domain.SourcePocket, row["url"]),
+			Source:    domain.SourcePocket,
+			Title:     titleOrURL(row["title"], row["url"]),
+			URL:       row["url"],
+			Timestamp: domain.Time{Time: unixTime(row["time_added"])},
+			Tags:      splitTags(row["tags"], "|"),
+		})
+	}
+	return stories, nil
+}
+
+// Instapaper reads an Instapaper CSV export, with URL, Title, Selection,
+// Folder and Timestamp columns.  The folder is recorded as a tag.
+type Instapaper struct {
+	Location string
+}
+
+// Name implements Source.
+func (s *Instapaper) Name() string {
+	return domain.SourceInstapaper
+}
+
+// Stories implements Source.
+func (s *Instapaper) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := csvRecords(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading instapaper csv: %s", err)
+	}
+	stories := []*domain.Story{}
+	for _, row := range rows {
+		if !bookmarkable(row["url"]) {
+			continue
+		}
+		story := &domain.Story{
+			ID:        hashID(domain.SourceInstapaper, row["url"]),
+			Source:    domain.SourceInstapaper,
+			Title:     titleOrURL(row["title"], row["url"]),
+			URL:       row["url"],
+			Timestamp: domain.Time{Time: unixTime(row["timestamp"])},
+		}
+		if folder := strings.TrimSpace(row["folder"]); folder != "" && folder != "Unread" {
+			story.Tags = []string{folder}
+		}
+		stories = append(stories, story)
+	}
+	return stories, nil
+}
+
+// csvRecords parses CSV with a header row into maps keyed by lower-cased
+// column name.
+func csvRecords(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func titleOrURL(title string, u string) string {
+	if title == "" {
+		return u
+	}
+	return title
+}
@@ -0,0 +1,56 @@
+package sources
+
+import (
+	"reflect"
+	"testing"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func TestPocket(t *testing.T) {
+	checkStories(t, "html", load(t, "pocket", "pocket.html"), []*domain.Story{
+		{ID: hashID(domain.SourcePocket, "https://example.com/unread"), Source: domain.SourcePocket, Title: "Unread article", URL: "https://example.com/unread", Timestamp: unix(1600000000, 0), Tags: []string{"go", "tools"}},
+		{ID: hashID(domain.SourcePocket, "https://example.com/read"), Source: domain.SourcePocket, Title: "https://example.com/read", URL: "https://example.com/read", Timestamp: unix(1600000100, 0)},
+	})
+	checkStories(t, "csv", load(t, "pocket", "pocket.csv"), []*domain.Story{
+		{ID: hashID(domain.SourcePocket, "https://example.com/pocket"), Source: domain.SourcePocket, Title: "Quoted, title", URL: "https://example.com/pocket", Timestamp: unix(1600000000, 0), Tags: []string{"go", "tools"}},
+		{ID: hashID(domain.SourcePocket, "https://example.com/untitled"), Source: domain.SourcePocket, Title: "https://example.com/untitled", URL: "https://example.com/untitled", Timestamp: unix(1600000100, 0)},
+		{ID: hashID(domain.SourcePocket, "https://example.com/short"), Source: domain.SourcePocket, Title: "Short row", URL: "https://example.com/short"},
+	})
+}
+
+func TestInstapaper(t *testing.T) {
+	// The Unread folder is the default rather than a meaningful tag.
+	checkStories(t, "instapaper", load(t, "instapaper", "instapaper.csv"), []*domain.Story{
+		{ID: hashID(domain.SourceInstapaper, "https://example.com/i1"), Source: domain.SourceInstapaper, Title: "First", URL: "https://example.com/i1", Timestamp: unix(1610000000, 0)},
+		{ID: hashID(domain.SourceInstapaper, "https://example.com/i2"), Source: domain.SourceInstapaper, Title: "Second", URL: "https://example.com/i2", Timestamp: unix(1610000100, 0), Tags: []string{"Archive"}},
+		{ID: hashID(domain.SourceInstapaper, "https://example.com/i3"), Source: domain.SourceInstapaper, Title: "https://example.com/i3", URL: "https://example.com/i3", Timestamp: unix(1610000200, 0), Tags: []string{"Go Stuff"}},
+	})
+}
+
+func TestCSVRecords(t *testing.T) {
+	testCases := []struct {
+		data     string
+		expected []map[string]string
+	}{
+		{"\xef\xbb\xbfURL, Title \nhttps://a, A \n", []map[string]string{{"url": "https://a", "title": "A"}}},
+		{"url,title\nhttps://a\nhttps://b,B,extra\n", []map[string]string{{"url": "https://a"}, {"url": "https://b", "title": "B"}}},
+		{"url,title\nhttps://a,\"Say \"hi\"\"\n", []map[string]string{{"url": "https://a", "title": `Say "hi"`}}},
+		{"url,title\r\nhttps://a,\"Multi\r\nline\"\r\n", []map[string]string{{"url": "https://a", "title": "Multi\nline"}}},
+		{"url,title\n", nil},
+	}
+	for _, testCase := range testCases {
+		rows, err := csvRecords([]byte(testCase.data))
+		if err != nil {
+			t.Errorf("%q: %s", testCase.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, testCase.expected) {
+			t.Errorf("%q: expected %v, got %v", testCase.data, testCase.expected, rows)
+		}
+	}
+
+	if _, err := csvRecords(nil); err == nil {
+		t.Errorf("expected an error for an empty file")
+	}
+}
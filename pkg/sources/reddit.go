@@ -0,0 +1,108 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// MaxRedditPages bounds how many listing pages are followed.
+var MaxRedditPages = 40
+
+func init() {
+	Register(domain.SourceReddit, func(location string) (Source, error) {
+		return &Reddit{Location: location}, nil
+	})
+}
+
+// Reddit reads a Reddit listing in its JSON format, typically the private
+// saved-posts feed (https://www.reddit.com/user/<user>/saved.json?feed=<token>&user=<user>,
+// with the token from https://www.reddit.com/prefs/feeds) or an exported
+// copy of it.  Saved comments are skipped.
+type Reddit struct {
+	Location string
+}
+
+type redditListing struct {
+	Data struct {
+		After    string `json:"after"`
+		Children []struct {
+			Kind string `json:"kind"`
+			Data struct {
+				ID          string  `json:"id"`
+				Title       string  `json:"title"`
+				URL         string  `json:"url"`
+				Permalink   string  `json:"permalink"`
+				Author      string  `json:"author"`
+				Score       int     `json:"score"`
+				NumComments int     `json:"num_comments"`
+				CreatedUTC  float64 `json:"created_utc"`
+				Subreddit   string  `json:"subreddit"`
+				IsSelf      bool    `json:"is_self"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// Name implements Source.
+func (s *Reddit) Name() string {
+	return domain.SourceReddit
+}
+
+// Stories implements Source.  Remote listings are paged through until
+// exhausted.
+func (s *Reddit) Stories() ([]*domain.Story, error) {
+	var (
+		stories  = []*domain.Story{}
+		location = s.Location
+	)
+	for page := 0; page < MaxRedditPages; page++ {
+		data, err := open(location)
+		if err != nil {
+			return nil, err
+		}
+		var listing redditListing
+		if err := json.Unmarshal(data, &listing); err != nil {
+			return nil, fmt.Errorf("unmarshalling reddit listing: %s", err)
+		}
+
+		for _, child := range listing.Data.Children {
+			if child.Kind != "t3" { // Links; t1 is a comment.
+				continue
+			}
+			post := child.Data
+			story := &domain.Story{
+				ID:        prefixedID(domain.SourceReddit, post.ID),
+				Source:    domain.SourceReddit,
+				Title:     post.Title,
+				URL:       post.URL,
+				Submitter: post.Author,
+				Points:    post.Score,
+				Comments:  post.NumComments,
+				Timestamp: domain.Time{Time: time.Unix(int64(post.CreatedUTC), 0).UTC()},
+				Permalink: "https://www.reddit.com" + post.Permalink,
+			}
+			if post.Subreddit != "" {
+				story.Tags = []string{post.Subreddit}
+			}
+			stories = append(stories, story)
+		}
+
+		if listing.Data.After == "" || !strings.Contains(location, "://") {
+			break
+		}
+		u, err := url.Parse(location)
+		if err != nil {
+			break
+		}
+		q := u.Query()
+		q.Set("after", listing.Data.After)
+		u.RawQuery = q.Encode()
+		location = u.String()
+	}
+	return stories, nil
+}
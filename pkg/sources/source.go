@@ -0,0 +1,102 @@
+package sources
+
+// Story sources: places links to hydrate come from.
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// Source produces stories.
+type Source interface {
+	// Name identifies the source, and is one of the domain.Source* constants.
+	Name() string
+
+	// Stories returns every story available from the source.
+	Stories() ([]*domain.Story, error)
+}
+
+// Constructor creates a Source reading from location, which is a file path or
+// URL depending on the source.
+type Constructor func(location string) (Source, error)
+
+var registry = map[string]Constructor{}
+
+// Register makes a source available to New under name.
+func Register(name string, constructor Constructor) {
+	registry[name] = constructor
+}
+
+// Names returns the registered source names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the source registered under name.
+func New(name string, location string) (Source, error) {
+	constructor, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized source %q, must be one of: %v", name, strings.Join(Names(), ", "))
+	}
+	return constructor(location)
+}
+
+// HTTPClient is used by sources which fetch over the network.
+var HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// UserAgent is sent by sources which fetch over the network.  Some APIs (e.g.
+// Reddit) throttle requests with generic user agents.
+var UserAgent = "circus/1.0 (+https://b.jaytaylor.com/)"
+
+// open reads location, which may be "-" for stdin, a local file or an
+// http(s) URL.
+func open(location string) ([]byte, error) {
+	switch {
+	case location == "-":
+		return ioutil.ReadAll(os.Stdin)
+
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		req, err := http.NewRequest("GET", location, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request to %v: %s", location, err)
+		}
+		req.Header.Set("User-Agent", UserAgent)
+		resp, err := HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %v: %s", location, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("fetching %v: non-2xx response status-code=%v", location, resp.StatusCode)
+		}
+		return ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024*1024))
+
+	default:
+		return ioutil.ReadFile(location)
+	}
+}
+
+// hashID derives a stable, filename-safe story ID from key for sources
+// without IDs of their own.
+func hashID(source string, key string) domain.StoryID {
+	return domain.HashStoryID(source, key)
+}
+
+// prefixedID namespaces a source's own ID so it cannot collide with numeric
+// HN IDs.
+func prefixedID(source string, id string) domain.StoryID {
+	return domain.StoryID(source + "-" + id)
+}
@@ -0,0 +1,191 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"jaytaylor.com/circus/domain"
+)
+
+// load reads the stories from the named source in testdata.
+func load(t *testing.T, name string, fixture string) []*domain.Story {
+	s, err := New(name, "testdata/"+fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stories, err := s.Stories()
+	if err != nil {
+		t.Fatalf("%v %v: %s", name, fixture, err)
+	}
+	return stories
+}
+
+func at(year int, month time.Month, day int, hour int, min int, sec int) domain.Time {
+	return domain.Time{Time: time.Date(year, month, day, hour, min, sec, 0, time.UTC)}
+}
+
+func unix(secs int64, nsecs int64) domain.Time {
+	return domain.Time{Time: time.Unix(secs, nsecs).UTC()}
+}
+
+func checkStories(t *testing.T, label string, actual []*domain.Story, expected []*domain.Story) {
+	if len(actual) != len(expected) {
+		for _, story := range actual {
+			t.Logf("%v: got %+v", label, *story)
+		}
+		t.Fatalf("%v: expected %v stories, got %v", label, len(expected), len(actual))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(actual[i], expected[i]) {
+			t.Errorf("%v [%v]:\nexpected %+v\n     got %+v", label, i, *expected[i], *actual[i])
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"hn", "hn-api", "lobsters", "reddit", "pinboard", "feed", "urls", "bookmarks-html", "firefox", "chrome", "pocket", "instapaper"} {
+		if _, err := New(name, "-"); err != nil {
+			t.Errorf("%v: %s", name, err)
+		}
+	}
+	if _, err := New("myspace", "-"); err == nil || !strings.Contains(err.Error(), "must be one of") {
+		t.Errorf("expected an unrecognized source error, got %v", err)
+	}
+}
+
+func TestHN(t *testing.T) {
+	checkStories(t, "hn", load(t, "hn", "hn.json"), []*domain.Story{
+		{ID: "17205865", Source: domain.SourceHN, Title: "Show HN: Something", URL: "https://example.com/show", Submitter: "pg", Points: 100, Comments: 20, Timestamp: unix(1527854400, 0)},
+		{ID: "17205866", Source: domain.SourceHN, Title: "Second", URL: "https://example.com/second", Timestamp: at(2018, 6, 1, 12, 0, 0)},
+	})
+}
+
+func TestLobsters(t *testing.T) {
+	expected := []*domain.Story{
+		{ID: "lobsters-abc123", Source: domain.SourceLobsters, Title: "Go generics, a year in", URL: "https://example.com/generics", Submitter: "alice", Points: 42, Comments: 7, Timestamp: at(2022, 3, 15, 15, 20, 30), Tags: []string{"go", "plt"}, Permalink: "https://lobste.rs/s/abc123/go_generics_year"},
+		// Text posts link to their discussion, and older listings hold user
+		// objects rather than names.
+		{ID: "lobsters-def456", Source: domain.SourceLobsters, Title: "Ask: favourite debuggers?", URL: "https://lobste.rs/s/def456/ask_favourite_debuggers", Submitter: "bob", Points: 3, Comments: 12, Timestamp: at(2018, 1, 2, 3, 4, 5), Tags: []string{"ask"}, Permalink: "https://lobste.rs/s/def456/ask_favourite_debuggers"},
+	}
+	checkStories(t, "file", load(t, "lobsters", "lobsters.json"), expected)
+
+	// Locations which are not files are paths on LobstersBaseURL.
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		http.ServeFile(w, r, "testdata/lobsters.json")
+	}))
+	defer server.Close()
+	defer func(base string) { LobstersBaseURL = base }(LobstersBaseURL)
+	LobstersBaseURL = server.URL + "/"
+
+	stories, err := (&Lobsters{Location: "/t/go/"}).Stories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/t/go.json" {
+		t.Errorf("expected a request for /t/go.json, got %v", path)
+	}
+	checkStories(t, "remote", stories, expected)
+}
+
+func TestReddit(t *testing.T) {
+	first := &domain.Story{ID: "reddit-first", Source: domain.SourceReddit, Title: "A link post", URL: "https://example.com/post", Submitter: "gopher", Points: 120, Comments: 30, Timestamp: at(2020, 1, 1, 0, 0, 0), Tags: []string{"golang"}, Permalink: "https://www.reddit.com/r/golang/comments/first/a_link_post/"}
+
+	// Saved comments are skipped, and files are not paged through.
+	checkStories(t, "file", load(t, "reddit", "reddit.json"), []*domain.Story{first})
+
+	var afters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != UserAgent {
+			t.Errorf("expected User-Agent %q, got %q", UserAgent, r.Header.Get("User-Agent"))
+		}
+		after := r.URL.Query().Get("after")
+		afters = append(afters, after)
+		if after == "" {
+			http.ServeFile(w, r, "testdata/reddit.json")
+			return
+		}
+		w.Write([]byte(`{"data":{"after":null,"children":[{"kind":"t3","data":{"id":"second","title":"Self post","url":"https://www.reddit.com/r/golang/comments/second/","permalink":"/r/golang/comments/second/","author":"gopher","created_utc":1577923200,"subreddit":"golang","is_self":true}}]}}`))
+	}))
+	defer server.Close()
+
+	stories, err := (&Reddit{Location: server.URL + "/user/gopher/saved.json?feed=token&user=gopher"}).Stories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"", "t3_second"}; !reflect.DeepEqual(afters, expected) {
+		t.Errorf("expected pages after %q, got %q", expected, afters)
+	}
+	checkStories(t, "remote", stories, []*domain.Story{
+		first,
+		{ID: "reddit-second", Source: domain.SourceReddit, Title: "Self post", URL: "https://www.reddit.com/r/golang/comments/second/", Submitter: "gopher", Timestamp: at(2020, 1, 2, 0, 0, 0), Tags: []string{"golang"}, Permalink: "https://www.reddit.com/r/golang/comments/second/"},
+	})
+}
+
+func TestPinboard(t *testing.T) {
+	checkStories(t, "pinboard", load(t, "pinboard", "pinboard.json"), []*domain.Story{
+		{ID: "pinboard-0123456789abcdef", Source: domain.SourcePinboard, Title: "Example A", URL: "https://example.com/a", Timestamp: at(2019, 5, 6, 7, 8, 9), Tags: []string{"go", "databases"}},
+		{ID: hashID(domain.SourcePinboard, "https://example.com/b"), Source: domain.SourcePinboard, Title: "Example B", URL: "https://example.com/b", Timestamp: at(2019, 5, 7, 0, 0, 0), Tags: []string{}},
+	})
+}
+
+func TestFeed(t *testing.T) {
+	checkStories(t, "rss", load(t, "feed", "feed-rss.xml"), []*domain.Story{
+		{ID: hashID(domain.SourceFeed, "urn:example:first"), Source: domain.SourceFeed, Title: "First item", URL: "https://example.com/first", Submitter: "Ann Author", Timestamp: at(2006, 1, 2, 22, 4, 5), Tags: []string{"go", "web"}, Permalink: "https://example.com/first#comments"},
+		{ID: hashID(domain.SourceFeed, "https://example.com/second"), Source: domain.SourceFeed, Title: "Second item", URL: "https://example.com/second", Submitter: "bob@example.com", Timestamp: at(2006, 1, 3, 10, 0, 0)},
+	})
+	checkStories(t, "atom", load(t, "feed", "feed-atom.xml"), []*domain.Story{
+		{ID: hashID(domain.SourceFeed, "tag:example.com,2020:1"), Source: domain.SourceFeed, Title: "Atom entry", URL: "https://example.com/entry", Submitter: "Carol", Timestamp: at(2020, 2, 3, 3, 5, 6), Tags: []string{"rust"}, Permalink: "https://example.com/entry#comments"},
+		{ID: hashID(domain.SourceFeed, "https://example.com/updated"), Source: domain.SourceFeed, Title: "Updated only", URL: "https://example.com/updated", Timestamp: at(2021, 1, 1, 0, 0, 0)},
+	})
+
+	if _, err := (&Feed{Location: "testdata/pinboard.json"}).Stories(); err == nil {
+		t.Errorf("expected an error reading a non-feed")
+	}
+	if _, err := (&Feed{Location: "testdata/bookmarks.html"}).Stories(); err == nil || !strings.Contains(err.Error(), "unrecognized feed format") {
+		t.Errorf("expected an unrecognized feed format error, got %v", err)
+	}
+}
+
+func TestFeedRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/feed-atom.xml")
+	}))
+	defer server.Close()
+
+	stories, err := (&Feed{Location: server.URL + "/feed.xml"}).Stories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 2 {
+		t.Errorf("expected 2 stories, got %v", len(stories))
+	}
+	if _, err := (&Feed{Location: server.URL + "/missing.xml"}).Stories(); err == nil || !strings.Contains(err.Error(), "status-code=404") {
+		t.Errorf("expected a non-2xx error, got %v", err)
+	}
+}
+
+func TestURLList(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	stories := load(t, "urls", "urls.txt")
+	for _, story := range stories {
+		if story.Timestamp.Before(before) || story.Timestamp.After(time.Now()) {
+			t.Errorf("%v: expected the current time, got %v", story.URL, story.Timestamp)
+		}
+		story.Timestamp = domain.Time{}
+	}
+	checkStories(t, "urls", stories, []*domain.Story{
+		{ID: hashID(domain.SourceURLs, "https://example.com/one"), Source: domain.SourceURLs, Title: "https://example.com/one", URL: "https://example.com/one"},
+		{ID: hashID(domain.SourceURLs, "https://example.com/two"), Source: domain.SourceURLs, Title: "Two, with a title", URL: "https://example.com/two"},
+		{ID: hashID(domain.SourceURLs, "https://example.com/three"), Source: domain.SourceURLs, Title: "Tab separated", URL: "https://example.com/three"},
+	})
+}
@@ -0,0 +1,65 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"time"
+	"unicode"
+
+	"jaytaylor.com/circus/domain"
+)
+
+func init() {
+	Register(domain.SourceURLs, func(location string) (Source, error) {
+		return &URLList{Location: location}, nil
+	})
+}
+
+// URLList reads a plain text file of URLs, one per line.  Blank lines and
+// lines starting with "#" are ignored, and anything after the URL on a line
+// is used as the title.
+type URLList struct {
+	Location string
+}
+
+// Name implements Source.
+func (s *URLList) Name() string {
+	return domain.SourceURLs
+}
+
+// Stories implements Source.
+func (s *URLList) Stories() ([]*domain.Story, error) {
+	data, err := open(s.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stories = []*domain.Story{}
+		now     = time.Now().UTC().Truncate(time.Second)
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, title := line, ""
+		if i := strings.IndexFunc(line, unicode.IsSpace); i != -1 {
+			u, title = line[0:i], strings.TrimSpace(line[i:])
+		}
+		story := &domain.Story{
+			ID:        hashID(domain.SourceURLs, u),
+			Source:    domain.SourceURLs,
+			Title:     titleOrURL(title, u),
+			URL:       u,
+			Timestamp: domain.Time{Time: now},
+		}
+		stories = append(stories, story)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
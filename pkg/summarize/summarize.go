@@ -0,0 +1,180 @@
+package summarize
+
+// Extractive summarization using TextRank over the sentences of a document.
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+const (
+	damping       = 0.85
+	maxIterations = 50
+	tolerance     = 1e-6
+
+	// maxSentences bounds the quadratic similarity graph for long documents.
+	maxSentences = 400
+
+	minSentenceWords = 6
+	maxSentenceWords = 80
+)
+
+var (
+	// boilerplateExpr matches phrases which are almost always page chrome
+	// rather than article content.  Single words such as "cookies" or
+	// "JavaScript" are not enough, as articles are often about them.
+	boilerplateExpr = regexp.MustCompile(`(?i)\b(?:` + strings.Join([]string{
+		`(?:we|this (?:site|website)) uses? cookies`,
+		`(?:accept|allow|enable) (?:all )?cookies`,
+		`cookie (?:policy|settings|preferences)`,
+		`(?:enable|turn on|activate) javascript`,
+		`javascript (?:is )?(?:disabled|required)`,
+		`requires? javascript`,
+		`subscribe (?:to|for) (?:our|the|my) (?:newsletter|mailing list|channel|podcast|feed)`,
+		`sign up for (?:our|the|my) (?:newsletter|mailing list)`,
+		`(?:log|sign) in to (?:continue|comment|read|view|reply)`,
+		`you must be (?:logged|signed) in`,
+		`all rights reserved`,
+		`privacy policy`,
+		`terms of (?:use|service)`,
+	}, "|") + `)\b`)
+)
+
+// Result holds an extractive summary.
+type Result struct {
+	Summary      string   // Highest ranked sentences, in document order.
+	KeySentences []string // Highest ranked sentences, best first.
+}
+
+// Summarize ranks the sentences of text with TextRank and returns the top n.
+func Summarize(text string, n int) Result {
+	var (
+		sentences = candidates(textmanip.Sentences(text))
+		result    = Result{}
+	)
+	if len(sentences) == 0 || n <= 0 {
+		return result
+	}
+
+	scores := rank(sentences)
+
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	if n > len(order) {
+		n = len(order)
+	}
+	top := order[0:n]
+
+	for _, i := range top {
+		result.KeySentences = append(result.KeySentences, sentences[i].text)
+	}
+
+	inDocOrder := append([]int(nil), top...)
+	sort.Ints(inDocOrder)
+	parts := make([]string, 0, n)
+	for _, i := range inDocOrder {
+		parts = append(parts, sentences[i].text)
+	}
+	result.Summary = strings.Join(parts, " ")
+
+	return result
+}
+
+type sentence struct {
+	text  string
+	terms map[string]struct{}
+}
+
+// candidates filters out fragments, run-ons and boilerplate, and computes the
+// term sets used for similarity.
+func candidates(texts []string) []sentence {
+	var out []sentence
+	for _, text := range texts {
+		words := textmanip.Words(text)
+		if len(words) < minSentenceWords || len(words) > maxSentenceWords || boilerplateExpr.MatchString(text) {
+			continue
+		}
+		terms := map[string]struct{}{}
+		for _, word := range words {
+			if !textmanip.IsStopWord(word) {
+				terms[word] = struct{}{}
+			}
+		}
+		out = append(out, sentence{text: text, terms: terms})
+		if len(out) >= maxSentences {
+			break
+		}
+	}
+	return out
+}
+
+// rank runs weighted PageRank over the sentence similarity graph.
+func rank(sentences []sentence) []float64 {
+	n := len(sentences)
+	weights := make([][]float64, n)
+	outSums := make([]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			w := similarity(sentences[i], sentences[j])
+			weights[i][j], weights[j][i] = w, w
+			outSums[i] += w
+			outSums[j] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	for iter := 0; iter < maxIterations; iter++ {
+		var (
+			next  = make([]float64, n)
+			delta float64
+		)
+		for i := 0; i < n; i++ {
+			var sum float64
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 && outSums[j] > 0 {
+					sum += weights[j][i] / outSums[j] * scores[j]
+				}
+			}
+			next[i] = (1 - damping) + damping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < tolerance {
+			break
+		}
+	}
+	return scores
+}
+
+// similarity is the TextRank sentence similarity: shared terms normalized by
+// the log lengths of both sentences.
+func similarity(a sentence, b sentence) float64 {
+	if len(a.terms) < 2 || len(b.terms) < 2 {
+		return 0
+	}
+	var shared int
+	for term := range a.terms {
+		if _, ok := b.terms[term]; ok {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / (math.Log(float64(len(a.terms))) + math.Log(float64(len(b.terms))))
+}
@@ -0,0 +1,99 @@
+package summarize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeRanking(t *testing.T) {
+	text := strings.Join([]string{
+		"The Go garbage collector trades throughput for low pause latency.",
+		"Pause latency in the garbage collector dropped below one millisecond.",
+		"My cat prefers sleeping on warm laptops during long afternoons.",
+		"The garbage collector runs concurrently with goroutines to keep pause latency low.",
+		"Goroutines allocate memory which the collector later reclaims concurrently.",
+	}, " ")
+
+	result := Summarize(text, 2)
+	if len(result.KeySentences) != 2 {
+		t.Fatalf("expected 2 key sentences, got %v", result.KeySentences)
+	}
+	if expected := "The garbage collector runs concurrently with goroutines to keep pause latency low."; result.KeySentences[0] != expected {
+		t.Errorf("expected the most connected sentence first, got %q", result.KeySentences[0])
+	}
+	for _, s := range result.KeySentences {
+		if strings.Contains(s, "cat") {
+			t.Errorf("unrelated sentence ranked in the top 2: %q", s)
+		}
+	}
+
+	// The summary holds the same sentences in document order.
+	first, second := strings.Index(text, result.KeySentences[0]), strings.Index(text, result.KeySentences[1])
+	expected := result.KeySentences[0] + " " + result.KeySentences[1]
+	if second < first {
+		expected = result.KeySentences[1] + " " + result.KeySentences[0]
+	}
+	if result.Summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, result.Summary)
+	}
+}
+
+func TestSummarizeBoilerplate(t *testing.T) {
+	testCases := []struct {
+		sentence string
+		dropped  bool
+	}{
+		{"We use cookies to improve your experience on this website.", true},
+		{"Please enable JavaScript in your browser to view this page.", true},
+		{"Subscribe to our newsletter for weekly updates on everything.", true},
+		{"Sign up for our newsletter and never miss another story.", true},
+		{"You must be logged in to post a comment here.", true},
+		{"Copyright 2020 Example Media, all rights reserved worldwide.", true},
+		{"Read our privacy policy and terms of service for details.", true},
+		{"JavaScript closures capture variables from their enclosing scope.", false},
+		{"Browsers store cookies per origin and send them with each request.", false},
+		{"Readers can subscribe to any feed with a standard RSS reader.", false},
+		{"Users log in with a passkey instead of a password today.", false},
+	}
+	for _, testCase := range testCases {
+		kept := len(candidates([]string{testCase.sentence})) == 1
+		if kept == testCase.dropped {
+			t.Errorf("%q: expected dropped=%v", testCase.sentence, testCase.dropped)
+		}
+	}
+}
+
+func TestSummarizeShortInputs(t *testing.T) {
+	testCases := []struct {
+		text     string
+		n        int
+		expected []string
+	}{
+		{"", 3, nil},
+		{"Too short. Also short.", 3, nil},
+		{"This sentence is long enough to be a candidate.", 0, nil},
+		{"This sentence is long enough to be a candidate.", -1, nil},
+		{"This sentence is long enough to be a candidate. Tiny one.", 3, []string{"This sentence is long enough to be a candidate."}},
+	}
+	for i, testCase := range testCases {
+		result := Summarize(testCase.text, testCase.n)
+		if !reflect.DeepEqual(result.KeySentences, testCase.expected) {
+			t.Errorf("[%v] expected key sentences %v, got %v", i, testCase.expected, result.KeySentences)
+		}
+		if expected := strings.Join(testCase.expected, " "); result.Summary != expected {
+			t.Errorf("[%v] expected summary %q, got %q", i, expected, result.Summary)
+		}
+	}
+}
+
+func TestSummarizeFewerSentencesThanRequested(t *testing.T) {
+	text := "First sentence here is long enough to count.\nSecond sentence here is also long enough to count."
+	result := Summarize(text, 5)
+	if len(result.KeySentences) != 2 {
+		t.Errorf("expected every sentence, got %v", result.KeySentences)
+	}
+	if expected := "First sentence here is long enough to count. Second sentence here is also long enough to count."; result.Summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, result.Summary)
+	}
+}
@@ -0,0 +1,132 @@
+package textmanip
+
+// Escaping of untrusted article text for inclusion in Hugo Markdown content
+// and YAML front matter.
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// blockStartExpr matches line prefixes which Markdown would interpret as
+	// block syntax: headings, blockquotes, list items, fences, tables and
+	// thematic breaks / front matter delimiters.
+	blockStartExpr = regexp.MustCompile("^(\\s*)(#|>|[-+*](?:\\s|$)|\\d+[.)](?:\\s|$)|```|~~~|\\||=+\\s*$|-{3,}|\\*{3,}|_{3,})")
+
+	inlineEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		"`", "\\`",
+		`*`, `\*`,
+		`_`, `\_`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`<`, `&lt;`,
+		`>`, `&gt;`,
+	)
+
+	orderedMarkerExpr = regexp.MustCompile(`^(\d+)([.)])`)
+
+	// shortcodeEscaper inserts a zero-width space into Hugo shortcode
+	// delimiters.
+	shortcodeEscaper = strings.NewReplacer(
+		"{{<", "{{\u200b<",
+		"{{%", "{{\u200b%",
+		">}}", ">\u200b}}",
+		"%}}", "%\u200b}}",
+	)
+
+	// urlEscaper percent-encodes characters which would end or break out of
+	// a Markdown link destination.
+	urlEscaper = strings.NewReplacer(
+		"(", "%28",
+		")", "%29",
+		"<", "%3C",
+		">", "%3E",
+		" ", "%20",
+		"\t", "%09",
+		"\r", "%0D",
+		"\n", "%0A",
+		"\\", "%5C",
+	)
+)
+
+// MarkdownEscape renders plain text so that it displays verbatim in Markdown:
+// inline formatting characters are backslash-escaped, HTML is entity-encoded,
+// lines which would start a block construct are neutralized, and Hugo
+// shortcode delimiters are broken up.
+func MarkdownEscape(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		escaped := inlineEscaper.Replace(line)
+		if m := blockStartExpr.FindStringSubmatchIndex(line); m != nil {
+			// Only the leading whitespace precedes the block marker in both
+			// the original and escaped line.
+			lead := line[m[2]:m[3]]
+			rest := escaped[len(lead):]
+			switch {
+			case strings.HasPrefix(rest, `\`), strings.HasPrefix(rest, "&"):
+				// Already neutralized by inline escaping.
+			case orderedMarkerExpr.MatchString(rest):
+				rest = orderedMarkerExpr.ReplaceAllString(rest, `$1\$2`)
+			default:
+				rest = `\` + rest
+			}
+			escaped = lead + rest
+		}
+		lines[i] = escaped
+	}
+	return ShortcodeSafe(strings.Join(lines, "\n"))
+}
+
+// ShortcodeSafe breaks up Hugo shortcode delimiters ("{{<", "{{%" and their
+// closing forms) with a zero-width space so Hugo will not try to execute them.
+// It is safe to apply to already-rendered Markdown, including code blocks.
+func ShortcodeSafe(s string) string {
+	return shortcodeEscaper.Replace(s)
+}
+
+// MarkdownURL makes u safe to use as a Markdown link destination.  Only
+// http, https and root-relative URLs are kept, so stored data cannot inject
+// javascript: or other scheme links, and characters which would end the
+// destination are percent-encoded.
+func MarkdownURL(u string) string {
+	u = strings.TrimSpace(u)
+	lower := strings.ToLower(u)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "/") {
+		return ""
+	}
+	return ShortcodeSafe(urlEscaper.Replace(u))
+}
+
+// YAMLString renders s as a double-quoted YAML scalar.
+func YAMLString(s string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			// YAML treats these as line breaks or disallows them outright.
+			if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' || r == '\ufeff' {
+				fmt.Fprintf(buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
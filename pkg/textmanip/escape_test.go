@@ -0,0 +1,97 @@
+package textmanip
+
+import (
+	"testing"
+)
+
+func TestMarkdownEscape(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"plain text", "plain text"},
+		{"*bold* _em_ `code`", "\\*bold\\* \\_em\\_ \\`code\\`"},
+		{"[click](javascript:alert(1))", "\\[click\\](javascript:alert(1))"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"# not a heading", "\\# not a heading"},
+		{"  > not a quote", "  &gt; not a quote"},
+		{"- not a list", "\\- not a list"},
+		{"1. not a list", "1\\. not a list"},
+		{"2) not a list", "2\\) not a list"},
+		{"---", "\\---"},
+		{"***", "\\*\\*\\*"},
+		{"```go", "\\`\\`\\`go"},
+		{"| a | b |", "\\| a | b |"},
+		{"Title\n===", "Title\n\\==="},
+		{"{{< shortcode >}}", "{{&lt; shortcode &gt;}}"},
+		{"{{% shortcode %}}", "{{\u200b% shortcode %\u200b}}"},
+		{"a\\b", "a\\\\b"},
+		{"C# 2024", "C# 2024"},
+	}
+	for _, testCase := range testCases {
+		if actual := MarkdownEscape(testCase.in); actual != testCase.expected {
+			t.Errorf("MarkdownEscape(%q) = %q, expected %q", testCase.in, actual, testCase.expected)
+		}
+	}
+}
+
+func TestShortcodeSafe(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"{{< youtube id >}}", "{{\u200b< youtube id >\u200b}}"},
+		{"{{% notice %}}", "{{\u200b% notice %\u200b}}"},
+		{"{{ .Title }}", "{{ .Title }}"},
+		{"```\n{{<x>}}\n```", "```\n{{\u200b<x>\u200b}}\n```"},
+	}
+	for _, testCase := range testCases {
+		if actual := ShortcodeSafe(testCase.in); actual != testCase.expected {
+			t.Errorf("ShortcodeSafe(%q) = %q, expected %q", testCase.in, actual, testCase.expected)
+		}
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"plain", `"plain"`},
+		{`Say "hi": # not a comment`, `"Say \"hi\": # not a comment"`},
+		{`C:\path`, `"C:\\path"`},
+		{"line\nbreak\r\ttab", `"line\nbreak\r\ttab"`},
+		{"---\ntitle: injected", `"---\ntitle: injected"`},
+		{"- item", `"- item"`},
+		{"nul\x00bel\x07", `"nul\u0000bel\u0007"`},
+		{"sep\u2028par\u2029bom\ufeff", `"sep\u2028par\u2029bom\uFEFF"`},
+		{"'single' & *alias", `"'single' & *alias"`},
+	}
+	for _, testCase := range testCases {
+		if actual := YAMLString(testCase.in); actual != testCase.expected {
+			t.Errorf("YAMLString(%q) = %v, expected %v", testCase.in, actual, testCase.expected)
+		}
+	}
+}
+
+func TestMarkdownURL(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"https://example.com/a?b=c#d", "https://example.com/a?b=c#d"},
+		{"/snapshots/1.html", "/snapshots/1.html"},
+		{"HTTP://EXAMPLE.COM/", "HTTP://EXAMPLE.COM/"},
+		{"https://example.com/a) [x](javascript:alert(1)", "https://example.com/a%29%20[x]%28javascript:alert%281%29"},
+		{"https://example.com/<x>\n", "https://example.com/%3Cx%3E"},
+		{"javascript:alert(1)", ""},
+		{"java\tscript:alert(1)", ""},
+		{"data:text/html,x", ""},
+		{"relative/path", ""},
+	}
+	for _, testCase := range testCases {
+		if actual := MarkdownURL(testCase.in); actual != testCase.expected {
+			t.Errorf("MarkdownURL(%q) = %q, expected %q", testCase.in, actual, testCase.expected)
+		}
+	}
+}
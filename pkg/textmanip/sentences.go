@@ -0,0 +1,30 @@
+package textmanip
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sentenceEndExpr matches sentence-terminating punctuation followed by
+// whitespace and the start of a new sentence.
+var sentenceEndExpr = regexp.MustCompile(`([.!?]["')\]]?)\s+(["'(\[]?[A-Z0-9])`)
+
+// Sentences splits text into sentences.  Line breaks are treated as hard
+// sentence boundaries since extracted article text places paragraphs on their
+// own lines.
+func Sentences(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		marked := sentenceEndExpr.ReplaceAllString(line, "$1\x00$2")
+		for _, sentence := range strings.Split(marked, "\x00") {
+			if sentence = strings.TrimSpace(sentence); sentence != "" {
+				out = append(out, sentence)
+			}
+		}
+	}
+	return out
+}
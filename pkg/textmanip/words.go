@@ -0,0 +1,13 @@
+package textmanip
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words splits text into lower-cased words, discarding punctuation.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
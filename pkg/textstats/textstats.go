@@ -0,0 +1,133 @@
+package textstats
+
+// Word counts, reading time and readability metrics for article text.
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"jaytaylor.com/circus/pkg/textmanip"
+)
+
+// WordsPerMinute is the assumed reading speed for prose.  Code is assumed to
+// be read at a quarter of this rate.
+const WordsPerMinute = 230
+
+// Stats holds the metrics computed for a piece of text.
+type Stats struct {
+	Words              int     `json:"words"`
+	Sentences          int     `json:"sentences"`
+	ReadingTime        int     `json:"readingTime"`        // Estimated minutes.
+	FleschKincaidGrade float64 `json:"fleschKincaidGrade"` // U.S. school grade level.
+	CodeBlockRatio     float64 `json:"codeBlockRatio"`     // Fraction of lines which look like source code.
+}
+
+// Analyze computes Stats for text.
+func Analyze(text string) *Stats {
+	var (
+		stats     = &Stats{}
+		syllables int
+		codeWords int
+		lines     int
+		codeLines int
+	)
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines++
+		if IsCodeLine(line) {
+			codeLines++
+			codeWords += len(textmanip.Words(line))
+			continue
+		}
+		for _, word := range textmanip.Words(line) {
+			syllables += Syllables(word)
+			stats.Words++
+		}
+		stats.Sentences += len(textmanip.Sentences(line))
+	}
+
+	if lines > 0 {
+		stats.CodeBlockRatio = round(float64(codeLines)/float64(lines), 3)
+	}
+	if stats.Words > 0 && stats.Sentences > 0 {
+		grade := 0.39*float64(stats.Words)/float64(stats.Sentences) + 11.8*float64(syllables)/float64(stats.Words) - 15.59
+		stats.FleschKincaidGrade = round(math.Max(grade, 0), 1)
+	}
+	stats.Words += codeWords
+
+	minutes := (float64(stats.Words-codeWords) + 4*float64(codeWords)) / WordsPerMinute
+	stats.ReadingTime = int(math.Ceil(minutes))
+	if stats.ReadingTime < 1 && stats.Words > 0 {
+		stats.ReadingTime = 1
+	}
+
+	return stats
+}
+
+// codeIndicators are substrings which rarely appear in prose.
+var codeIndicators = []string{"{", "}", ";", "=>", "->", ":=", "==", "!=", "&&", "||", "()", "</", "/>", "#include", "func ", "def ", "import ", "return ", "var ", "const ", "$ "}
+
+// IsCodeLine uses punctuation density and common syntax to guess whether a
+// line of extracted text is source code.
+func IsCodeLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+	if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+		return true
+	}
+
+	var symbols, letters int
+	for _, r := range trimmed {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case strings.ContainsRune("{}[]()<>;=+-*/\\|&!$#%^~`", r):
+			symbols++
+		}
+	}
+	indicators := 0
+	for _, indicator := range codeIndicators {
+		if strings.Contains(trimmed, indicator) {
+			indicators++
+		}
+	}
+	endsLikeCode := strings.HasSuffix(trimmed, ";") || strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, "}")
+
+	return indicators >= 2 || (endsLikeCode && indicators >= 1) || (letters > 0 && float64(symbols)/float64(letters) > 0.3)
+}
+
+// Syllables estimates the number of syllables in an English word by counting
+// vowel groups.
+func Syllables(word string) int {
+	word = strings.ToLower(word)
+	var (
+		count     int
+		prevVowel bool
+	)
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	// Silent trailing "e", e.g. "make", but not "le" as in "table".
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+func round(f float64, places int) float64 {
+	shift := math.Pow(10, float64(places))
+	return math.Round(f*shift) / shift
+}
@@ -0,0 +1,150 @@
+package textstats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected Stats
+	}{
+		{
+			name:     "empty",
+			text:     "",
+			expected: Stats{},
+		},
+		{
+			name:     "blank lines",
+			text:     "\n  \n\t\n",
+			expected: Stats{},
+		},
+		{
+			// Grades below zero are clamped.
+			name:     "simple prose",
+			text:     "One two three. Four five six.",
+			expected: Stats{Words: 6, Sentences: 2, ReadingTime: 1},
+		},
+		{
+			// 0.39*4/1 + 11.8*18/4 - 15.59
+			name:     "dense prose",
+			text:     "Comprehensive documentation facilitates understanding.",
+			expected: Stats{Words: 4, Sentences: 1, ReadingTime: 1, FleschKincaidGrade: 39.1},
+		},
+		{
+			name:     "paragraphs are sentences",
+			text:     "First paragraph without a stop\nsecond paragraph without one either",
+			expected: Stats{Words: 10, Sentences: 2, ReadingTime: 1, FleschKincaidGrade: 7.6},
+		},
+		{
+			// Code lines count towards words and reading time, but not
+			// sentences or readability.
+			name:     "code heavy",
+			text:     "Install it.\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n",
+			expected: Stats{Words: 7, Sentences: 1, ReadingTime: 1, FleschKincaidGrade: 2.9, CodeBlockRatio: 0.75},
+		},
+		{
+			name:     "only code",
+			text:     "x := 1;\ny := 2;\nreturn x + y;",
+			expected: Stats{Words: 7, ReadingTime: 1, CodeBlockRatio: 1},
+		},
+		{
+			name:     "ratio rounding",
+			text:     "if (a) {\nPlain words.\nMore plain words.",
+			expected: Stats{Words: 7, Sentences: 2, ReadingTime: 1, CodeBlockRatio: 0.333},
+		},
+	}
+	for _, testCase := range testCases {
+		actual := Analyze(testCase.text)
+		if !reflect.DeepEqual(*actual, testCase.expected) {
+			t.Errorf("%v: expected %+v, got %+v", testCase.name, testCase.expected, *actual)
+		}
+	}
+}
+
+func TestAnalyzeReadingTime(t *testing.T) {
+	prose := func(words int) string {
+		return strings.Repeat("word ", words-1) + "word."
+	}
+	code := func(words int) string {
+		return "    " + strings.Repeat("x ", words)
+	}
+	testCases := []struct {
+		text     string
+		expected int
+	}{
+		{prose(1), 1},
+		{prose(WordsPerMinute), 1},
+		{prose(WordsPerMinute + 1), 2},
+		{prose(2 * WordsPerMinute), 2},
+		// Code is read at a quarter of the speed.
+		{code(WordsPerMinute / 2), 2},
+		{code(WordsPerMinute/2) + "\n" + prose(1), 3},
+		{code(WordsPerMinute/4) + "\n" + prose(WordsPerMinute-WordsPerMinute/4*4), 1},
+	}
+	for i, testCase := range testCases {
+		if actual := Analyze(testCase.text).ReadingTime; actual != testCase.expected {
+			t.Errorf("[%v]: expected %v minutes, got %v", i, testCase.expected, actual)
+		}
+	}
+}
+
+func TestSyllables(t *testing.T) {
+	testCases := []struct {
+		word     string
+		expected int
+	}{
+		{"", 1},
+		{"a", 1},
+		{"cat", 1},
+		{"the", 1},
+		{"make", 1},
+		{"queue", 1},
+		{"strengths", 1},
+		{"rhythm", 1},
+		{"table", 2},
+		{"HELLO", 2},
+		{"beautiful", 3},
+		{"comprehensive", 4},
+		{"readability", 5},
+		{"42", 1},
+	}
+	for _, testCase := range testCases {
+		if actual := Syllables(testCase.word); actual != testCase.expected {
+			t.Errorf("Syllables(%q): expected %v, got %v", testCase.word, testCase.expected, actual)
+		}
+	}
+}
+
+func TestIsCodeLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected bool
+	}{
+		{"", false},
+		{"   ", false},
+		{"The quick brown fox jumps over the lazy dog.", false},
+		{"Use the -v flag (verbose) for more output.", false},
+		{"Prices rose 5% in Q1 & Q2; analysts were surprised.", false},
+		{"Is it better? Yes -- mostly.", false},
+		{"    indented like a code block", true},
+		{"\tindented with a tab", true},
+		{"if err != nil {", true},
+		{"x := 1", true},
+		{"return x;", true},
+		{"}", true},
+		{"def main():", true},
+		{"for (i = 0; i < n; i++) {", true},
+		{`<div class="x"></div>`, true},
+		{"const ids = items.map(item => item.id)", true},
+		{"a && b || c", true},
+	}
+	for _, testCase := range testCases {
+		if actual := IsCodeLine(testCase.line); actual != testCase.expected {
+			t.Errorf("IsCodeLine(%q): expected %v, got %v", testCase.line, testCase.expected, actual)
+		}
+	}
+}
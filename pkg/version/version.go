@@ -0,0 +1,25 @@
+package version
+
+// Build information, injected at link time, e.g.:
+//
+//	go build -ldflags "-X jaytaylor.com/circus/pkg/version.Version=v1.2.0 \
+//	    -X jaytaylor.com/circus/pkg/version.Commit=$(git rev-parse --short HEAD) \
+//	    -X jaytaylor.com/circus/pkg/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/circus
+//
+// The Makefile at the repository root does this.
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// String describes the build on one line.
+func String() string {
+	return fmt.Sprintf("circus %v (commit %v, built %v, %v)", Version, Commit, Date, runtime.Version())
+}
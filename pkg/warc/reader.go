@@ -0,0 +1,176 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxContentLength is the largest record content read into memory by
+// default.  Crawl archives routinely hold multi-gigabyte media records.
+const DefaultMaxContentLength = 64 << 20
+
+// Reader reads WARC records from a .warc or .warc.gz stream.
+type Reader struct {
+	// MaxContentLength bounds the content held in memory.  Larger records
+	// are skipped.
+	MaxContentLength int64
+
+	// Skip, when set, is called with each record before its content is read.
+	// Records it returns true for are skipped without being loaded.
+	Skip func(*Record) bool
+
+	// Skipped counts the records passed over.
+	Skipped int
+
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader for r, which may be gzip compressed (either as a
+// single member or one member per record).
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading warc: %s", err)
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("opening gzipped warc: %s", err)
+		}
+		br = bufio.NewReader(gz)
+	}
+	reader := &Reader{
+		MaxContentLength: DefaultMaxContentLength,
+		r:                br,
+	}
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF when there are no more.  Records
+// larger than MaxContentLength, or which Skip rejects, are passed over.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		record, err := r.next()
+		if err != nil || record != nil {
+			return record, err
+		}
+		r.Skipped++
+	}
+}
+
+// next reads the next record, returning a nil record when it was skipped.
+func (r *Reader) next() (*Record, error) {
+	// Skip blank lines between records.
+	var line string
+	for {
+		l, err := r.r.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(l) == "" {
+			return nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("reading warc record: %s", err)
+		}
+		if line = strings.TrimRight(l, "\r\n"); line != "" {
+			break
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("malformed warc record: expected version line but found %q", line)
+	}
+
+	record := &Record{
+		Header: map[string]string{},
+	}
+	for {
+		l, err := r.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading warc record header: %s", err)
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" {
+			break
+		}
+		idx := strings.Index(l, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("malformed warc header line %q", l)
+		}
+		record.Header[canonicalHeader(strings.TrimSpace(l[0:idx]))] = strings.TrimSpace(l[idx+1:])
+	}
+
+	length, err := strconv.ParseInt(record.Header[HeaderContentLength], 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("malformed warc record Content-Length %q", record.Header[HeaderContentLength])
+	}
+	if (r.MaxContentLength > 0 && length > r.MaxContentLength) || (r.Skip != nil && r.Skip(record)) {
+		if _, err := io.CopyN(ioutil.Discard, r.r, length); err != nil {
+			return nil, fmt.Errorf("skipping warc record content: %s", err)
+		}
+		return nil, nil
+	}
+	record.Content = make([]byte, length)
+	if _, err := io.ReadFull(r.r, record.Content); err != nil {
+		return nil, fmt.Errorf("reading warc record content: %s", err)
+	}
+	return record, nil
+}
+
+// canonicalHeader normalizes WARC header names to the capitalization used by
+// the Header constants.
+func canonicalHeader(name string) string {
+	parts := strings.Split(strings.ToLower(name), "-")
+	for i, part := range parts {
+		switch part {
+		case "warc":
+			parts[i] = "WARC"
+		case "id", "uri", "ip":
+			parts[i] = strings.ToUpper(part)
+		default:
+			if part != "" {
+				parts[i] = strings.ToUpper(part[0:1]) + part[1:]
+			}
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// HTTPResponse parses the HTTP response held by a response record.  The
+// returned body has transfer and content encodings removed.
+func (r *Record) HTTPResponse() (*http.Response, []byte, error) {
+	if r.Type() != TypeResponse {
+		return nil, nil, fmt.Errorf("warc record is a %v, not a %v", r.Type(), TypeResponse)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Content)), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing http response: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, nil, fmt.Errorf("reading http response body: %s", err)
+	}
+
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, fmt.Errorf("decompressing http response body: %s", err)
+		}
+		if body, err = ioutil.ReadAll(gz); err != nil && err != io.ErrUnexpectedEOF {
+			return nil, nil, fmt.Errorf("decompressing http response body: %s", err)
+		}
+	case "", "identity":
+	default:
+		return nil, nil, errors.New("unsupported content-encoding " + resp.Header.Get("Content-Encoding"))
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,132 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Exchange is a captured HTTP request and its response.
+type Exchange struct {
+	Date     time.Time
+	URL      string
+	Request  []byte // Request line and headers.
+	Response []byte // Status line, headers and the (decoded) body.
+}
+
+// Recorder captures every HTTP exchange made through transports it wraps.
+type Recorder struct {
+	Exchanges []*Exchange
+
+	mu sync.Mutex
+}
+
+// Wrap returns a RoundTripper which records exchanges made through base.
+func (r *Recorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &recordingTransport{
+		base:     base,
+		recorder: r,
+	}
+}
+
+// WriteTo writes a warcinfo record followed by request and response records
+// for each captured exchange.
+func (r *Recorder) WriteTo(w *Writer, software string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info := NewRecord(TypeWarcinfo, "", "application/warc-fields", []byte(fmt.Sprintf("software: %v\r\nformat: WARC File Format 1.0\r\n", software)))
+	if err := w.WriteRecord(info); err != nil {
+		return err
+	}
+
+	for _, exchange := range r.Exchanges {
+		date := exchange.Date.UTC().Format(time.RFC3339)
+
+		resp := NewRecord(TypeResponse, exchange.URL, "application/http; msgtype=response", exchange.Response)
+		resp.Header[HeaderDate] = date
+		req := NewRecord(TypeRequest, exchange.URL, "application/http; msgtype=request", exchange.Request)
+		req.Header[HeaderDate] = date
+		req.Header[HeaderConcurrentTo] = resp.ID()
+
+		if err := w.WriteRecord(req); err != nil {
+			return err
+		}
+		if err := w.WriteRecord(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) add(exchange *Exchange) {
+	r.mu.Lock()
+	r.Exchanges = append(r.Exchanges, exchange)
+	r.mu.Unlock()
+}
+
+type recordingTransport struct {
+	base     http.RoundTripper
+	recorder *Recorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := &Exchange{
+		Date:    time.Now(),
+		URL:     req.URL.String(),
+		Request: dumpRequest(req),
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body from %v: %s", exchange.URL, err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	exchange.Response = dumpResponse(resp, body)
+	t.recorder.add(exchange)
+
+	return resp, nil
+}
+
+func dumpRequest(req *http.Request) []byte {
+	buf := &bytes.Buffer{}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	fmt.Fprintf(buf, "%v %v HTTP/1.1\r\n", method, req.URL.RequestURI())
+	fmt.Fprintf(buf, "Host: %v\r\n", req.URL.Host)
+	req.Header.Write(buf)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// dumpResponse serializes resp with body.  The body has already had any
+// transfer encoding removed, so the headers are adjusted to match.
+func dumpResponse(resp *http.Response, body []byte) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "HTTP/%v.%v %v\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	header := http.Header{}
+	for name, values := range resp.Header {
+		header[name] = values
+	}
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+	header.Write(buf)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
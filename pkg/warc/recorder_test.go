@@ -0,0 +1,82 @@
+package warc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// Flushing forces a chunked response.
+		w.Write([]byte("<p>"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(r.URL.Path + "</p>"))
+	}))
+	defer server.Close()
+
+	recorder := &Recorder{}
+	client := &http.Client{Transport: recorder.Wrap(nil)}
+	for _, path := range []string{"/a", "/b"} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("User-Agent", "recorder-test")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if expected := "<p>" + path + "</p>"; string(body) != expected {
+			t.Errorf("expected the caller to still see %q, got %q", expected, body)
+		}
+	}
+	if len(recorder.Exchanges) != 2 {
+		t.Fatalf("expected 2 exchanges, got %v", len(recorder.Exchanges))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := recorder.WriteTo(NewWriter(buf, true), "circus-test"); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := readRecords(t, buf.Bytes())
+	if len(records) != 5 {
+		t.Fatalf("expected a warcinfo record and 2 request/response pairs, got %v records", len(records))
+	}
+	if records[0].Type() != TypeWarcinfo || !strings.Contains(string(records[0].Content), "software: circus-test") {
+		t.Errorf("unexpected warcinfo record %v %q", records[0].Header, records[0].Content)
+	}
+
+	for i, path := range []string{"/a", "/b"} {
+		req, resp := records[1+2*i], records[2+2*i]
+		if req.Type() != TypeRequest || resp.Type() != TypeResponse {
+			t.Fatalf("%v: expected a request then a response, got %v and %v", path, req.Type(), resp.Type())
+		}
+		if req.Header[HeaderConcurrentTo] != resp.ID() {
+			t.Errorf("%v: request is not concurrent to its response", path)
+		}
+		if req.TargetURI() != server.URL+path || resp.TargetURI() != server.URL+path {
+			t.Errorf("%v: unexpected target URIs %v and %v", path, req.TargetURI(), resp.TargetURI())
+		}
+		if !strings.HasPrefix(string(req.Content), "GET "+path+" HTTP/1.1\r\n") || !strings.Contains(string(req.Content), "User-Agent: recorder-test\r\n") {
+			t.Errorf("%v: unexpected request %q", path, req.Content)
+		}
+
+		httpResp, body, err := resp.HTTPResponse()
+		if err != nil {
+			t.Fatalf("%v: %s", path, err)
+		}
+		if expected := "<p>" + path + "</p>"; string(body) != expected {
+			t.Errorf("%v: expected body %q, got %q", path, expected, body)
+		}
+		if httpResp.StatusCode != http.StatusOK || httpResp.Header.Get("Content-Type") != "text/html" {
+			t.Errorf("%v: unexpected response %v %v", path, httpResp.StatusCode, httpResp.Header)
+		}
+		if len(httpResp.TransferEncoding) != 0 {
+			t.Errorf("%v: expected the chunked encoding to be removed, got %v", path, httpResp.TransferEncoding)
+		}
+	}
+}
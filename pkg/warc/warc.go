@@ -0,0 +1,167 @@
+package warc
+
+// Minimal WARC/1.0 (ISO 28500) record reading and writing.
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the WARC format version written.
+const Version = "WARC/1.0"
+
+// Record types used by this package.
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeResource = "resource"
+	TypeMetadata = "metadata"
+)
+
+// Header names.
+const (
+	HeaderType          = "WARC-Type"
+	HeaderRecordID      = "WARC-Record-ID"
+	HeaderDate          = "WARC-Date"
+	HeaderTargetURI     = "WARC-Target-URI"
+	HeaderConcurrentTo  = "WARC-Concurrent-To"
+	HeaderContentType   = "Content-Type"
+	HeaderContentLength = "Content-Length"
+)
+
+// leadingHeaders are written first, in this order.  Remaining headers follow
+// sorted by name, with Content-Length always last.
+var leadingHeaders = []string{HeaderType, HeaderRecordID, HeaderDate, HeaderTargetURI}
+
+// Record is a single WARC record.  Header keys use the canonical
+// capitalization from the specification, e.g. "WARC-Type".
+type Record struct {
+	Header  map[string]string
+	Content []byte
+}
+
+// NewRecord returns a record of the given type with a fresh record ID and
+// the current date.
+func NewRecord(typ string, targetURI string, contentType string, content []byte) *Record {
+	r := &Record{
+		Header: map[string]string{
+			HeaderType:     typ,
+			HeaderRecordID: NewRecordID(),
+			HeaderDate:     time.Now().UTC().Format(time.RFC3339),
+		},
+		Content: content,
+	}
+	if targetURI != "" {
+		r.Header[HeaderTargetURI] = targetURI
+	}
+	if contentType != "" {
+		r.Header[HeaderContentType] = contentType
+	}
+	return r
+}
+
+// Type returns the WARC-Type of the record.
+func (r *Record) Type() string {
+	return r.Header[HeaderType]
+}
+
+// ID returns the WARC-Record-ID of the record.
+func (r *Record) ID() string {
+	return r.Header[HeaderRecordID]
+}
+
+// TargetURI returns the WARC-Target-URI of the record, stripped of the
+// angle brackets some writers wrap it in.
+func (r *Record) TargetURI() string {
+	return strings.Trim(r.Header[HeaderTargetURI], "<>")
+}
+
+// NewRecordID generates a random UUID URN suitable for WARC-Record-ID.
+func NewRecordID() string {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %s", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Writer writes WARC records to an underlying stream.
+type Writer struct {
+	w        io.Writer
+	compress bool
+}
+
+// NewWriter returns a Writer.  When compress is true each record is written
+// as its own gzip member, as is conventional for .warc.gz files.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	writer := &Writer{
+		w:        w,
+		compress: compress,
+	}
+	return writer
+}
+
+// WriteRecord writes r, filling in Content-Length.
+func (w *Writer) WriteRecord(r *Record) error {
+	var (
+		out = w.w
+		gz  *gzip.Writer
+	)
+	if w.compress {
+		gz = gzip.NewWriter(w.w)
+		out = gz
+	}
+
+	var names []string
+	for name := range r.Header {
+		if !isLeading(name) && name != HeaderContentLength {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	b.WriteString(Version + "\r\n")
+	for _, name := range leadingHeaders {
+		if value, ok := r.Header[name]; ok {
+			fmt.Fprintf(b, "%v: %v\r\n", name, value)
+		}
+	}
+	for _, name := range names {
+		fmt.Fprintf(b, "%v: %v\r\n", name, r.Header[name])
+	}
+	fmt.Fprintf(b, "%v: %v\r\n\r\n", HeaderContentLength, len(r.Content))
+
+	if _, err := io.WriteString(out, b.String()); err != nil {
+		return fmt.Errorf("writing warc record header: %s", err)
+	}
+	if _, err := out.Write(r.Content); err != nil {
+		return fmt.Errorf("writing warc record content: %s", err)
+	}
+	if _, err := io.WriteString(out, "\r\n\r\n"); err != nil {
+		return fmt.Errorf("writing warc record trailer: %s", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("compressing warc record: %s", err)
+		}
+	}
+	return nil
+}
+
+func isLeading(name string) bool {
+	for _, leading := range leadingHeaders {
+		if name == leading {
+			return true
+		}
+	}
+	return false
+}
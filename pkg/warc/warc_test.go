@@ -0,0 +1,237 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeRecords(t *testing.T, compress bool, records ...*Record) []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, compress)
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func readRecords(t *testing.T, data []byte) ([]*Record, *Reader) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*Record
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, r
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := []*Record{
+		NewRecord(TypeWarcinfo, "", "application/warc-fields", []byte("software: circus\r\n")),
+		NewRecord(TypeResponse, "https://example.com/", "application/http; msgtype=response", []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<p>\r\n\r\nhi</p>")),
+		NewRecord(TypeResource, "https://example.com/empty", "", nil),
+	}
+	records[1].Header["WARC-Payload-Digest"] = "sha1:XYZ"
+
+	for _, compress := range []bool{false, true} {
+		data := writeRecords(t, compress, records...)
+		if compressed := data[0] == 0x1f && data[1] == 0x8b; compressed != compress {
+			t.Errorf("compress=%v: unexpected leading bytes %x", compress, data[0:2])
+		}
+		read, _ := readRecords(t, data)
+		if len(read) != len(records) {
+			t.Fatalf("compress=%v: expected %v records, got %v", compress, len(records), len(read))
+		}
+		for i, record := range read {
+			expected := map[string]string{HeaderContentLength: fmt.Sprint(len(records[i].Content))}
+			for name, value := range records[i].Header {
+				expected[name] = value
+			}
+			if !reflect.DeepEqual(record.Header, expected) {
+				t.Errorf("compress=%v [%v]: expected header %v, got %v", compress, i, expected, record.Header)
+			}
+			if !bytes.Equal(record.Content, records[i].Content) {
+				t.Errorf("compress=%v [%v]: expected content %q, got %q", compress, i, records[i].Content, record.Content)
+			}
+		}
+		if read[1].TargetURI() != "https://example.com/" || read[1].Type() != TypeResponse || read[1].ID() != records[1].ID() {
+			t.Errorf("compress=%v: unexpected accessors for %v", compress, read[1].Header)
+		}
+	}
+}
+
+func TestReadGzipMembers(t *testing.T) {
+	// A single gzip member holding several records, followed by a member per
+	// record, as produced by concatenating .warc.gz files.
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(writeRecords(t, false,
+		NewRecord(TypeResource, "https://example.com/1", "text/plain", []byte("one")),
+		NewRecord(TypeResource, "https://example.com/2", "text/plain", []byte("two")),
+	))
+	gz.Close()
+	buf.Write(writeRecords(t, true,
+		NewRecord(TypeResource, "https://example.com/3", "text/plain", []byte("three")),
+		NewRecord(TypeResource, "https://example.com/4", "text/plain", []byte("four")),
+	))
+
+	records, _ := readRecords(t, buf.Bytes())
+	var contents []string
+	for _, record := range records {
+		contents = append(contents, string(record.Content))
+	}
+	if expected := []string{"one", "two", "three", "four"}; !reflect.DeepEqual(contents, expected) {
+		t.Errorf("expected contents %v, got %v", expected, contents)
+	}
+}
+
+func TestReadHeaderCase(t *testing.T) {
+	data := "WARC/1.1\nwarc-type: response\nwarc-target-uri: <https://example.com/>\ncontent-length: 2\n\nok\n\n"
+	records, _ := readRecords(t, []byte(data))
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %v", len(records))
+	}
+	if records[0].Type() != TypeResponse || records[0].TargetURI() != "https://example.com/" || string(records[0].Content) != "ok" {
+		t.Errorf("unexpected record %v %q", records[0].Header, records[0].Content)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	full := string(writeRecords(t, false, NewRecord(TypeResource, "https://example.com/", "text/plain", []byte("0123456789"))))
+	header := full[0 : strings.Index(full, "\r\n\r\n")+4]
+
+	testCases := []struct {
+		data     string
+		expected string
+	}{
+		{full[0 : len(full)-9], "reading warc record content"},
+		{header, "reading warc record content"},
+		{header[0 : len(header)-10], "reading warc record header"},
+		{"HTTP/1.1 200 OK\r\n\r\n", "expected version line"},
+		{"WARC/1.0\r\nno colon\r\n\r\n", "malformed warc header line"},
+		{"WARC/1.0\r\nContent-Length: -1\r\n\r\n", "malformed warc record Content-Length"},
+		{"WARC/1.0\r\nContent-Length: 99999999999999999999\r\n\r\n", "malformed warc record Content-Length"},
+		{"WARC/1.0\r\nWARC-Type: resource\r\n\r\n", "malformed warc record Content-Length"},
+	}
+	for _, testCase := range testCases {
+		r, err := NewReader(strings.NewReader(testCase.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", testCase.data, testCase.expected, err)
+		}
+	}
+
+	// Truncated gzip members are errors too, rather than a silent EOF.
+	compressed := writeRecords(t, true, NewRecord(TypeResource, "https://example.com/", "text/plain", bytes.Repeat([]byte("x"), 4096)))
+	r, err := NewReader(bytes.NewReader(compressed[0 : len(compressed)/2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected an error reading a truncated gzip member, got %v", err)
+	}
+}
+
+func TestReadSkipsLargeAndRejected(t *testing.T) {
+	data := writeRecords(t, true,
+		NewRecord(TypeWarcinfo, "", "application/warc-fields", []byte("software: test\r\n")),
+		NewRecord(TypeResponse, "https://example.com/video.mp4", "application/http; msgtype=response", bytes.Repeat([]byte("v"), 1024)),
+		NewRecord(TypeResponse, "https://example.com/", "application/http; msgtype=response", []byte("small")),
+		NewRecord(TypeRequest, "https://example.com/", "application/http; msgtype=request", []byte("GET / HTTP/1.1\r\n\r\n")),
+	)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxContentLength = 512
+	r.Skip = func(record *Record) bool {
+		return record.Type() != TypeResponse
+	}
+	var uris []string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Content == nil {
+			t.Errorf("skipped record %v returned", record.Header)
+		}
+		uris = append(uris, record.TargetURI())
+	}
+	if expected := []string{"https://example.com/"}; !reflect.DeepEqual(uris, expected) {
+		t.Errorf("expected %v, got %v", expected, uris)
+	}
+	if r.Skipped != 3 {
+		t.Errorf("expected 3 skipped records, got %v", r.Skipped)
+	}
+
+	// Skipping a truncated record is still an error.
+	full := string(writeRecords(t, false, NewRecord(TypeResource, "https://example.com/", "text/plain", bytes.Repeat([]byte("x"), 1024))))
+	r, err = NewReader(strings.NewReader(full[0 : strings.Index(full, "\r\n\r\n")+100]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxContentLength = 512
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "skipping warc record content") {
+		t.Errorf("expected an error skipping a truncated record, got %v", err)
+	}
+}
+
+func TestHTTPResponse(t *testing.T) {
+	gzipped := &bytes.Buffer{}
+	gz := gzip.NewWriter(gzipped)
+	gz.Write([]byte("<p>compressed</p>"))
+	gz.Close()
+
+	testCases := []struct {
+		typ      string
+		content  string
+		expected string
+		err      string
+	}{
+		{TypeResponse, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 9\r\n\r\n<p>hi</p>", "<p>hi</p>", ""},
+		{TypeResponse, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n4\r\n<p>h\r\n5\r\ni</p>\r\n0\r\n\r\n", "<p>hi</p>", ""},
+		{TypeResponse, "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n\r\n" + gzipped.String(), "<p>compressed</p>", ""},
+		{TypeResponse, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\ntruncated", "truncated", ""},
+		{TypeResponse, "HTTP/1.1 200 OK\r\nContent-Encoding: br\r\n\r\nxx", "", "unsupported content-encoding"},
+		{TypeResponse, "not http", "", "parsing http response"},
+		{TypeRequest, "GET / HTTP/1.1\r\n\r\n", "", "not a response"},
+	}
+	for _, testCase := range testCases {
+		record := NewRecord(testCase.typ, "https://example.com/", "", []byte(testCase.content))
+		_, body, err := record.HTTPResponse()
+		if testCase.err != "" {
+			if err == nil || !strings.Contains(err.Error(), testCase.err) {
+				t.Errorf("%q: expected an error containing %q, got %v", testCase.content, testCase.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", testCase.content, err)
+			continue
+		}
+		if string(body) != testCase.expected {
+			t.Errorf("%q: expected body %q, got %q", testCase.content, testCase.expected, body)
+		}
+	}
+}
@@ -6,7 +6,11 @@ set -o nounset
 
 cd "$(dirname "$0")/.."
 
-user="${user:-jaytaylor}"
+# The HN user comes from the circus config (hn.user, or $CIRCUS_HN_USER), and
+# may still be overridden with $user.
+make -s build
+eval "$(bin/circus config print --format shell)"
+user="${user:-${CIRCUS_HN_USER}}"
 
 function favorites() {
     if [ -e favorites.json ] ; then
@@ -32,9 +36,22 @@ function upvotes() {
     fi
 }
 
+# Re-poll points, comment counts and dead/deleted status of already hydrated
+# stories.  Only stories due on their decaying schedule are fetched.
+function scores() {
+    local dir
+    for dir in "${@:-upvotes-data}" ; do
+        if [ -d "${dir}" ] ; then
+            echo "INFO: refreshing story metadata in ${dir}" 1>&2
+            bin/circus hn-refresh -q "${dir}"
+        fi
+    done
+}
+
 if [ "${BASH_SOURCE[0]}" = "${0}" ] ; then
     # Only auto-run when being executed (and don't auto-run functions when being sourced).
     favorites
     upvotes
+    scores
 fi
 
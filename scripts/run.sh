@@ -4,6 +4,8 @@ set -o errexit
 set -o pipefail
 set -o nounset 
 
-here="$(dirname "$0")"
+cd "$(dirname "$0")/.."
 
-"${here}/generate-site.sh" -s "${here}/../upvotes-data" -o "${here}/../quickstart" -l 100 $*
+make -s build
+
+bin/circus site -s upvotes-data -l 100 "$@"